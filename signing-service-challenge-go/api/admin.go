@@ -0,0 +1,245 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportDeviceRequest represents the request body for exporting a device backup
+type ExportDeviceRequest struct {
+	Password string `json:"password" binding:"required"` // encrypts the exported private key
+}
+
+// RestoreDeviceRequest represents the request body for restoring a device backup
+type RestoreDeviceRequest struct {
+	Backup   DeviceBackup `json:"backup"`
+	Password string       `json:"password" binding:"required"`
+}
+
+// DeviceBackup is an offline escrow copy of a device: its settings, the state
+// of its signature chain and its private key as password protected PKCS#8.
+type DeviceBackup struct {
+	ID                string                         `json:"id"`
+	Algorithm         domain.SignatureAlgorithm      `json:"algorithm"`
+	Label             string                         `json:"label,omitempty"`
+	Metadata          map[string]string              `json:"metadata,omitempty"`
+	Curve             string                         `json:"curve,omitempty"`
+	RSAScheme         string                         `json:"rsa_scheme,omitempty"`
+	Deterministic     bool                           `json:"deterministic,omitempty"`
+	SignatureEncoding string                         `json:"signature_encoding,omitempty"`
+	SignatureCounter  int                            `json:"signature_counter"`
+	LastSignature     string                         `json:"last_signature,omitempty"`
+	PrivateKey        string                         `json:"private_key"`            // ENCRYPTED PRIVATE KEY PEM (PBES2, PBKDF2-HMAC-SHA256, AES-256-CBC)
+	RetiredKeys       []persistence.RetiredKeyRecord `json:"retired_keys,omitempty"` // public keys replaced by key rotations, oldest first
+	Status            domain.DeviceStatus            `json:"status,omitempty"`       // lifecycle state, empty means active
+	CreatedAt         *time.Time                     `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time                     `json:"updated_at,omitempty"`
+	LastSignedAt      *time.Time                     `json:"last_signed_at,omitempty"`
+	ExportedAt        time.Time                      `json:"exported_at"`
+}
+
+// requireAdmin only lets requests through that carry the admin token as a
+// bearer token. Without a configured admin token the admin endpoints are
+// disabled.
+func (s *Server) requireAdmin(c *gin.Context) {
+	if s.adminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+			Errors: []string{"Admin endpoints are disabled"},
+		})
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	// Compare digests so that the comparison takes the same time for any length
+	given, expected := sha256.Sum256([]byte(token)), sha256.Sum256([]byte(s.adminToken))
+	if !ok || subtle.ConstantTimeCompare(given[:], expected[:]) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+			Errors: []string{"Admin token required"},
+		})
+		return
+	}
+
+	c.Next()
+}
+
+// ExportDevice returns a backup of a device with its private key encrypted
+// under the given password. The signature counter and last signature are
+// taken together with the key, so the backup restores to a consistent chain.
+func (s *Server) ExportDevice(c *gin.Context) {
+	id := c.Param("id")
+
+	var req ExportDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid request body: " + err.Error()},
+		})
+		return
+	}
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+	// The snapshot holds its own copy of the private key, which a concurrent
+	// deletion cannot zeroize while it is being encrypted
+	snapshot := device.Clone()
+	defer crypto.ZeroizePrivateKey(snapshot.PrivateKey)
+	if snapshot.Status == domain.StatusDeleted {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Errors: []string{"Device cannot be exported: " + domain.ErrDeviceDeleted.Error()},
+		})
+		return
+	}
+
+	retiredKeys, err := persistence.EncodeRetiredKeys(snapshot.RetiredKeys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to encode retired keys: " + err.Error()},
+		})
+		return
+	}
+	privateKey, err := crypto.NewPKCS8Marshaler().MarshalEncrypted(snapshot.PrivateKey, []byte(req.Password))
+	if err != nil {
+		if errors.Is(err, crypto.ErrWeakPassword) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{"Invalid password: " + err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to encrypt private key: " + err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Data: DeviceBackup{
+		ID:                snapshot.ID,
+		Algorithm:         snapshot.Algorithm,
+		Label:             snapshot.Label,
+		Metadata:          snapshot.Metadata,
+		Curve:             snapshot.Curve,
+		RSAScheme:         snapshot.RSAScheme,
+		Deterministic:     snapshot.Deterministic,
+		SignatureEncoding: snapshot.SignatureEncoding,
+		SignatureCounter:  snapshot.SignatureCounter,
+		LastSignature:     snapshot.LastSignature,
+		PrivateKey:        string(privateKey),
+		RetiredKeys:       retiredKeys,
+		Status:            snapshot.Status,
+		CreatedAt:         optionalTime(snapshot.CreatedAt),
+		UpdatedAt:         optionalTime(snapshot.UpdatedAt),
+		LastSignedAt:      optionalTime(snapshot.LastSignedAt),
+		ExportedAt:        time.Now().UTC(),
+	}})
+}
+
+// RestoreDevice recreates a device from a backup taken by ExportDevice. The
+// device keeps its ID, settings and chain state; it fails with a conflict if
+// a device with that ID still exists.
+func (s *Server) RestoreDevice(c *gin.Context) {
+	var req RestoreDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid request body: " + err.Error()},
+		})
+		return
+	}
+
+	backup := req.Backup
+	if backup.ID == "" || backup.Algorithm == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Backup must contain the device ID and algorithm"},
+		})
+		return
+	}
+	if block, _ := pem.Decode([]byte(backup.PrivateKey)); block == nil || block.Type != crypto.PEMTypeEncryptedPrivateKey {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Backup must contain an " + crypto.PEMTypeEncryptedPrivateKey},
+		})
+		return
+	}
+
+	if backup.Status == domain.StatusDeleted || backup.Status != "" && !domain.ValidDeviceStatus(backup.Status) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid status: " + string(backup.Status)},
+		})
+		return
+	}
+
+	retiredKeys, err := persistence.DecodeRetiredKeys(backup.RetiredKeys)
+	if err == nil {
+		err = validateKeyHistory(retiredKeys, backup.SignatureCounter)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid retired keys: " + err.Error()},
+		})
+		return
+	}
+
+	s.importDevice(c, ImportDeviceRequest{
+		Algorithm:         backup.Algorithm,
+		ID:                backup.ID,
+		Label:             backup.Label,
+		PrivateKey:        backup.PrivateKey,
+		Password:          req.Password,
+		RSAScheme:         backup.RSAScheme,
+		Deterministic:     backup.Deterministic,
+		SignatureEncoding: backup.SignatureEncoding,
+		SignatureCounter:  backup.SignatureCounter,
+		LastSignature:     backup.LastSignature,
+		Metadata:          backup.Metadata,
+	}, restoredState{
+		RetiredKeys:  retiredKeys,
+		Status:       backup.Status,
+		CreatedAt:    timeOrZero(backup.CreatedAt),
+		UpdatedAt:    timeOrZero(backup.UpdatedAt),
+		LastSignedAt: timeOrZero(backup.LastSignedAt),
+	})
+}
+
+// validateKeyHistory checks that retired keys cover consecutive counter
+// ranges, starting at 0 and ending before the signature counter.
+func validateKeyHistory(retiredKeys []domain.RetiredKey, signatureCounter int) error {
+	next := 0
+	for i, retired := range retiredKeys {
+		if retired.FirstCounter != next || retired.LastCounter < retired.FirstCounter {
+			return fmt.Errorf("retired key %d covers counters %d-%d, expected a range starting at %d",
+				i+1, retired.FirstCounter, retired.LastCounter, next)
+		}
+		next = retired.LastCounter + 1
+	}
+	if next > signatureCounter {
+		return fmt.Errorf("retired keys cover counters up to %d, beyond the signature counter %d", next-1, signatureCounter)
+	}
+	return nil
+}
+
+// timeOrZero dereferences an optional time, mapping nil to the zero time.
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name           string
+		adminToken     string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "success - admin token", adminToken: "secret", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "error - admin endpoints disabled", adminToken: "", authorization: "Bearer ", expectedStatus: http.StatusForbidden},
+		{name: "error - missing token", adminToken: "secret", authorization: "", expectedStatus: http.StatusUnauthorized},
+		{name: "error - wrong token", adminToken: "secret", authorization: "Bearer wrong", expectedStatus: http.StatusUnauthorized},
+		{name: "error - not a bearer token", adminToken: "secret", authorization: "Basic secret", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(":8080", WithAdminToken(tt.adminToken))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/admin/devices/restore", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			server.requireAdmin(c)
+
+			if c.IsAborted() != (tt.expectedStatus != http.StatusOK) {
+				t.Errorf("expected aborted to be %v", tt.expectedStatus != http.StatusOK)
+			}
+			if c.IsAborted() && w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestExportDevice(t *testing.T) {
+	tests := []struct {
+		name           string
+		deviceID       string
+		requestBody    interface{}
+		expectedStatus int
+	}{
+		{name: "success - export device", deviceID: "device", requestBody: ExportDeviceRequest{Password: "correct horse"}, expectedStatus: http.StatusOK},
+		{name: "error - device not found", deviceID: "non-existent", requestBody: ExportDeviceRequest{Password: "correct horse"}, expectedStatus: http.StatusNotFound},
+		{name: "error - weak password", deviceID: "device", requestBody: ExportDeviceRequest{Password: "short"}, expectedStatus: http.StatusBadRequest},
+		{name: "error - missing password", deviceID: "device", requestBody: map[string]string{}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			createTestDevice(t, server, "device")
+			signTestTransaction(t, server, "device", "first")
+
+			w := exportTestDevice(server, tt.deviceID, tt.requestBody)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data DeviceBackup `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if response.Data.SignatureCounter != 1 || response.Data.LastSignature == "" {
+				t.Errorf("expected counter 1 with a last signature, got %d, %q", response.Data.SignatureCounter, response.Data.LastSignature)
+			}
+			if !strings.Contains(response.Data.PrivateKey, crypto.PEMTypeEncryptedPrivateKey) {
+				t.Errorf("expected an encrypted private key, got %q", response.Data.PrivateKey)
+			}
+		})
+	}
+}
+
+func TestRestoreDevice(t *testing.T) {
+	source := setupTestServer()
+	createTestDevice(t, source, "device").Metadata = map[string]string{"store_id": "42"}
+	signTestTransaction(t, source, "device", "first")
+	last := signTestTransaction(t, source, "device", "second")
+
+	w := exportTestDevice(source, "device", ExportDeviceRequest{Password: "correct horse"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to export device: %s", w.Body.String())
+	}
+	var exported struct {
+		Data DeviceBackup `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &exported)
+	backup := exported.Data
+
+	_, plainKey, _ := crypto.NewPKCS8Marshaler().Marshal(mustGetDevice(t, source, "device").PrivateKey)
+	plainBackup := backup
+	plainBackup.PrivateKey = string(plainKey)
+	wrongAlgorithm := backup
+	wrongAlgorithm.Algorithm = domain.AlgorithmRSA
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		setup          func(*Server)
+		expectedStatus int
+	}{
+		{
+			name:           "success - restore device",
+			requestBody:    RestoreDeviceRequest{Backup: backup, Password: "correct horse"},
+			setup:          func(s *Server) {},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "error - device exists",
+			requestBody:    RestoreDeviceRequest{Backup: backup, Password: "correct horse"},
+			setup:          func(s *Server) { createTestDevice(t, s, "device") },
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "error - wrong password",
+			requestBody:    RestoreDeviceRequest{Backup: backup, Password: "wrong horse"},
+			setup:          func(s *Server) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - unencrypted private key",
+			requestBody:    RestoreDeviceRequest{Backup: plainBackup, Password: "correct horse"},
+			setup:          func(s *Server) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - algorithm does not match key",
+			requestBody:    RestoreDeviceRequest{Backup: wrongAlgorithm, Password: "correct horse"},
+			setup:          func(s *Server) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - empty backup",
+			requestBody:    RestoreDeviceRequest{Password: "correct horse"},
+			setup:          func(s *Server) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			tt.setup(server)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/admin/devices/restore", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			server.RestoreDevice(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			got := getTestDevice(t, server, "device")
+			if got.Metadata["store_id"] != "42" {
+				t.Errorf("expected metadata to be restored, got %v", got.Metadata)
+			}
+			original := mustGetDevice(t, source, "device")
+			if got.CreatedAt == nil || !got.CreatedAt.Equal(original.CreatedAt) {
+				t.Errorf("expected created at %v to be restored, got %v", original.CreatedAt, got.CreatedAt)
+			}
+			if got.LastSignedAt == nil || !got.LastSignedAt.Equal(original.LastSignedAt) {
+				t.Errorf("expected last signed at %v to be restored, got %v", original.LastSignedAt, got.LastSignedAt)
+			}
+
+			// The restored device continues the chain of the original
+			signed := signTestTransaction(t, server, "device", "third")
+			if expected := "2_third_" + last.Signature; signed.SignedData != expected {
+				t.Errorf("expected signed data %q, got %q", expected, signed.SignedData)
+			}
+		})
+	}
+}
+
+// exportTestDevice calls the ExportDevice handler with body.
+func exportTestDevice(s *Server, id string, body interface{}) *httptest.ResponseRecorder {
+	encoded, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/admin/devices/"+id+"/export", bytes.NewBuffer(encoded))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.ExportDevice(c)
+	return w
+}
+
+// mustGetDevice returns a stored device.
+func mustGetDevice(t *testing.T, s *Server, id string) *domain.Device {
+	t.Helper()
+
+	device, err := s.repository.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+	return device
+}
+
+func TestRestoreDevice_KeyHistory(t *testing.T) {
+	source := setupTestServer()
+	createTestDevice(t, source, "device")
+	signed := []domain.SignatureResponse{signTestTransaction(t, source, "device", "before")}
+	w := rotateTestKey(source, "device")
+	var rotated struct {
+		Data RotateKeyResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &rotated)
+	signed = append(signed, rotated.Data.Rotation, signTestTransaction(t, source, "device", "after"))
+
+	w = exportTestDevice(source, "device", ExportDeviceRequest{Password: "correct horse"})
+	var exported struct {
+		Data DeviceBackup `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &exported)
+	if len(exported.Data.RetiredKeys) != 1 {
+		t.Fatalf("expected one retired key in the backup, got %d", len(exported.Data.RetiredKeys))
+	}
+
+	gap := exported.Data
+	gap.RetiredKeys = append(gap.RetiredKeys[:0:0], gap.RetiredKeys...)
+	gap.RetiredKeys[0].FirstCounter = 1
+
+	tests := []struct {
+		name           string
+		backup         DeviceBackup
+		expectedStatus int
+	}{
+		{name: "success - history restored", backup: exported.Data, expectedStatus: http.StatusCreated},
+		{name: "error - history with a gap", backup: gap, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			body, _ := json.Marshal(RestoreDeviceRequest{Backup: tt.backup, Password: "correct horse"})
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/admin/devices/restore", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			server.RestoreDevice(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			// Signatures of the retired key still verify on the restored device
+			links := chainLinks(append(signed, signTestTransaction(t, server, "device", "restored")))
+			if chain := verifyTestChain(server, "device", links); !chain.Valid {
+				t.Errorf("expected the chain to verify across the rotation, got %+v", chain)
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package api
 
 import (
-	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
@@ -11,19 +16,57 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	// DefaultDevicePageSize is the page size used when no limit is given.
+	DefaultDevicePageSize = 50
+	// MaxDevicePageSize is the largest page size a client may request.
+	MaxDevicePageSize = 500
+)
+
 // CreateDeviceRequest represents the request body for creating a device
 type CreateDeviceRequest struct {
-	ID        string                    `json:"id,omitempty"`
-	Algorithm domain.SignatureAlgorithm `json:"algorithm" binding:"required"`
-	Label     string                    `json:"label,omitempty"`
+	ID                string                    `json:"id,omitempty"`
+	Algorithm         domain.SignatureAlgorithm `json:"algorithm" binding:"required"`
+	Label             string                    `json:"label,omitempty"`
+	KeySize           int                       `json:"key_size,omitempty"`           // RSA modulus size in bits, defaults to crypto.DefaultRSAKeySize
+	Curve             string                    `json:"curve,omitempty"`              // ECDSA curve, defaults to crypto.DefaultCurve
+	RSAScheme         string                    `json:"rsa_scheme,omitempty"`         // RSA signature scheme, defaults to crypto.DefaultRSAScheme
+	Deterministic     bool                      `json:"deterministic,omitempty"`      // ECDSA only: derive nonces as in RFC 6979
+	SignatureEncoding string                    `json:"signature_encoding,omitempty"` // ECDSA only: DER (default) or RAW r||s
+	Metadata          map[string]string         `json:"metadata,omitempty"`           // tags such as store or register number, see domain.ValidateMetadata
 }
 
 // CreateDeviceResponse represents the response after creating a device
 type CreateDeviceResponse struct {
-	ID               string                    `json:"id"`
-	Algorithm        domain.SignatureAlgorithm `json:"algorithm"`
-	Label            string                    `json:"label,omitempty"`
-	SignatureCounter int                       `json:"signature_counter"`
+	ID                string                    `json:"id"`
+	Algorithm         domain.SignatureAlgorithm `json:"algorithm"`
+	Label             string                    `json:"label,omitempty"`
+	Metadata          map[string]string         `json:"metadata,omitempty"`
+	KeySize           int                       `json:"key_size"`
+	Curve             string                    `json:"curve,omitempty"`
+	RSAScheme         string                    `json:"rsa_scheme,omitempty"`
+	Deterministic     bool                      `json:"deterministic,omitempty"`
+	SignatureEncoding string                    `json:"signature_encoding,omitempty"`
+	SignatureCounter  int                       `json:"signature_counter"`
+	KeyVersion        int                       `json:"key_version"`
+	Status            domain.DeviceStatus       `json:"status"`
+	CreatedAt         *time.Time                `json:"created_at,omitempty"`     // omitted for devices stored before timestamps
+	UpdatedAt         *time.Time                `json:"updated_at,omitempty"`     // last change of label, metadata, lifecycle state or key
+	LastSignedAt      *time.Time                `json:"last_signed_at,omitempty"` // omitted until the device signs
+}
+
+// UpdateDeviceRequest represents the request body for updating a device. Only
+// the label and metadata can change; any other field is rejected. Metadata is
+// merged as in a JSON merge patch (RFC 7396): null removes an entry.
+type UpdateDeviceRequest struct {
+	Label    *string            `json:"label,omitempty"`
+	Metadata map[string]*string `json:"metadata,omitempty"`
+}
+
+// DeleteDeviceResponse represents the result of deleting a device
+type DeleteDeviceResponse struct {
+	Device       CreateDeviceResponse      `json:"device"`
+	Decommission *domain.SignatureResponse `json:"decommission,omitempty"` // omitted if the device was already retired
 }
 
 // SignTransactionRequest represents the request body for signing a transaction
@@ -42,10 +85,30 @@ func (s *Server) CreateDevice(c *gin.Context) {
 		return
 	}
 
-	// Validate algorithm
-	if req.Algorithm != domain.AlgorithmRSA && req.Algorithm != domain.AlgorithmECDSA {
+	// Look up algorithm and validate its key options
+	algorithm, err := crypto.Lookup(string(req.Algorithm))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Algorithm must be one of: " + strings.Join(crypto.DefaultRegistry.Names(), ", ")},
+		})
+		return
+	}
+	keyOptions := withKeyOptionDefaults(req.Algorithm, crypto.KeyOptions{
+		Bits:          req.KeySize,
+		Curve:         req.Curve,
+		Scheme:        req.RSAScheme,
+		Deterministic: req.Deterministic,
+		Encoding:      req.SignatureEncoding,
+	})
+	if err := algorithm.ValidateOptions(keyOptions); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Errors: []string{"Algorithm must be either 'RSA' or 'ECDSA'"},
+			Errors: []string{"Invalid key options: " + err.Error()},
+		})
+		return
+	}
+	if err := domain.ValidateMetadata(req.Metadata); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{err.Error()},
 		})
 		return
 	}
@@ -56,39 +119,24 @@ func (s *Server) CreateDevice(c *gin.Context) {
 		deviceID = uuid.New().String()
 	}
 
-	// Generate key pair based on algorithm
-	var publicKey, privateKey interface{}
-	var err error
-
-	if req.Algorithm == domain.AlgorithmRSA {
-		generator := &crypto.RSAGenerator{}
-		keyPair, genErr := generator.Generate()
-		if genErr != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Errors: []string{"Failed to generate RSA key pair: " + genErr.Error()},
-			})
-			return
-		}
-		publicKey = keyPair.Public
-		privateKey = keyPair.Private
-	} else {
-		generator := &crypto.ECCGenerator{}
-		keyPair, genErr := generator.Generate()
-		if genErr != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Errors: []string{"Failed to generate ECDSA key pair: " + genErr.Error()},
-			})
-			return
-		}
-		publicKey = keyPair.Public
-		privateKey = keyPair.Private
+	// Generate key pair
+	publicKey, privateKey, err := algorithm.GenerateKey(keyOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to generate " + algorithm.Name() + " key pair: " + err.Error()},
+		})
+		return
 	}
 
 	// Create device
 	device := domain.NewDevice(deviceID, req.Algorithm, req.Label, publicKey, privateKey)
+	device.SetKeyOptions(keyOptions)
+	if len(req.Metadata) > 0 {
+		device.Metadata = req.Metadata
+	}
 
 	// Store device
-	if err = s.repository.Create(device); err != nil {
+	if err = s.repository.Create(c.Request.Context(), device); err != nil {
 		if err == persistence.ErrDeviceAlreadyExists {
 			c.JSON(http.StatusConflict, ErrorResponse{
 				Errors: []string{"Device with this ID already exists"},
@@ -101,44 +149,115 @@ func (s *Server) CreateDevice(c *gin.Context) {
 		return
 	}
 
-	response := CreateDeviceResponse{
-		ID:               device.ID,
-		Algorithm:        device.Algorithm,
-		Label:            device.Label,
-		SignatureCounter: device.SignatureCounter,
-	}
+	c.JSON(http.StatusCreated, Response{Data: newDeviceResponse(device)})
+}
 
-	c.JSON(http.StatusCreated, Response{Data: response})
+// ListDevicesResponse represents one page of the device listing
+type ListDevicesResponse struct {
+	Devices    []CreateDeviceResponse `json:"devices"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
 }
 
-// ListDevices returns all signature devices
+// ListDevices returns a page of the signature devices. Pages are requested
+// with the optional "limit" and "cursor" query parameters, the order with
+// "sort" ("id", "-id", "created_at" or "-created_at"), and the devices are
+// filtered by the optional "algorithm", "label" (substring), "status",
+// repeatable "metadata" ("key:value"), "created_from" and "created_before"
+// (RFC 3339) query parameters.
 func (s *Server) ListDevices(c *gin.Context) {
-	devices, err := s.repository.List()
+	opts, err := listOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{err.Error()},
+		})
+		return
+	}
+
+	page, err := s.repository.List(c.Request.Context(), opts)
 	if err != nil {
+		if errors.Is(err, persistence.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{"Invalid cursor"},
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Errors: []string{"Failed to list devices: " + err.Error()},
 		})
 		return
 	}
 
-	response := make([]CreateDeviceResponse, len(devices))
-	for i, device := range devices {
-		response[i] = CreateDeviceResponse{
-			ID:               device.ID,
-			Algorithm:        device.Algorithm,
-			Label:            device.Label,
-			SignatureCounter: device.SignatureCounter,
-		}
+	response := ListDevicesResponse{
+		Devices:    make([]CreateDeviceResponse, len(page.Devices)),
+		NextCursor: page.NextCursor,
+	}
+	for i, device := range page.Devices {
+		response.Devices[i] = newDeviceResponse(device)
 	}
 
 	c.JSON(http.StatusOK, Response{Data: response})
 }
 
+// listOptionsFromQuery reads the paging, sorting and filter query parameters
+// of the device listing.
+func listOptionsFromQuery(c *gin.Context) (persistence.ListOptions, error) {
+	opts := persistence.ListOptions{
+		Algorithm: domain.SignatureAlgorithm(c.Query("algorithm")),
+		Label:     c.Query("label"),
+		Status:    domain.DeviceStatus(c.Query("status")),
+		Sort:      persistence.DeviceSort(c.Query("sort")),
+		Cursor:    c.Query("cursor"),
+		Limit:     DefaultDevicePageSize,
+	}
+
+	if rawLimit := c.Query("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > MaxDevicePageSize {
+			return opts, fmt.Errorf("Limit must be between 1 and %d", MaxDevicePageSize)
+		}
+		opts.Limit = parsed
+	}
+	if opts.Status != "" && !domain.ValidDeviceStatus(opts.Status) {
+		return opts, fmt.Errorf("Unknown status: %s", opts.Status)
+	}
+	if !persistence.ValidDeviceSort(opts.Sort) {
+		return opts, fmt.Errorf("Unknown sort order: %s", opts.Sort)
+	}
+	for _, bound := range []struct {
+		param string
+		to    *time.Time
+	}{
+		{"created_from", &opts.CreatedFrom},
+		{"created_before", &opts.CreatedBefore},
+	} {
+		if raw := c.Query(bound.param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be an RFC 3339 timestamp, got %q", bound.param, raw)
+			}
+			*bound.to = parsed
+		}
+	}
+
+	for _, entry := range c.QueryArray("metadata") {
+		key, value, ok := strings.Cut(entry, ":")
+		if !ok || key == "" {
+			return opts, fmt.Errorf("Metadata filter must have the form key:value, got %q", entry)
+		}
+		if opts.Metadata == nil {
+			opts.Metadata = make(map[string]string)
+		}
+		opts.Metadata[key] = value
+	}
+
+	return opts, nil
+}
+
 // GetDevice returns a single signature device by ID
 func (s *Server) GetDevice(c *gin.Context) {
 	id := c.Param("id")
 
-	device, err := s.repository.Get(id)
+	device, err := s.repository.Get(c.Request.Context(), id)
 	if err != nil {
 		if err == persistence.ErrDeviceNotFound {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -152,16 +271,179 @@ func (s *Server) GetDevice(c *gin.Context) {
 		return
 	}
 
-	response := CreateDeviceResponse{
-		ID:               device.ID,
-		Algorithm:        device.Algorithm,
-		Label:            device.Label,
-		SignatureCounter: device.SignatureCounter,
+	c.JSON(http.StatusOK, Response{Data: newDeviceResponse(device)})
+}
+
+// UpdateDevice changes the label and metadata of a device. Algorithm and keys
+// can never be changed.
+func (s *Server) UpdateDevice(c *gin.Context) {
+	id := c.Param("id")
+
+	var req UpdateDeviceRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid request body: " + err.Error()},
+		})
+		return
+	}
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	if err := device.UpdateDetails(req.Label, req.Metadata); err != nil {
+		if errors.Is(err, domain.ErrInvalidMetadata) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{err.Error()},
+			})
+			return
+		}
+		if errors.Is(err, domain.ErrDeviceDeleted) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{"Device cannot be updated: " + err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to update device: " + err.Error()},
+		})
+		return
+	}
+
+	// Persist updated device
+	if err = s.repository.Update(c.Request.Context(), device); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to update device: " + err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Data: newDeviceResponse(device)})
+}
+
+// DeleteDevice soft deletes a device. A device that is not retired yet is
+// retired first, sealing its chain with a decommission record that is
+// recorded in the signature journal. The private key is zeroized and erased
+// from storage; the device, its public keys and its signature journal remain
+// available for audits.
+func (s *Server) DeleteDevice(c *gin.Context) {
+	id := c.Param("id")
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get " + string(device.Algorithm) + " algorithm: " + err.Error()},
+		})
+		return
+	}
+
+	// Seal and journal the chain if needed and zeroize the private key
+	// atomically
+	record, err := device.Delete(algorithm.NewSigner, s.journalFunc(c.Request.Context()))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to delete device: " + err.Error()},
+		})
+		return
+	}
+
+	// Persist the deletion, erasing the stored private key
+	if err = s.repository.Delete(c.Request.Context(), device); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to delete device: " + err.Error()},
+		})
+		return
+	}
+
+	response := DeleteDeviceResponse{Device: newDeviceResponse(device)}
+	if record != nil {
+		decommission := record.Response()
+		response.Decommission = &decommission
 	}
 
 	c.JSON(http.StatusOK, Response{Data: response})
 }
 
+// withKeyOptionDefaults fills in the RSA scheme and ECDSA signature encoding
+// when they are not given, so that devices report what they actually use
+func withKeyOptionDefaults(algorithm domain.SignatureAlgorithm, opts crypto.KeyOptions) crypto.KeyOptions {
+	if algorithm == domain.AlgorithmRSA && opts.Scheme == "" {
+		opts.Scheme = crypto.DefaultRSAScheme
+	}
+	if algorithm == domain.AlgorithmECDSA && opts.Encoding == "" {
+		opts.Encoding = crypto.DefaultSignatureEncoding
+	}
+	return opts
+}
+
+// newDeviceResponse builds the client facing view of a device
+func newDeviceResponse(device *domain.Device) CreateDeviceResponse {
+	snapshot := device.Snapshot()
+	status := snapshot.Status
+	if status == "" {
+		status = domain.StatusActive
+	}
+
+	return CreateDeviceResponse{
+		ID:                snapshot.ID,
+		Algorithm:         snapshot.Algorithm,
+		Label:             snapshot.Label,
+		Metadata:          snapshot.Metadata,
+		KeySize:           snapshot.KeySize(),
+		Curve:             snapshot.Curve,
+		RSAScheme:         snapshot.RSAScheme,
+		Deterministic:     snapshot.Deterministic,
+		SignatureEncoding: snapshot.SignatureEncoding,
+		SignatureCounter:  snapshot.SignatureCounter,
+		KeyVersion:        snapshot.KeyVersion(),
+		Status:            status,
+		CreatedAt:         optionalTime(snapshot.CreatedAt),
+		UpdatedAt:         optionalTime(snapshot.UpdatedAt),
+		LastSignedAt:      optionalTime(snapshot.LastSignedAt),
+	}
+}
+
+// optionalTime returns nil for the zero time, so that it is omitted from
+// responses.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 // SignTransaction signs transaction data with the specified device
 func (s *Server) SignTransaction(c *gin.Context) {
 	id := c.Param("id")
@@ -175,7 +457,7 @@ func (s *Server) SignTransaction(c *gin.Context) {
 	}
 
 	// Get device
-	device, err := s.repository.Get(id)
+	device, err := s.repository.Get(c.Request.Context(), id)
 	if err != nil {
 		if err == persistence.ErrDeviceNotFound {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -189,58 +471,43 @@ func (s *Server) SignTransaction(c *gin.Context) {
 		return
 	}
 
-	// Build secured data to sign
-	securedData := device.GetSecuredDataToSign(req.Data)
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get " + string(device.Algorithm) + " algorithm: " + err.Error()},
+		})
+		return
+	}
 
-	// Create appropriate signer
-	var signer crypto.Signer
-	if device.Algorithm == domain.AlgorithmRSA {
-		privateKey, keyErr := device.GetRSAPrivateKey()
-		if keyErr != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Errors: []string{"Failed to get RSA private key: " + keyErr.Error()},
+	// Sign the data with the current key, record the signing event for
+	// auditing and advance the counter atomically
+	record, err := device.SignWithCurrentKey(algorithm.NewSigner, req.Data, s.journalFunc(c.Request.Context()))
+	if err != nil {
+		if errors.Is(err, domain.ErrDeviceSuspended) || errors.Is(err, domain.ErrDeviceRetired) || errors.Is(err, domain.ErrDeviceDeleted) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{"Device cannot sign: " + err.Error()},
 			})
 			return
 		}
-		signer = crypto.NewRSASigner(privateKey)
-	} else {
-		privateKey, keyErr := device.GetECDSAPrivateKey()
-		if keyErr != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Errors: []string{"Failed to get ECDSA private key: " + keyErr.Error()},
+		if errors.Is(err, domain.ErrReservedData) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{"Invalid data: " + err.Error()},
 			})
 			return
 		}
-		signer = crypto.NewECDSASigner(privateKey)
-	}
-
-	// Sign the data
-	signature, err := signer.Sign([]byte(securedData))
-	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Errors: []string{"Failed to sign data: " + err.Error()},
 		})
 		return
 	}
 
-	// Encode signature to base64
-	signatureBase64 := base64.StdEncoding.EncodeToString(signature)
-
-	// Update device with new signature and increment counter
-	device.IncrementCounter(signatureBase64)
-
 	// Persist updated device
-	if err = s.repository.Update(device); err != nil {
+	if err = s.repository.Update(c.Request.Context(), device); err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Errors: []string{"Failed to update device: " + err.Error()},
 		})
 		return
 	}
 
-	response := domain.SignatureResponse{
-		Signature:  signatureBase64,
-		SignedData: securedData,
-	}
-
-	c.JSON(http.StatusOK, Response{Data: response})
+	c.JSON(http.StatusOK, Response{Data: record.Response()})
 }
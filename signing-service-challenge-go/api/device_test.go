@@ -2,10 +2,17 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
@@ -19,9 +26,13 @@ func setupTestServer() *Server {
 
 func TestCreateDevice(t *testing.T) {
 	tests := []struct {
-		name           string
-		requestBody    interface{}
-		expectedStatus int
+		name            string
+		requestBody     interface{}
+		expectedStatus  int
+		expectedKeySize int
+		expectedCurve   string
+		expectedScheme  string
+		expectedTags    map[string]string
 	}{
 		{
 			name: "success - create RSA device",
@@ -29,7 +40,45 @@ func TestCreateDevice(t *testing.T) {
 				Algorithm: domain.AlgorithmRSA,
 				Label:     "Test Device",
 			},
-			expectedStatus: http.StatusCreated,
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: crypto.DefaultRSAKeySize,
+			expectedScheme:  crypto.RSASchemePSS,
+		},
+		{
+			name: "success - create RSA PKCS#1 v1.5 device",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmRSA,
+				RSAScheme: crypto.RSASchemePKCS1v15,
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: crypto.DefaultRSAKeySize,
+			expectedScheme:  crypto.RSASchemePKCS1v15,
+		},
+		{
+			name: "error - unsupported RSA scheme",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmRSA,
+				RSAScheme: "OAEP",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - RSA scheme for ECDSA",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmECDSA,
+				RSAScheme: crypto.RSASchemePSS,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "success - create RSA device with key size",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmRSA,
+				KeySize:   3072,
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 3072,
+			expectedScheme:  crypto.RSASchemePSS,
 		},
 		{
 			name: "success - create ECDSA device",
@@ -37,7 +86,133 @@ func TestCreateDevice(t *testing.T) {
 				Algorithm: domain.AlgorithmECDSA,
 				Label:     "ECDSA Device",
 			},
-			expectedStatus: http.StatusCreated,
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 384,
+			expectedCurve:   crypto.DefaultCurve,
+		},
+		{
+			name: "success - create ECDSA P-256 device",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmECDSA,
+				Curve:     crypto.CurveP256,
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 256,
+			expectedCurve:   crypto.CurveP256,
+		},
+		{
+			name: "success - create ECDSA P-521 device",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmECDSA,
+				Curve:     crypto.CurveP521,
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 521,
+			expectedCurve:   crypto.CurveP521,
+		},
+		{
+			name: "success - create deterministic ECDSA device",
+			requestBody: CreateDeviceRequest{
+				Algorithm:     domain.AlgorithmECDSA,
+				Curve:         crypto.CurveP256,
+				Deterministic: true,
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 256,
+			expectedCurve:   crypto.CurveP256,
+		},
+		{
+			name: "success - create ECDSA device with raw signatures",
+			requestBody: CreateDeviceRequest{
+				Algorithm:         domain.AlgorithmECDSA,
+				SignatureEncoding: crypto.SignatureEncodingRaw,
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 384,
+			expectedCurve:   crypto.DefaultCurve,
+		},
+		{
+			name: "error - unsupported signature encoding",
+			requestBody: CreateDeviceRequest{
+				Algorithm:         domain.AlgorithmECDSA,
+				SignatureEncoding: "PEM",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - deterministic RSA device",
+			requestBody: CreateDeviceRequest{
+				Algorithm:     domain.AlgorithmRSA,
+				Deterministic: true,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - unsupported curve",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmECDSA,
+				Curve:     "P-224",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - curve for RSA",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmRSA,
+				Curve:     crypto.CurveP256,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "success - create Ed25519 device",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmEd25519,
+				Label:     "Ed25519 Device",
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 256,
+		},
+		{
+			name: "error - curve for Ed25519",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmEd25519,
+				Curve:     crypto.CurveP256,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - weak RSA key size",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmRSA,
+				KeySize:   1024,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - key size for ECDSA",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmECDSA,
+				KeySize:   2048,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "success - create device with metadata",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmEd25519,
+				Metadata:  map[string]string{"store_id": "42", "register": "7", "tenant": "acme"},
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedKeySize: 256,
+			expectedTags:    map[string]string{"store_id": "42", "register": "7", "tenant": "acme"},
+		},
+		{
+			name: "error - invalid metadata key",
+			requestBody: CreateDeviceRequest{
+				Algorithm: domain.AlgorithmEd25519,
+				Metadata:  map[string]string{"store id": "42"},
+			},
+			expectedStatus: http.StatusBadRequest,
 		},
 		{
 			name:           "error - invalid algorithm",
@@ -66,7 +241,31 @@ func TestCreateDevice(t *testing.T) {
 			server.CreateDevice(c)
 
 			if w.Code != tt.expectedStatus {
-				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var response struct {
+				Data CreateDeviceResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if response.Data.KeySize != tt.expectedKeySize {
+				t.Errorf("expected key size %d, got %d", tt.expectedKeySize, response.Data.KeySize)
+			}
+			if response.Data.Curve != tt.expectedCurve {
+				t.Errorf("expected curve %q, got %q", tt.expectedCurve, response.Data.Curve)
+			}
+			if response.Data.RSAScheme != tt.expectedScheme {
+				t.Errorf("expected RSA scheme %q, got %q", tt.expectedScheme, response.Data.RSAScheme)
+			}
+			if !reflect.DeepEqual(response.Data.Metadata, tt.expectedTags) {
+				t.Errorf("expected metadata %v, got %v", tt.expectedTags, response.Data.Metadata)
+			}
+			if response.Data.CreatedAt == nil || response.Data.UpdatedAt == nil || response.Data.LastSignedAt != nil {
+				t.Errorf("expected created and updated at but no last signed at, got %v, %v, %v",
+					response.Data.CreatedAt, response.Data.UpdatedAt, response.Data.LastSignedAt)
 			}
 		})
 	}
@@ -88,8 +287,8 @@ func TestListDevices(t *testing.T) {
 		{
 			name: "success - list with devices",
 			setup: func(s *Server) {
-				s.repository.Create(domain.NewDevice("dev-1", domain.AlgorithmRSA, "Device 1", nil, nil))
-				s.repository.Create(domain.NewDevice("dev-2", domain.AlgorithmECDSA, "Device 2", nil, nil))
+				s.repository.Create(context.Background(), domain.NewDevice("dev-1", domain.AlgorithmRSA, "Device 1", nil, nil))
+				s.repository.Create(context.Background(), domain.NewDevice("dev-2", domain.AlgorithmECDSA, "Device 2", nil, nil))
 			},
 			expectedStatus: http.StatusOK,
 			expectedCount:  2,
@@ -113,14 +312,119 @@ func TestListDevices(t *testing.T) {
 				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
 			}
 
-			var response Response
+			var response struct {
+				Data ListDevicesResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if len(response.Data.Devices) != tt.expectedCount {
+				t.Errorf("expected %d devices, got %d", tt.expectedCount, len(response.Data.Devices))
+			}
+		})
+	}
+}
+
+func TestListDevices_Query(t *testing.T) {
+	server := setupTestServer()
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"dev-3", "dev-1", "dev-4", "dev-2"} {
+		createTestDevice(t, server, id).CreatedAt = created.Add(time.Duration(i) * time.Hour)
+	}
+	store := "berlin"
+	device := mustGetDevice(t, server, "dev-2")
+	if err := device.UpdateDetails(nil, map[string]*string{"store": &store}); err != nil {
+		t.Fatalf("failed to set metadata: %v", err)
+	}
+	if w := transitionTestDevice(server, "dev-3", "suspend"); w.Code != http.StatusOK {
+		t.Fatalf("failed to suspend device: %s", w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []string
+		expectedNext   bool
+	}{
+		{name: "success - ordered by ID", query: "", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-1", "dev-2", "dev-3", "dev-4"}},
+		{name: "success - descending", query: "?sort=-id", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-4", "dev-3", "dev-2", "dev-1"}},
+		{name: "success - first page", query: "?limit=3", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-1", "dev-2", "dev-3"}, expectedNext: true},
+		{name: "success - by status", query: "?status=SUSPENDED", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-3"}},
+		{name: "success - by metadata", query: "?metadata=store:berlin", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-2"}},
+		{name: "success - by algorithm and label", query: "?algorithm=ECDSA&label=Te", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-1", "dev-2", "dev-3", "dev-4"}},
+		{name: "success - no match", query: "?algorithm=RSA", expectedStatus: http.StatusOK, expectedIDs: []string{}},
+		{name: "success - oldest first", query: "?sort=created_at", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-3", "dev-1", "dev-4", "dev-2"}},
+		{name: "success - newest first", query: "?sort=-created_at&limit=2", expectedStatus: http.StatusOK, expectedIDs: []string{"dev-2", "dev-4"}, expectedNext: true},
+		{
+			name:           "success - created range",
+			query:          "?created_from=2026-01-01T01:00:00Z&created_before=2026-01-01T03:00:00Z",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"dev-1", "dev-4"},
+		},
+		{name: "error - malformed created_from", query: "?created_from=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "error - limit too large", query: "?limit=501", expectedStatus: http.StatusBadRequest},
+		{name: "error - unknown status", query: "?status=BROKEN", expectedStatus: http.StatusBadRequest},
+		{name: "error - unknown sort", query: "?sort=label", expectedStatus: http.StatusBadRequest},
+		{name: "error - malformed metadata filter", query: "?metadata=store", expectedStatus: http.StatusBadRequest},
+		{name: "error - invalid cursor", query: "?cursor=not-a-cursor", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := listTestDevices(server, tt.query)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data ListDevicesResponse `json:"data"`
+			}
 			json.Unmarshal(w.Body.Bytes(), &response)
-			devices := response.Data.([]interface{})
-			if len(devices) != tt.expectedCount {
-				t.Errorf("expected %d devices, got %d", tt.expectedCount, len(devices))
+			ids := []string{}
+			for _, device := range response.Data.Devices {
+				ids = append(ids, device.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.expectedIDs) {
+				t.Errorf("expected devices %v, got %v", tt.expectedIDs, ids)
+			}
+			if (response.Data.NextCursor != "") != tt.expectedNext {
+				t.Errorf("expected next cursor %v, got %q", tt.expectedNext, response.Data.NextCursor)
 			}
 		})
 	}
+
+	t.Run("success - next page", func(t *testing.T) {
+		var first struct {
+			Data ListDevicesResponse `json:"data"`
+		}
+		json.Unmarshal(listTestDevices(server, "?limit=3").Body.Bytes(), &first)
+
+		w := listTestDevices(server, "?limit=3&cursor="+first.Data.NextCursor)
+		var second struct {
+			Data ListDevicesResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &second)
+		if len(second.Data.Devices) != 1 || second.Data.Devices[0].ID != "dev-4" {
+			t.Errorf("expected only dev-4 on the second page, got %+v", second.Data.Devices)
+		}
+		if second.Data.NextCursor != "" {
+			t.Errorf("expected no next cursor, got %q", second.Data.NextCursor)
+		}
+	})
+}
+
+// listTestDevices calls the ListDevices handler with the given query string.
+func listTestDevices(s *Server, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/devices"+query, nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	s.ListDevices(c)
+	return w
 }
 
 func TestGetDevice(t *testing.T) {
@@ -134,7 +438,7 @@ func TestGetDevice(t *testing.T) {
 			name:     "success - get existing device",
 			deviceID: "existing-device",
 			setup: func(s *Server) {
-				s.repository.Create(domain.NewDevice("existing-device", domain.AlgorithmRSA, "Test", nil, nil))
+				s.repository.Create(context.Background(), domain.NewDevice("existing-device", domain.AlgorithmRSA, "Test", nil, nil))
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -167,6 +471,237 @@ func TestGetDevice(t *testing.T) {
 	}
 }
 
+func TestDeviceTimestamps(t *testing.T) {
+	server := setupTestServer()
+	device := createTestDevice(t, server, "device-1")
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	device.CreatedAt, device.UpdatedAt = past, past
+
+	got := getTestDevice(t, server, "device-1")
+	if got.CreatedAt == nil || !got.CreatedAt.Equal(past) || got.LastSignedAt != nil {
+		t.Fatalf("expected created at %v and no last signed at, got %v and %v", past, got.CreatedAt, got.LastSignedAt)
+	}
+
+	signTestTransaction(t, server, "device-1", "data")
+	got = getTestDevice(t, server, "device-1")
+	if got.LastSignedAt == nil || !got.LastSignedAt.After(past) {
+		t.Errorf("expected last signed at after signing, got %v", got.LastSignedAt)
+	}
+	if !got.UpdatedAt.Equal(past) {
+		t.Errorf("expected signing to leave updated at %v, got %v", past, got.UpdatedAt)
+	}
+
+	if w := transitionTestDevice(server, "device-1", "suspend"); w.Code != http.StatusOK {
+		t.Fatalf("failed to suspend device: %s", w.Body.String())
+	}
+	got = getTestDevice(t, server, "device-1")
+	if !got.UpdatedAt.After(past) {
+		t.Errorf("expected updated at after suspending, got %v", got.UpdatedAt)
+	}
+	if !got.CreatedAt.Equal(past) {
+		t.Errorf("expected created at to stay %v, got %v", past, got.CreatedAt)
+	}
+}
+
+func TestUpdateDevice(t *testing.T) {
+	tests := []struct {
+		name             string
+		deviceID         string
+		status           domain.DeviceStatus
+		body             string
+		expectedStatus   int
+		expectedLabel    string
+		expectedMetadata map[string]string
+	}{
+		{
+			name:             "success - label",
+			deviceID:         "device",
+			body:             `{"label":"register 7"}`,
+			expectedStatus:   http.StatusOK,
+			expectedLabel:    "register 7",
+			expectedMetadata: map[string]string{"store_id": "1", "tenant": "acme"},
+		},
+		{
+			name:             "success - metadata merge",
+			deviceID:         "device",
+			body:             `{"metadata":{"store_id":"2","tenant":null,"register":"7"}}`,
+			expectedStatus:   http.StatusOK,
+			expectedLabel:    "Test",
+			expectedMetadata: map[string]string{"store_id": "2", "register": "7"},
+		},
+		{
+			name:             "success - retired device",
+			deviceID:         "device",
+			status:           domain.StatusRetired,
+			body:             `{"label":"archived"}`,
+			expectedStatus:   http.StatusOK,
+			expectedLabel:    "archived",
+			expectedMetadata: map[string]string{"store_id": "1", "tenant": "acme"},
+		},
+		{
+			name:             "error - algorithm cannot change",
+			deviceID:         "device",
+			body:             `{"label":"register 7","algorithm":"RSA"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedLabel:    "Test",
+			expectedMetadata: map[string]string{"store_id": "1", "tenant": "acme"},
+		},
+		{
+			name:             "error - invalid metadata key",
+			deviceID:         "device",
+			body:             `{"metadata":{"store id":"2"}}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedLabel:    "Test",
+			expectedMetadata: map[string]string{"store_id": "1", "tenant": "acme"},
+		},
+		{
+			name:             "error - invalid JSON",
+			deviceID:         "device",
+			body:             `{"label":`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedLabel:    "Test",
+			expectedMetadata: map[string]string{"store_id": "1", "tenant": "acme"},
+		},
+		{
+			name:             "error - deleted device",
+			deviceID:         "device",
+			status:           domain.StatusDeleted,
+			body:             `{"label":"register 7"}`,
+			expectedStatus:   http.StatusConflict,
+			expectedLabel:    "Test",
+			expectedMetadata: map[string]string{"store_id": "1", "tenant": "acme"},
+		},
+		{
+			name:           "error - device not found",
+			deviceID:       "non-existent",
+			body:           `{"label":"register 7"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			device := createTestDevice(t, server, "device")
+			device.Metadata = map[string]string{"store_id": "1", "tenant": "acme"}
+			device.Status = tt.status
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/v0/devices/"+tt.deviceID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.deviceID}}
+
+			server.UpdateDevice(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.deviceID != "device" {
+				return
+			}
+			got := getTestDevice(t, server, "device")
+			if got.Label != tt.expectedLabel {
+				t.Errorf("expected label %q, got %q", tt.expectedLabel, got.Label)
+			}
+			if !reflect.DeepEqual(got.Metadata, tt.expectedMetadata) {
+				t.Errorf("expected metadata %v, got %v", tt.expectedMetadata, got.Metadata)
+			}
+			if got.Algorithm != domain.AlgorithmECDSA {
+				t.Errorf("expected algorithm %q, got %q", domain.AlgorithmECDSA, got.Algorithm)
+			}
+		})
+	}
+}
+
+func TestDeleteDevice(t *testing.T) {
+	tests := []struct {
+		name             string
+		transitions      []string
+		wantDecommission bool
+		expectedCounter  int
+	}{
+		{name: "success - active device is sealed", wantDecommission: true, expectedCounter: 2},
+		{name: "success - suspended device is sealed", transitions: []string{"suspend"}, wantDecommission: true, expectedCounter: 2},
+		{name: "success - retired device is already sealed", transitions: []string{"retire"}, expectedCounter: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			createTestDevice(t, server, "device")
+			signTestTransaction(t, server, "device", "data")
+			for _, transition := range tt.transitions {
+				transitionTestDevice(server, "device", transition)
+			}
+
+			w := deleteTestDevice(server, "device")
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			var deleted struct {
+				Data DeleteDeviceResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &deleted)
+
+			if deleted.Data.Device.Status != domain.StatusDeleted || deleted.Data.Device.SignatureCounter != tt.expectedCounter {
+				t.Errorf("expected deleted device at counter %d, got %q at %d", tt.expectedCounter, deleted.Data.Device.Status, deleted.Data.Device.SignatureCounter)
+			}
+			if (deleted.Data.Decommission != nil) != tt.wantDecommission {
+				t.Errorf("expected decommission record: %t, got %+v", tt.wantDecommission, deleted.Data.Decommission)
+			}
+			if device := mustGetDevice(t, server, "device"); device.PrivateKey != nil {
+				t.Error("expected the private key to be dropped")
+			}
+
+			// Public key and journal stay available for audits
+			if chain := verifyTestChain(server, "device", nil); !chain.Valid || chain.Verified != 2 {
+				t.Errorf("expected a valid journal of 2 records, got %+v", chain)
+			}
+			if got := getTestDevice(t, server, "device"); got.KeySize != 384 {
+				t.Errorf("expected key size 384 from the public key, got %d", got.KeySize)
+			}
+
+			// Nothing can use the device anymore
+			if w := signTestData(server, "device", "data"); w.Code != http.StatusConflict {
+				t.Errorf("expected status %d when signing, got %d", http.StatusConflict, w.Code)
+			}
+			if w := rotateTestKey(server, "device"); w.Code != http.StatusConflict {
+				t.Errorf("expected status %d when rotating, got %d", http.StatusConflict, w.Code)
+			}
+			if w := deleteTestDevice(server, "device"); w.Code != http.StatusConflict {
+				t.Errorf("expected status %d when deleting again, got %d", http.StatusConflict, w.Code)
+			}
+			if w := exportTestDevice(server, "device", ExportDeviceRequest{Password: "correct horse"}); w.Code != http.StatusConflict {
+				t.Errorf("expected status %d when exporting, got %d", http.StatusConflict, w.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteDevice_NotFound(t *testing.T) {
+	server := setupTestServer()
+
+	if w := deleteTestDevice(server, "non-existent"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// deleteTestDevice calls the DeleteDevice handler.
+func deleteTestDevice(s *Server, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/api/v0/devices/"+id, nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.DeleteDevice(c)
+	return w
+}
+
 func TestSignTransaction(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -184,7 +719,7 @@ func TestSignTransaction(t *testing.T) {
 			setup: func(s *Server) {
 				gen := &crypto.RSAGenerator{}
 				kp, _ := gen.Generate()
-				s.repository.Create(domain.NewDevice("rsa-device", domain.AlgorithmRSA, "RSA", kp.Public, kp.Private))
+				s.repository.Create(context.Background(), domain.NewDevice("rsa-device", domain.AlgorithmRSA, "RSA", kp.Public, kp.Private))
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -228,3 +763,126 @@ func TestSignTransaction(t *testing.T) {
 		})
 	}
 }
+
+func TestSignTransaction_ConcurrentRequests(t *testing.T) {
+	server := setupTestServer()
+
+	gen := &crypto.ECCGenerator{}
+	kp, _ := gen.Generate()
+	server.repository.Create(context.Background(), domain.NewDevice("stress-device", domain.AlgorithmECDSA, "Stress", kp.Public, kp.Private))
+
+	var wg sync.WaitGroup
+	requests := 300
+	responses := make([]domain.SignatureResponse, requests)
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			body, _ := json.Marshal(SignTransactionRequest{Data: "transaction"})
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/stress-device/sign", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: "stress-device"}}
+
+			server.SignTransaction(c)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+				return
+			}
+
+			var response struct {
+				Data domain.SignatureResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Errorf("failed to unmarshal response: %v", err)
+				return
+			}
+			responses[index] = response.Data
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Index every signature by the counter it was created with
+	signatures := make(map[int]string, requests)
+	lastSignatures := make(map[int]string, requests)
+	for _, response := range responses {
+		parts := strings.Split(response.SignedData, "_")
+		if len(parts) != 3 {
+			t.Fatalf("unexpected signed data format %q", response.SignedData)
+		}
+		counter, err := strconv.Atoi(parts[0])
+		if err != nil {
+			t.Fatalf("failed to parse counter from %q: %v", response.SignedData, err)
+		}
+		if _, duplicate := signatures[counter]; duplicate {
+			t.Fatalf("counter %d was signed more than once", counter)
+		}
+		signatures[counter] = response.Signature
+		lastSignatures[counter] = parts[2]
+	}
+
+	for counter := 0; counter < requests; counter++ {
+		if _, ok := signatures[counter]; !ok {
+			t.Fatalf("counter %d is missing (gap in the chain)", counter)
+		}
+
+		expectedLast := base64.StdEncoding.EncodeToString([]byte("stress-device"))
+		if counter > 0 {
+			expectedLast = signatures[counter-1]
+		}
+		if lastSignatures[counter] != expectedLast {
+			t.Errorf("counter %d does not chain onto the previous signature", counter)
+		}
+	}
+
+	device, _ := server.repository.Get(context.Background(), "stress-device")
+	if device.SignatureCounter != requests {
+		t.Errorf("expected counter %d, got %d", requests, device.SignatureCounter)
+	}
+}
+
+// renamedAlgorithm registers an existing algorithm under another name, standing
+// in for an algorithm added after the handlers were written.
+type renamedAlgorithm struct {
+	crypto.Algorithm
+	name string
+}
+
+func (a renamedAlgorithm) Name() string {
+	return a.name
+}
+
+func TestCreateDevice_RegisteredAlgorithm(t *testing.T) {
+	ed25519Algorithm, _ := crypto.Lookup(crypto.AlgorithmNameEd25519)
+	if err := crypto.Register(renamedAlgorithm{Algorithm: ed25519Algorithm, name: "TEST-ALGORITHM"}); err != nil {
+		t.Fatalf("failed to register algorithm: %v", err)
+	}
+
+	server := setupTestServer()
+
+	body, _ := json.Marshal(CreateDeviceRequest{ID: "registered-device", Algorithm: "TEST-ALGORITHM"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	server.CreateDevice(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	signed := signTestTransaction(t, server, "registered-device", "transaction")
+	if !strings.HasPrefix(signed.SignedData, "0_transaction_") {
+		t.Errorf("unexpected signed data %q", signed.SignedData)
+	}
+}
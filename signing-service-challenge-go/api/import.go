@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImportDeviceRequest represents the request body for creating a device from
+// existing key material
+type ImportDeviceRequest struct {
+	Algorithm         domain.SignatureAlgorithm `json:"algorithm,omitempty"` // if given, must match the key
+	ID                string                    `json:"id,omitempty"`
+	Label             string                    `json:"label,omitempty"`
+	PrivateKey        string                    `json:"private_key" binding:"required"` // PEM: PKCS#8, PBES2 encrypted PKCS#8, PKCS#1 or SEC 1
+	Password          string                    `json:"password,omitempty"`             // decrypts an ENCRYPTED PRIVATE KEY
+	RSAScheme         string                    `json:"rsa_scheme,omitempty"`           // RSA signature scheme, defaults to crypto.DefaultRSAScheme
+	Deterministic     bool                      `json:"deterministic,omitempty"`        // ECDSA only: derive nonces as in RFC 6979
+	SignatureEncoding string                    `json:"signature_encoding,omitempty"`   // ECDSA only: DER (default) or RAW r||s
+	SignatureCounter  int                       `json:"signature_counter,omitempty"`    // counter of the next signature, to continue an existing chain
+	LastSignature     string                    `json:"last_signature,omitempty"`       // base64 encoded last signature of the existing chain
+	Metadata          map[string]string         `json:"metadata,omitempty"`             // tags such as store or register number, see domain.ValidateMetadata
+}
+
+// ImportDevice creates a signature device from an existing private key. The
+// algorithm is detected from the key. A signature counter and last signature
+// may be given so that the chain of the previous provider continues unbroken.
+func (s *Server) ImportDevice(c *gin.Context) {
+	var req ImportDeviceRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid request body: " + err.Error()},
+		})
+		return
+	}
+
+	s.importDevice(c, req, restoredState{})
+}
+
+// restoredState is the device state a restore carries beyond an import.
+type restoredState struct {
+	RetiredKeys  []domain.RetiredKey
+	Status       domain.DeviceStatus // empty for active
+	CreatedAt    time.Time           // zero keeps the time of the restore
+	UpdatedAt    time.Time
+	LastSignedAt time.Time
+}
+
+// importDevice creates a device from the key and chain state in req, with the
+// given restored state, and writes the response.
+func (s *Server) importDevice(c *gin.Context, req ImportDeviceRequest, restored restoredState) {
+	// Validate the chain to continue
+	if req.SignatureCounter < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Signature counter must not be negative"},
+		})
+		return
+	}
+	if req.SignatureCounter > 0 && req.LastSignature == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"A last signature is required to continue a chain at counter " + strconv.Itoa(req.SignatureCounter)},
+		})
+		return
+	}
+	if req.SignatureCounter == 0 && req.LastSignature != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"A last signature can only be given with a signature counter"},
+		})
+		return
+	}
+	if _, err := base64.StdEncoding.DecodeString(req.LastSignature); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Last signature must be base64 encoded"},
+		})
+		return
+	}
+	if err := domain.ValidateMetadata(req.Metadata); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{err.Error()},
+		})
+		return
+	}
+
+	// Decode the key and detect its algorithm
+	publicKey, privateKey, err := crypto.NewPKCS8Marshaler().UnmarshalWithPassword([]byte(req.PrivateKey), []byte(req.Password))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid private key: " + err.Error()},
+		})
+		return
+	}
+	if err := crypto.ValidateKeyStrength(privateKey); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Private key rejected: " + err.Error()},
+		})
+		return
+	}
+	name, err := crypto.KeyAlgorithm(privateKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid private key: " + err.Error()},
+		})
+		return
+	}
+	if req.Algorithm != "" && req.Algorithm != domain.SignatureAlgorithm(name) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Private key is a " + name + " key, not " + string(req.Algorithm)},
+		})
+		return
+	}
+	algorithm, err := crypto.Lookup(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Unsupported algorithm: " + err.Error()},
+		})
+		return
+	}
+
+	keyOptions := withKeyOptionDefaults(domain.SignatureAlgorithm(name), crypto.KeyOptions{
+		Scheme:        req.RSAScheme,
+		Deterministic: req.Deterministic,
+		Encoding:      req.SignatureEncoding,
+	})
+	if err := algorithm.ValidateOptions(keyOptions); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid key options: " + err.Error()},
+		})
+		return
+	}
+
+	// Generate ID if not provided
+	deviceID := req.ID
+	if deviceID == "" {
+		deviceID = uuid.New().String()
+	}
+
+	// Create device, continuing the existing chain
+	device := domain.NewDevice(deviceID, domain.SignatureAlgorithm(name), req.Label, publicKey, privateKey)
+	device.SetKeyOptions(keyOptions)
+	device.SignatureCounter = req.SignatureCounter
+	device.LastSignature = req.LastSignature
+	if len(req.Metadata) > 0 {
+		device.Metadata = req.Metadata
+	}
+	device.RetiredKeys = restored.RetiredKeys
+	if restored.Status != "" {
+		device.Status = restored.Status
+	}
+	if !restored.CreatedAt.IsZero() {
+		device.CreatedAt = restored.CreatedAt
+		device.UpdatedAt = restored.UpdatedAt
+	}
+	device.LastSignedAt = restored.LastSignedAt
+
+	// Store device
+	if err = s.repository.Create(c.Request.Context(), device); err != nil {
+		if errors.Is(err, persistence.ErrDeviceAlreadyExists) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{"Device with this ID already exists"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to store device: " + err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{Data: newDeviceResponse(device)})
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func TestImportDevice(t *testing.T) {
+	eccKeyPair, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECC key pair: %v", err)
+	}
+	_, ecdsaPEM, err := crypto.NewPKCS8Marshaler().Marshal(eccKeyPair.Private)
+	if err != nil {
+		t.Fatalf("failed to marshal ECC key: %v", err)
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	weakPEM := pem.EncodeToMemory(&pem.Block{Type: crypto.PEMTypeRSAPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(weakKey)})
+	encryptedPEM, err := os.ReadFile("../crypto/testdata/ecdsa-p256.encrypted.pem")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	rsaPEM, err := os.ReadFile("../crypto/testdata/rsa-2048.pem")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	lastSignature := base64.StdEncoding.EncodeToString([]byte("signature of the previous provider"))
+
+	tests := []struct {
+		name              string
+		requestBody       interface{}
+		expectedStatus    int
+		expectedAlgorithm domain.SignatureAlgorithm
+		expectedKeySize   int
+		expectedCounter   int
+	}{
+		{
+			name:              "success - import ECDSA PKCS#8 key",
+			requestBody:       ImportDeviceRequest{PrivateKey: string(ecdsaPEM), Label: "Imported"},
+			expectedStatus:    http.StatusCreated,
+			expectedAlgorithm: domain.AlgorithmECDSA,
+			expectedKeySize:   384,
+		},
+		{
+			name:              "success - import with metadata",
+			requestBody:       ImportDeviceRequest{PrivateKey: string(ecdsaPEM), Metadata: map[string]string{"store_id": "42", "tenant": "acme"}},
+			expectedStatus:    http.StatusCreated,
+			expectedAlgorithm: domain.AlgorithmECDSA,
+			expectedKeySize:   384,
+		},
+		{
+			name:           "error - invalid metadata",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), Metadata: map[string]string{"store id": "42"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:              "success - import encrypted key",
+			requestBody:       ImportDeviceRequest{PrivateKey: string(encryptedPEM), Password: "fiskaly"},
+			expectedStatus:    http.StatusCreated,
+			expectedAlgorithm: domain.AlgorithmECDSA,
+			expectedKeySize:   256,
+		},
+		{
+			name:              "success - import RSA key with PKCS#1 v1.5 scheme",
+			requestBody:       ImportDeviceRequest{PrivateKey: string(rsaPEM), RSAScheme: crypto.RSASchemePKCS1v15},
+			expectedStatus:    http.StatusCreated,
+			expectedAlgorithm: domain.AlgorithmRSA,
+			expectedKeySize:   2048,
+		},
+		{
+			name: "success - continue an existing chain",
+			requestBody: ImportDeviceRequest{
+				PrivateKey:       string(ecdsaPEM),
+				SignatureCounter: 42,
+				LastSignature:    lastSignature,
+			},
+			expectedStatus:    http.StatusCreated,
+			expectedAlgorithm: domain.AlgorithmECDSA,
+			expectedKeySize:   384,
+			expectedCounter:   42,
+		},
+		{
+			name:           "error - wrong password",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(encryptedPEM), Password: "wrong"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - missing password",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(encryptedPEM)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - weak RSA key",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(weakPEM)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - not a key",
+			requestBody:    ImportDeviceRequest{PrivateKey: "not PEM"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - algorithm does not match key",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), Algorithm: domain.AlgorithmRSA},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - RSA scheme for ECDSA key",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), RSAScheme: crypto.RSASchemePSS},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - counter without last signature",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), SignatureCounter: 42},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - last signature without counter",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), LastSignature: lastSignature},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - negative counter",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), SignatureCounter: -1, LastSignature: lastSignature},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - last signature not base64",
+			requestBody:    ImportDeviceRequest{PrivateKey: string(ecdsaPEM), SignatureCounter: 42, LastSignature: "not base64!"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - missing private key",
+			requestBody:    map[string]string{"label": "test"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			w := importTestDevice(server, tt.requestBody)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var response struct {
+				Data CreateDeviceResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if response.Data.Algorithm != tt.expectedAlgorithm {
+				t.Errorf("expected algorithm %q, got %q", tt.expectedAlgorithm, response.Data.Algorithm)
+			}
+			if response.Data.KeySize != tt.expectedKeySize {
+				t.Errorf("expected key size %d, got %d", tt.expectedKeySize, response.Data.KeySize)
+			}
+			if response.Data.SignatureCounter != tt.expectedCounter {
+				t.Errorf("expected signature counter %d, got %d", tt.expectedCounter, response.Data.SignatureCounter)
+			}
+
+			req := tt.requestBody.(ImportDeviceRequest)
+			if !reflect.DeepEqual(response.Data.Metadata, req.Metadata) {
+				t.Errorf("expected metadata %v, got %v", req.Metadata, response.Data.Metadata)
+			}
+
+			// The imported key signs, continuing the seeded chain
+			signed := signTestTransaction(t, server, response.Data.ID, "after import")
+			if req.LastSignature != "" && !strings.HasSuffix(signed.SignedData, "_"+req.LastSignature) {
+				t.Errorf("expected signed data to continue from the seeded last signature, got %q", signed.SignedData)
+			}
+			if !strings.HasPrefix(signed.SignedData, strconv.Itoa(tt.expectedCounter)+"_") {
+				t.Errorf("expected signed data to start with counter %d, got %q", tt.expectedCounter, signed.SignedData)
+			}
+		})
+	}
+}
+
+func TestImportDevice_Conflict(t *testing.T) {
+	server := setupTestServer()
+	existing := createTestDevice(t, server, "existing")
+
+	kp, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	_, privatePEM, err := crypto.NewPKCS8Marshaler().Marshal(kp.Private)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	w := importTestDevice(server, ImportDeviceRequest{ID: "existing", PrivateKey: string(privatePEM)})
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	device, err := server.repository.Get(context.Background(), "existing")
+	if err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+	if !device.PublicKey.(*ecdsa.PublicKey).Equal(existing.PublicKey) {
+		t.Error("expected the existing device's key to be kept")
+	}
+}
+
+// importTestDevice calls the ImportDevice handler with body.
+func importTestDevice(s *Server, body interface{}) *httptest.ResponseRecorder {
+	encoded, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/import", bytes.NewBuffer(encoded))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	s.ImportDevice(c)
+	return w
+}
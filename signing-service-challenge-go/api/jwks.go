@@ -0,0 +1,94 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSMaxAge is how long, in seconds, clients may cache the JWK Set.
+const JWKSMaxAge = 300
+
+// JWKS publishes the public keys of the active devices as a JWK Set,
+// including keys retired by their key rotations so that the signatures they
+// made before stay verifiable. Suspended, retired and deleted devices may not
+// sign, so their keys are left out unless the include_inactive query
+// parameter asks for the keys of every device, for verifiers auditing
+// signatures of devices taken out of service. Each key's kid is its RFC 7638
+// thumbprint, as returned by the public key endpoint. The response carries an
+// ETag so that verifiers can revalidate cheaply.
+func (s *Server) JWKS(c *gin.Context) {
+	opts := persistence.ListOptions{Status: domain.StatusActive}
+	if raw := c.Query("include_inactive"); raw != "" {
+		includeInactive, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{"include_inactive must be a boolean, got " + strconv.Quote(raw)},
+			})
+			return
+		}
+		if includeInactive {
+			opts.Status = ""
+		}
+	}
+
+	page, err := s.repository.List(c.Request.Context(), opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to list devices: " + err.Error()},
+		})
+		return
+	}
+
+	set := crypto.JWKSet{Keys: make([]crypto.JWK, 0, len(page.Devices))}
+	for _, device := range page.Devices {
+		snapshot := device.Snapshot()
+		publicKeys := []interface{}{snapshot.PublicKey}
+		for _, retired := range snapshot.RetiredKeys {
+			publicKeys = append(publicKeys, retired.PublicKey)
+		}
+
+		for _, publicKey := range publicKeys {
+			jwk, err := newKeyJWK(snapshot, publicKey)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{
+					Errors: []string{"Failed to encode public key of device " + device.ID + ": " + err.Error()},
+				})
+				return
+			}
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	// Repositories do not guarantee an order; sorting keeps the ETag stable.
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	body, err := json.Marshal(set)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to encode key set: " + err.Error()},
+		})
+		return
+	}
+	digest := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(digest[:16]) + `"`
+
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(JWKSMaxAge))
+	c.Header("ETag", etag)
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/jwk-set+json", body)
+}
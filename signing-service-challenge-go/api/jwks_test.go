@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+func TestJWKS(t *testing.T) {
+	tests := []struct {
+		name           string
+		deviceIDs      []string
+		ifNoneMatch    func(etag string) string
+		expectedStatus int
+		expectedKeys   int
+	}{
+		{
+			name:           "success - no devices",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   0,
+		},
+		{
+			name:           "success - all active devices",
+			deviceIDs:      []string{"device-a", "device-b", "device-c"},
+			expectedStatus: http.StatusOK,
+			expectedKeys:   3,
+		},
+		{
+			name:      "success - stale ETag",
+			deviceIDs: []string{"device-a"},
+			ifNoneMatch: func(etag string) string {
+				return `"stale"`
+			},
+			expectedStatus: http.StatusOK,
+			expectedKeys:   1,
+		},
+		{
+			name:      "not modified - matching ETag",
+			deviceIDs: []string{"device-a", "device-b"},
+			ifNoneMatch: func(etag string) string {
+				return etag
+			},
+			expectedStatus: http.StatusNotModified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			kids := make(map[string]bool)
+			for _, id := range tt.deviceIDs {
+				device := createTestDevice(t, server, id)
+				jwk, _ := crypto.NewJWK(device.PublicKey)
+				kids[jwk.Kid] = true
+			}
+
+			first := getTestJWKS(server, "", "")
+			etag := first.Header().Get("ETag")
+			if etag == "" {
+				t.Fatal("expected an ETag")
+			}
+
+			w := first
+			if tt.ifNoneMatch != nil {
+				w = getTestJWKS(server, "", tt.ifNoneMatch(etag))
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Header().Get("Cache-Control") == "" {
+				t.Error("expected a Cache-Control header")
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("expected a stable ETag %q, got %q", etag, w.Header().Get("ETag"))
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var set crypto.JWKSet
+			if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+				t.Fatalf("failed to decode key set: %v", err)
+			}
+			if len(set.Keys) != tt.expectedKeys {
+				t.Fatalf("expected %d keys, got %d", tt.expectedKeys, len(set.Keys))
+			}
+			for _, key := range set.Keys {
+				if !kids[key.Kid] {
+					t.Errorf("unexpected kid %q", key.Kid)
+				}
+			}
+		})
+	}
+}
+
+func TestJWKS_DeviceStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{
+			name:           "success - active devices only by default",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"active-device"},
+		},
+		{
+			name:           "success - inactive devices not included",
+			query:          "include_inactive=false",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"active-device"},
+		},
+		{
+			name:           "success - inactive devices included on request",
+			query:          "include_inactive=true",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"active-device", "suspended-device", "retired-device", "deleted-device"},
+		},
+		{
+			name:           "bad request - include_inactive is not a boolean",
+			query:          "include_inactive=all",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			transitions := map[string]func(id string) *httptest.ResponseRecorder{
+				"active-device":    nil,
+				"suspended-device": func(id string) *httptest.ResponseRecorder { return transitionTestDevice(server, id, "suspend") },
+				"retired-device":   func(id string) *httptest.ResponseRecorder { return transitionTestDevice(server, id, "retire") },
+				"deleted-device":   func(id string) *httptest.ResponseRecorder { return deleteTestDevice(server, id) },
+			}
+			kids := make(map[string]string)
+			for id, transition := range transitions {
+				device := createTestDevice(t, server, id)
+				if transition != nil {
+					if w := transition(id); w.Code != http.StatusOK {
+						t.Fatalf("failed to change status of %s: %d %s", id, w.Code, w.Body.String())
+					}
+				}
+				jwk, _ := crypto.NewJWK(device.PublicKey)
+				kids[id] = jwk.Kid
+			}
+
+			w := getTestJWKS(server, tt.query, "")
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var set crypto.JWKSet
+			if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+				t.Fatalf("failed to decode key set: %v", err)
+			}
+			published := make(map[string]bool, len(set.Keys))
+			for _, key := range set.Keys {
+				published[key.Kid] = true
+			}
+			for _, id := range tt.expectedIDs {
+				if !published[kids[id]] {
+					t.Errorf("expected the key of %s to be published", id)
+				}
+			}
+			if len(set.Keys) != len(tt.expectedIDs) {
+				t.Errorf("expected %d keys, got %d", len(tt.expectedIDs), len(set.Keys))
+			}
+		})
+	}
+}
+
+func getTestJWKS(s *Server, query, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json?"+query, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	s.JWKS(c)
+
+	return w
+}
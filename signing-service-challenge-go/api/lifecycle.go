@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// RetireDeviceResponse represents the result of retiring a device
+type RetireDeviceResponse struct {
+	Device       CreateDeviceResponse     `json:"device"`
+	Decommission domain.SignatureResponse `json:"decommission"` // last record of the chain, sealing it
+}
+
+// SuspendDevice takes an active device out of service until it is
+// reactivated. Suspended devices cannot sign.
+func (s *Server) SuspendDevice(c *gin.Context) {
+	s.transitionDevice(c, (*domain.Device).Suspend)
+}
+
+// ReactivateDevice puts a suspended device back into service.
+func (s *Server) ReactivateDevice(c *gin.Context) {
+	s.transitionDevice(c, (*domain.Device).Reactivate)
+}
+
+// transitionDevice applies a lifecycle transition to the device named in the
+// path, persists it and writes the device as the response.
+func (s *Server) transitionDevice(c *gin.Context, transition func(*domain.Device) error) {
+	id := c.Param("id")
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	if err := transition(device); err != nil {
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to change device status: " + err.Error()},
+		})
+		return
+	}
+
+	// Persist updated device
+	if err = s.repository.Update(c.Request.Context(), device); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to update device: " + err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Data: newDeviceResponse(device)})
+}
+
+// RetireDevice permanently takes an active or suspended device out of service.
+// The device signs a final decommission record that seals its chain, which is
+// recorded in the signature journal. Retired devices can neither sign nor be
+// reactivated, but their signatures remain verifiable.
+func (s *Server) RetireDevice(c *gin.Context) {
+	id := c.Param("id")
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get " + string(device.Algorithm) + " algorithm: " + err.Error()},
+		})
+		return
+	}
+
+	// Sign and journal the decommission record and retire the device
+	// atomically
+	record, err := device.Retire(algorithm.NewSigner, s.journalFunc(c.Request.Context()))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to retire device: " + err.Error()},
+		})
+		return
+	}
+
+	// Persist updated device
+	if err = s.repository.Update(c.Request.Context(), device); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to update device: " + err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Data: RetireDeviceResponse{
+		Device:       newDeviceResponse(device),
+		Decommission: record.Response(),
+	}})
+}
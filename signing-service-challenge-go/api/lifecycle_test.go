@@ -0,0 +1,261 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeviceLifecycle(t *testing.T) {
+	tests := []struct {
+		name           string
+		transitions    []string
+		expectedCodes  []int
+		expectedStatus domain.DeviceStatus
+		canSign        bool
+	}{
+		{
+			name:           "success - new device is active",
+			expectedStatus: domain.StatusActive,
+			canSign:        true,
+		},
+		{
+			name:           "success - suspend",
+			transitions:    []string{"suspend"},
+			expectedCodes:  []int{http.StatusOK},
+			expectedStatus: domain.StatusSuspended,
+		},
+		{
+			name:           "success - suspend and reactivate",
+			transitions:    []string{"suspend", "reactivate"},
+			expectedCodes:  []int{http.StatusOK, http.StatusOK},
+			expectedStatus: domain.StatusActive,
+			canSign:        true,
+		},
+		{
+			name:           "success - retire suspended device",
+			transitions:    []string{"suspend", "retire"},
+			expectedCodes:  []int{http.StatusOK, http.StatusOK},
+			expectedStatus: domain.StatusRetired,
+		},
+		{
+			name:           "error - reactivate active device",
+			transitions:    []string{"reactivate"},
+			expectedCodes:  []int{http.StatusConflict},
+			expectedStatus: domain.StatusActive,
+			canSign:        true,
+		},
+		{
+			name:           "error - suspend suspended device",
+			transitions:    []string{"suspend", "suspend"},
+			expectedCodes:  []int{http.StatusOK, http.StatusConflict},
+			expectedStatus: domain.StatusSuspended,
+		},
+		{
+			name:           "error - retired device cannot come back",
+			transitions:    []string{"retire", "reactivate", "suspend", "retire"},
+			expectedCodes:  []int{http.StatusOK, http.StatusConflict, http.StatusConflict, http.StatusConflict},
+			expectedStatus: domain.StatusRetired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			createTestDevice(t, server, "device")
+
+			for i, transition := range tt.transitions {
+				if w := transitionTestDevice(server, "device", transition); w.Code != tt.expectedCodes[i] {
+					t.Fatalf("%s: expected status %d, got %d: %s", transition, tt.expectedCodes[i], w.Code, w.Body.String())
+				}
+			}
+
+			if status := getTestDevice(t, server, "device").Status; status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, status)
+			}
+
+			w := signTestData(server, "device", "data")
+			if tt.canSign && w.Code != http.StatusOK {
+				t.Errorf("expected device to sign, got %d: %s", w.Code, w.Body.String())
+			}
+			if !tt.canSign && w.Code != http.StatusConflict {
+				t.Errorf("expected status %d when signing, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeviceLifecycle_NotFound(t *testing.T) {
+	server := setupTestServer()
+
+	for _, transition := range []string{"suspend", "reactivate", "retire"} {
+		if w := transitionTestDevice(server, "non-existent", transition); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", transition, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestRetireDevice(t *testing.T) {
+	server := setupTestServer()
+	createTestDevice(t, server, "device")
+	signed := []domain.SignatureResponse{signTestTransaction(t, server, "device", "data")}
+
+	w := transitionTestDevice(server, "device", "retire")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var retired struct {
+		Data RetireDeviceResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &retired)
+
+	if retired.Data.Device.Status != domain.StatusRetired || retired.Data.Device.SignatureCounter != 2 {
+		t.Errorf("expected retired device at counter 2, got %q at %d", retired.Data.Device.Status, retired.Data.Device.SignatureCounter)
+	}
+	expected := "1_" + domain.DecommissionData + "_" + signed[0].Signature
+	if retired.Data.Decommission.SignedData != expected {
+		t.Errorf("expected decommission record %q, got %q", expected, retired.Data.Decommission.SignedData)
+	}
+	signed = append(signed, retired.Data.Decommission)
+
+	// The sealed chain, including the journal, stays verifiable
+	if verified := verifyTestSignature(server, "device", retired.Data.Decommission); !verified.Valid {
+		t.Errorf("expected decommission record to verify, got %+v", verified)
+	}
+	if chain := verifyTestChain(server, "device", nil); !chain.Valid || chain.Verified != len(signed) {
+		t.Errorf("expected a valid journal of %d records, got %+v", len(signed), chain)
+	}
+
+	if w := rotateTestKey(server, "device"); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d when rotating, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
+
+func TestSignTransaction_ReservedData(t *testing.T) {
+	server := setupTestServer()
+	createTestDevice(t, server, "device")
+
+	for _, data := range []string{domain.DecommissionData, domain.KeyRotationPrefix + "forged"} {
+		if w := signTestData(server, "device", data); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d: %s", data, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+	if device := getTestDevice(t, server, "device"); device.SignatureCounter != 0 {
+		t.Errorf("expected counter 0, got %d", device.SignatureCounter)
+	}
+}
+
+func TestRestoreDevice_Status(t *testing.T) {
+	source := setupTestServer()
+	createTestDevice(t, source, "device")
+	transitionTestDevice(source, "device", "retire")
+
+	w := exportTestDevice(source, "device", ExportDeviceRequest{Password: "correct horse"})
+	var exported struct {
+		Data DeviceBackup `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &exported)
+	if exported.Data.Status != domain.StatusRetired {
+		t.Fatalf("expected retired status in the backup, got %q", exported.Data.Status)
+	}
+
+	unknown := exported.Data
+	unknown.Status = "DORMANT"
+
+	tests := []struct {
+		name           string
+		backup         DeviceBackup
+		expectedStatus int
+	}{
+		{name: "success - retired device stays retired", backup: exported.Data, expectedStatus: http.StatusCreated},
+		{name: "error - unknown status", backup: unknown, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			body, _ := json.Marshal(RestoreDeviceRequest{Backup: tt.backup, Password: "correct horse"})
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/admin/devices/restore", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			server.RestoreDevice(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			if w := signTestData(server, "device", "data"); w.Code != http.StatusConflict {
+				t.Errorf("expected restored retired device to refuse signing, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// transitionTestDevice calls the handler of a lifecycle transition: suspend,
+// reactivate or retire.
+func transitionTestDevice(s *Server, id, transition string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/"+transition, nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	switch transition {
+	case "suspend":
+		s.SuspendDevice(c)
+	case "reactivate":
+		s.ReactivateDevice(c)
+	case "retire":
+		s.RetireDevice(c)
+	}
+	return w
+}
+
+// signTestData calls the SignTransaction handler without checking the result.
+func signTestData(s *Server, id, data string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(SignTransactionRequest{Data: data})
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/sign", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.SignTransaction(c)
+	return w
+}
+
+// getTestDevice returns the device as served by the GetDevice handler.
+func getTestDevice(t *testing.T, s *Server, id string) CreateDeviceResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+id, nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.GetDevice(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data CreateDeviceResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data
+}
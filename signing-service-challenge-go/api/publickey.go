@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// Media types offered by the public key endpoint
+const (
+	MIMEPEM  = "application/x-pem-file"
+	MIMEDER  = "application/pkix-spki"
+	MIMEJWK  = "application/jwk+json"
+	MIMEJSON = "application/json"
+)
+
+// PublicKeyResponse represents a device's public key in every supported encoding
+type PublicKeyResponse struct {
+	DeviceID  string                    `json:"device_id"`
+	Algorithm domain.SignatureAlgorithm `json:"algorithm"`
+	Curve     string                    `json:"curve,omitempty"`
+	Kid       string                    `json:"kid"`
+	PEM       string                    `json:"pem"`
+	DER       string                    `json:"der"` // base64 encoded SubjectPublicKeyInfo
+	JWK       *crypto.JWK               `json:"jwk"`
+}
+
+// GetPublicKey returns the public key of a device. The format is negotiated
+// through the Accept header: application/x-pem-file for PEM,
+// application/pkix-spki for raw DER, application/jwk+json for a bare JWK and
+// application/json (the default) for all encodings wrapped in the usual
+// response container.
+func (s *Server) GetPublicKey(c *gin.Context) {
+	id := c.Param("id")
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	response, err := newPublicKeyResponse(device)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to encode public key: " + err.Error()},
+		})
+		return
+	}
+
+	switch c.NegotiateFormat(MIMEJSON, MIMEPEM, MIMEDER, MIMEJWK) {
+	case MIMEJSON:
+		c.JSON(http.StatusOK, Response{Data: response})
+	case MIMEPEM:
+		c.Data(http.StatusOK, MIMEPEM, []byte(response.PEM))
+	case MIMEDER:
+		der, _ := base64.StdEncoding.DecodeString(response.DER)
+		c.Data(http.StatusOK, MIMEDER, der)
+	case MIMEJWK:
+		c.Header("Content-Type", MIMEJWK)
+		c.JSON(http.StatusOK, response.JWK)
+	default:
+		c.JSON(http.StatusNotAcceptable, ErrorResponse{
+			Errors: []string{"Supported formats are " + MIMEJSON + ", " + MIMEPEM + ", " + MIMEDER + " and " + MIMEJWK},
+		})
+	}
+}
+
+// newPublicKeyResponse encodes the public key of a device from a snapshot, so
+// a concurrent key rotation cannot mix the fields of two keys
+func newPublicKeyResponse(device *domain.Device) (*PublicKeyResponse, error) {
+	snapshot := device.Snapshot()
+
+	der, err := crypto.MarshalPublicKeyDER(snapshot.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pemBytes, err := crypto.MarshalPublicKeyPEM(snapshot.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := newKeyJWK(snapshot, snapshot.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicKeyResponse{
+		DeviceID:  snapshot.ID,
+		Algorithm: snapshot.Algorithm,
+		Curve:     snapshot.Curve,
+		Kid:       jwk.Kid,
+		PEM:       string(pemBytes),
+		DER:       base64.StdEncoding.EncodeToString(der),
+		JWK:       jwk,
+	}, nil
+}
+
+// newKeyJWK builds the JWK of one of a device's current or retired public
+// keys. RSA keys advertise the JWS algorithm of the device's signature scheme.
+// The device must be a snapshot.
+func newKeyJWK(device *domain.Device, publicKey interface{}) (*crypto.JWK, error) {
+	jwk, err := crypto.NewJWK(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if device.Algorithm == domain.AlgorithmRSA {
+		jwk.Alg = crypto.RSAJWSAlgorithm(device.RSAScheme)
+	}
+	return jwk, nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	stdcrypto "crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetPublicKey(t *testing.T) {
+	tests := []struct {
+		name                string
+		deviceID            string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{
+			name:                "success - JSON by default",
+			deviceID:            "key-device",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MIMEJSON,
+		},
+		{
+			name:                "success - PEM",
+			deviceID:            "key-device",
+			accept:              MIMEPEM,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MIMEPEM,
+		},
+		{
+			name:                "success - DER",
+			deviceID:            "key-device",
+			accept:              MIMEDER,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MIMEDER,
+		},
+		{
+			name:                "success - JWK",
+			deviceID:            "key-device",
+			accept:              "text/html, " + MIMEJWK,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MIMEJWK,
+		},
+		{
+			name:           "error - unsupported format",
+			deviceID:       "key-device",
+			accept:         "text/html",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:           "error - device not found",
+			deviceID:       "non-existent",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			device := createTestDevice(t, server, "key-device")
+			expectedJWK, _ := crypto.NewJWK(device.PublicKey)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+tt.deviceID+"/public-key", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.deviceID}}
+
+			server.GetPublicKey(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.expectedContentType) {
+				t.Errorf("expected content type %q, got %q", tt.expectedContentType, contentType)
+			}
+
+			var der []byte
+			switch tt.expectedContentType {
+			case MIMEJSON:
+				var response struct {
+					Data PublicKeyResponse `json:"data"`
+				}
+				json.Unmarshal(w.Body.Bytes(), &response)
+				if response.Data.Kid != expectedJWK.Kid || response.Data.JWK == nil || response.Data.JWK.Kid != expectedJWK.Kid {
+					t.Errorf("expected kid %q, got %+v", expectedJWK.Kid, response.Data)
+				}
+				der, _ = base64.StdEncoding.DecodeString(response.Data.DER)
+			case MIMEPEM:
+				block, _ := pem.Decode(w.Body.Bytes())
+				if block == nil {
+					t.Fatalf("expected PEM, got %q", w.Body.String())
+				}
+				der = block.Bytes
+			case MIMEDER:
+				der = w.Body.Bytes()
+			case MIMEJWK:
+				var jwk crypto.JWK
+				json.Unmarshal(w.Body.Bytes(), &jwk)
+				if jwk != *expectedJWK {
+					t.Errorf("expected JWK %+v, got %+v", expectedJWK, jwk)
+				}
+				return
+			}
+
+			publicKey, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				t.Fatalf("failed to parse public key: %v", err)
+			}
+			if !device.PublicKey.(interface {
+				Equal(stdcrypto.PublicKey) bool
+			}).Equal(publicKey) {
+				t.Error("exported public key does not match the device key")
+			}
+		})
+	}
+}
+
+func TestGetPublicKey_RSAScheme(t *testing.T) {
+	tests := []struct {
+		scheme      string
+		expectedAlg string
+	}{
+		{scheme: "", expectedAlg: "PS256"},
+		{scheme: crypto.RSASchemePSS, expectedAlg: "PS256"},
+		{scheme: crypto.RSASchemePKCS1v15, expectedAlg: "RS256"},
+	}
+
+	keyPair, err := (&crypto.RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expectedAlg+"/"+tt.scheme, func(t *testing.T) {
+			server := setupTestServer()
+			device := domain.NewDevice("rsa-device", domain.AlgorithmRSA, "", keyPair.Public, keyPair.Private)
+			device.RSAScheme = tt.scheme
+			if err := server.repository.Create(context.Background(), device); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/rsa-device/public-key", nil)
+			req.Header.Set("Accept", MIMEJWK)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: "rsa-device"}}
+
+			server.GetPublicKey(c)
+
+			var jwk crypto.JWK
+			json.Unmarshal(w.Body.Bytes(), &jwk)
+			if jwk.Alg != tt.expectedAlg {
+				t.Errorf("expected alg %q, got %q", tt.expectedAlg, jwk.Alg)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// RotateKeyResponse represents the result of a key rotation
+type RotateKeyResponse struct {
+	Device   CreateDeviceResponse     `json:"device"`
+	Rotation domain.SignatureResponse `json:"rotation"` // record signed with the old key, committing to the new one
+	Kid      string                   `json:"kid"`      // thumbprint of the new public key
+}
+
+// DeviceKeyResponse represents one public key a device has signed with and
+// the range of signature counters it covers
+type DeviceKeyResponse struct {
+	Version      int        `json:"version"`
+	Kid          string     `json:"kid"`
+	PEM          string     `json:"pem"`
+	FirstCounter int        `json:"first_counter"`
+	LastCounter  *int       `json:"last_counter,omitempty"` // omitted for the current key
+	RetiredAt    *time.Time `json:"retired_at,omitempty"`
+	Current      bool       `json:"current"`
+}
+
+// RotateKey replaces the key pair of a device with a new one of the same
+// algorithm and key options. The old key signs a rotation record committing to
+// the new public key as the next link of the chain, which is recorded in the
+// signature journal; the signature counter and chain continue unbroken. The
+// device is persisted before it signs with the new key, and keeps the old key
+// if persisting fails.
+func (s *Server) RotateKey(c *gin.Context) {
+	id := c.Param("id")
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get " + string(device.Algorithm) + " algorithm: " + err.Error()},
+		})
+		return
+	}
+
+	// The new key keeps the size and options of the old one
+	snapshot := device.Snapshot()
+	keyOptions := snapshot.KeyOptions()
+	if snapshot.Algorithm == domain.AlgorithmRSA {
+		keyOptions.Bits = snapshot.KeySize()
+	}
+	publicKey, privateKey, err := algorithm.GenerateKey(keyOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to generate key pair: " + err.Error()},
+		})
+		return
+	}
+
+	// Sign and journal the rotation record with the old key, switch keys and
+	// persist the device before anything signs with the new key; the device
+	// keeps the old key if it cannot be persisted
+	ctx := c.Request.Context()
+	persist := func() error { return s.repository.Update(ctx, device) }
+	record, err := device.RotateKey(algorithm.NewSigner, publicKey, privateKey, s.journalFunc(ctx), persist)
+	if err != nil {
+		if errors.Is(err, domain.ErrDeviceRetired) || errors.Is(err, domain.ErrDeviceDeleted) {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Errors: []string{"Device cannot rotate its key: " + err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to rotate key: " + err.Error()},
+		})
+		return
+	}
+
+	jwk, err := crypto.NewJWK(publicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to encode public key: " + err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Data: RotateKeyResponse{
+		Device:   newDeviceResponse(device),
+		Rotation: record.Response(),
+		Kid:      jwk.Kid,
+	}})
+}
+
+// ListDeviceKeys returns every public key a device has signed with, oldest
+// first, with the range of signature counters each one covers.
+func (s *Server) ListDeviceKeys(c *gin.Context) {
+	id := c.Param("id")
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+	snapshot := device.Snapshot()
+
+	keys := make([]DeviceKeyResponse, 0, len(snapshot.RetiredKeys)+1)
+	for i, retired := range snapshot.RetiredKeys {
+		key, err := newDeviceKeyResponse(snapshot, retired.PublicKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Errors: []string{"Failed to encode public key: " + err.Error()},
+			})
+			return
+		}
+		lastCounter, retiredAt := retired.LastCounter, retired.RetiredAt
+		key.Version = i + 1
+		key.FirstCounter = retired.FirstCounter
+		key.LastCounter = &lastCounter
+		key.RetiredAt = &retiredAt
+		keys = append(keys, key)
+	}
+
+	current, err := newDeviceKeyResponse(snapshot, snapshot.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to encode public key: " + err.Error()},
+		})
+		return
+	}
+	current.Version = snapshot.KeyVersion()
+	current.FirstCounter = snapshot.KeyValidFrom()
+	current.Current = true
+	keys = append(keys, current)
+
+	c.JSON(http.StatusOK, Response{Data: keys})
+}
+
+// newDeviceKeyResponse encodes one of a device's public keys
+func newDeviceKeyResponse(device *domain.Device, publicKey interface{}) (DeviceKeyResponse, error) {
+	pemBytes, err := crypto.MarshalPublicKeyPEM(publicKey)
+	if err != nil {
+		return DeviceKeyResponse{}, err
+	}
+	jwk, err := newKeyJWK(device, publicKey)
+	if err != nil {
+		return DeviceKeyResponse{}, err
+	}
+
+	return DeviceKeyResponse{
+		Kid: jwk.Kid,
+		PEM: string(pemBytes),
+	}, nil
+}
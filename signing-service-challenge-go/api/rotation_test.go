@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRotateKey(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         CreateDeviceRequest
+		expectedKeySize int
+	}{
+		{
+			name:            "success - RSA keeps key size and scheme",
+			request:         CreateDeviceRequest{ID: "rsa-device", Algorithm: domain.AlgorithmRSA, KeySize: 3072, RSAScheme: crypto.RSASchemePKCS1v15},
+			expectedKeySize: 3072,
+		},
+		{
+			name:            "success - ECDSA keeps curve",
+			request:         CreateDeviceRequest{ID: "ecdsa-device", Algorithm: domain.AlgorithmECDSA, Curve: crypto.CurveP256},
+			expectedKeySize: 256,
+		},
+		{
+			name:            "success - Ed25519",
+			request:         CreateDeviceRequest{ID: "ed25519-device", Algorithm: domain.AlgorithmEd25519},
+			expectedKeySize: 256,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			body, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			server.CreateDevice(c)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("failed to create device: %s", w.Body.String())
+			}
+
+			signed := []domain.SignatureResponse{
+				signTestTransaction(t, server, tt.request.ID, "before"),
+				signTestTransaction(t, server, tt.request.ID, "before"),
+			}
+
+			w = rotateTestKey(server, tt.request.ID)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			var rotated struct {
+				Data RotateKeyResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &rotated)
+
+			if rotated.Data.Device.KeyVersion != 2 || rotated.Data.Device.SignatureCounter != 3 {
+				t.Errorf("expected key version 2 at counter 3, got %d at %d", rotated.Data.Device.KeyVersion, rotated.Data.Device.SignatureCounter)
+			}
+			if rotated.Data.Device.KeySize != tt.expectedKeySize {
+				t.Errorf("expected key size %d, got %d", tt.expectedKeySize, rotated.Data.Device.KeySize)
+			}
+			if rotated.Data.Device.RSAScheme != tt.request.RSAScheme {
+				t.Errorf("expected RSA scheme %q, got %q", tt.request.RSAScheme, rotated.Data.Device.RSAScheme)
+			}
+			expected := "2_" + domain.KeyRotationPrefix + rotated.Data.Kid + "_" + signed[1].Signature
+			if rotated.Data.Rotation.SignedData != expected {
+				t.Errorf("expected rotation record %q, got %q", expected, rotated.Data.Rotation.SignedData)
+			}
+			signed = append(signed, rotated.Data.Rotation, signTestTransaction(t, server, tt.request.ID, "after"))
+
+			// Signatures of both keys verify, each against the key of its counter
+			for i, s := range signed {
+				if verified := verifyTestSignature(server, tt.request.ID, s); !verified.Valid {
+					t.Errorf("expected signature %d to verify, got %+v", i, verified)
+				}
+			}
+			if chain := verifyTestChain(server, tt.request.ID, nil); !chain.Valid || chain.Verified != len(signed) {
+				t.Errorf("expected a valid journal of %d records, got %+v", len(signed), chain)
+			}
+			if chain := verifyTestChain(server, tt.request.ID, chainLinks(signed)); !chain.Valid {
+				t.Errorf("expected the given records to form a valid chain, got %+v", chain)
+			}
+
+			// A signature of the new key is not valid at a counter of the old one
+			forged := domain.SignatureResponse{SignedData: "1_before_" + signed[0].Signature, Signature: signed[3].Signature}
+			if verified := verifyTestSignature(server, tt.request.ID, forged); verified.Valid {
+				t.Error("expected a signature of the new key to fail at a counter of the retired key")
+			}
+		})
+	}
+}
+
+// failingRepository fails every Update while fail is set.
+type failingRepository struct {
+	persistence.DeviceRepository
+	fail bool
+}
+
+func (r *failingRepository) Update(ctx context.Context, device *domain.Device) error {
+	if r.fail {
+		return errors.New("storage unavailable")
+	}
+	return r.DeviceRepository.Update(ctx, device)
+}
+
+func TestRotateKey_UpdateFailure(t *testing.T) {
+	repository := &failingRepository{DeviceRepository: persistence.NewInMemoryRepository()}
+	gin.SetMode(gin.TestMode)
+	server := NewServer(":8080", WithRepository(repository))
+	device := createTestDevice(t, server, "rotating-device")
+	oldKey := device.PublicKey
+
+	repository.fail = true
+	if w := rotateTestKey(server, "rotating-device"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if snapshot := device.Snapshot(); snapshot.PublicKey != oldKey || len(snapshot.RetiredKeys) != 0 {
+		t.Fatal("expected the device to keep its old key")
+	}
+
+	// The journaled rotation record stays in the chain and the old key
+	// continues it.
+	repository.fail = false
+	signTestTransaction(t, server, "rotating-device", "after")
+	if chain := verifyTestChain(server, "rotating-device", nil); !chain.Valid || chain.Verified != 2 {
+		t.Errorf("expected a valid journal of 2 records, got %+v", chain)
+	}
+}
+
+func TestRotateKey_NotFound(t *testing.T) {
+	server := setupTestServer()
+
+	if w := rotateTestKey(server, "non-existent"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestListDeviceKeys(t *testing.T) {
+	server := setupTestServer()
+	device := createTestDevice(t, server, "device")
+	originalKid := mustKid(t, device.PublicKey)
+	signTestTransaction(t, server, "device", "before")
+	rotateTestKey(server, "device")
+	rotateTestKey(server, "device")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/device/keys", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "device"}}
+	server.ListDeviceKeys(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var response struct {
+		Data []DeviceKeyResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	expected := []struct {
+		first, last int
+		current     bool
+	}{
+		{first: 0, last: 1},
+		{first: 2, last: 2},
+		{first: 3, current: true},
+	}
+	if len(response.Data) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(response.Data))
+	}
+	for i, key := range response.Data {
+		if key.Version != i+1 || key.FirstCounter != expected[i].first || key.Current != expected[i].current {
+			t.Errorf("expected key %d from counter %d, got %+v", i+1, expected[i].first, key)
+		}
+		if expected[i].current {
+			if key.LastCounter != nil || key.RetiredAt != nil {
+				t.Errorf("expected the current key to have no end, got %+v", key)
+			}
+		} else if key.LastCounter == nil || *key.LastCounter != expected[i].last || key.RetiredAt == nil {
+			t.Errorf("expected key %d to end at counter %d, got %+v", i+1, expected[i].last, key)
+		}
+	}
+	if response.Data[0].Kid != originalKid {
+		t.Errorf("expected the original key first, got kid %q", response.Data[0].Kid)
+	}
+	if current := mustKid(t, device.PublicKey); response.Data[2].Kid != current {
+		t.Errorf("expected the current key last, got kid %q", response.Data[2].Kid)
+	}
+
+	// Retired keys of an active device stay published for offline verifiers
+	var set crypto.JWKSet
+	json.Unmarshal(getTestJWKS(server, "", "").Body.Bytes(), &set)
+	if len(set.Keys) != 3 {
+		t.Errorf("expected 3 keys in the JWK Set, got %d", len(set.Keys))
+	}
+}
+
+// rotateTestKey calls the RotateKey handler.
+func rotateTestKey(s *Server, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/rotate-key", nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.RotateKey(c)
+	return w
+}
+
+// verifyTestSignature calls the VerifySignature handler.
+func verifyTestSignature(s *Server, id string, signed domain.SignatureResponse) VerifySignatureResponse {
+	body, _ := json.Marshal(VerifySignatureRequest{SignedData: signed.SignedData, Signature: signed.Signature})
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/verify", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	s.VerifySignature(c)
+
+	var response struct {
+		Data VerifySignatureResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data
+}
+
+// verifyTestChain calls the VerifyChain handler with the given links, or
+// without a body to verify the journal.
+func verifyTestChain(s *Server, id string, links []domain.ChainLink) VerifyChainResponse {
+	var body []byte
+	if links != nil {
+		body, _ = json.Marshal(VerifyChainRequest{Records: links})
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/verify-chain", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	s.VerifyChain(c)
+
+	var response struct {
+		Data VerifyChainResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data
+}
+
+// mustKid returns the RFC 7638 thumbprint of a public key.
+func mustKid(t *testing.T, publicKey interface{}) string {
+	t.Helper()
+
+	jwk, err := crypto.NewJWK(publicKey)
+	if err != nil {
+		t.Fatalf("failed to encode public key: %v", err)
+	}
+	return jwk.Kid
+}
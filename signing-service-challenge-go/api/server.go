@@ -18,23 +18,60 @@ type ErrorResponse struct {
 // Server manages HTTP requests and dispatches them to the appropriate services.
 type Server struct {
 	listenAddress string
-	repository    *persistence.InMemoryRepository
+	repository    persistence.DeviceRepository
+	journal       persistence.SignatureJournal
+	adminToken    string
 	router        *gin.Engine
 }
 
-// NewServer is a factory to instantiate a new Server.
-func NewServer(listenAddress string) *Server {
-	repository := persistence.NewInMemoryRepository()
+// Option configures optional Server dependencies.
+type Option func(*Server)
+
+// WithRepository sets the storage backend used for signature devices.
+// Without this option the Server keeps its devices in memory.
+func WithRepository(repository persistence.DeviceRepository) Option {
+	return func(s *Server) {
+		s.repository = repository
+	}
+}
+
+// WithSignatureJournal sets the store that records every signing event.
+// Without this option the Server keeps its journal in memory.
+func WithSignatureJournal(journal persistence.SignatureJournal) Option {
+	return func(s *Server) {
+		s.journal = journal
+	}
+}
+
+// WithAdminToken sets the bearer token that authorizes the admin endpoints.
+// Without this option the admin endpoints are disabled.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
 
-	return &Server{
+// NewServer is a factory to instantiate a new Server.
+func NewServer(listenAddress string, opts ...Option) *Server {
+	server := &Server{
 		listenAddress: listenAddress,
-		repository:    repository,
+		repository:    persistence.NewInMemoryRepository(),
+		journal:       persistence.NewInMemorySignatureJournal(),
 		router:        gin.Default(),
 	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	return server
 }
 
 // Run registers all HandlerFuncs for the existing HTTP routes and starts the Server.
 func (s *Server) Run() error {
+	// Key discovery for offline verifiers
+	s.router.GET("/.well-known/jwks.json", s.JWKS)
+
 	v0 := s.router.Group("/api/v0")
 	{
 		// Health endpoint
@@ -42,11 +79,28 @@ func (s *Server) Run() error {
 
 		// Device endpoints
 		v0.POST("/devices", s.CreateDevice)
+		v0.POST("/devices/import", s.ImportDevice)
 		v0.GET("/devices", s.ListDevices)
 		v0.GET("/devices/:id", s.GetDevice)
+		v0.PATCH("/devices/:id", s.UpdateDevice)
+		v0.DELETE("/devices/:id", s.DeleteDevice)
+		v0.GET("/devices/:id/public-key", s.GetPublicKey)
+		v0.GET("/devices/:id/keys", s.ListDeviceKeys)
+		v0.POST("/devices/:id/rotate-key", s.RotateKey)
+		v0.POST("/devices/:id/suspend", s.SuspendDevice)
+		v0.POST("/devices/:id/reactivate", s.ReactivateDevice)
+		v0.POST("/devices/:id/retire", s.RetireDevice)
 
-		// Signature endpoint
+		// Signature endpoints
 		v0.POST("/devices/:id/sign", s.SignTransaction)
+		v0.GET("/devices/:id/signatures", s.ListSignatures)
+		v0.POST("/devices/:id/verify", s.VerifySignature)
+		v0.POST("/devices/:id/verify-chain", s.VerifyChain)
+
+		// Admin endpoints
+		admin := v0.Group("/admin", s.requireAdmin)
+		admin.POST("/devices/:id/export", s.ExportDevice)
+		admin.POST("/devices/restore", s.RestoreDevice)
 	}
 
 	return s.router.Run(s.listenAddress)
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultSignaturePageSize is the page size used when no limit is given.
+	DefaultSignaturePageSize = 50
+	// MaxSignaturePageSize is the largest page size a client may request.
+	MaxSignaturePageSize = 500
+)
+
+// ListSignaturesResponse represents one page of a device's signature journal
+type ListSignaturesResponse struct {
+	Signatures []domain.SignatureRecord `json:"signatures"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// ListSignatures returns the signing events of a device in counter order.
+// Pages are requested with the optional "limit" and "cursor" query parameters.
+func (s *Server) ListSignatures(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := DefaultSignaturePageSize
+	if rawLimit := c.Query("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > MaxSignaturePageSize {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{"Limit must be between 1 and " + strconv.Itoa(MaxSignaturePageSize)},
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	if _, err := s.repository.Get(c.Request.Context(), id); err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	page, err := s.journal.List(c.Request.Context(), id, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, persistence.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Errors: []string{"Invalid cursor"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to list signatures: " + err.Error()},
+		})
+		return
+	}
+
+	response := ListSignaturesResponse{
+		Signatures: page.Records,
+		NextCursor: page.NextCursor,
+	}
+
+	c.JSON(http.StatusOK, Response{Data: response})
+}
+
+// journalFunc returns the domain.JournalFunc that records a request's signing
+// events in the server's signature journal. Devices call it under their lock
+// before committing an event, so the journal never misses a counter the
+// device has moved past.
+func (s *Server) journalFunc(ctx context.Context) domain.JournalFunc {
+	return func(record domain.SignatureRecord) error {
+		return s.journal.Append(ctx, record)
+	}
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// createTestDevice stores an ECDSA device with a real key pair.
+func createTestDevice(t *testing.T, s *Server, id string) *domain.Device {
+	t.Helper()
+
+	gen := &crypto.ECCGenerator{}
+	kp, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	device := domain.NewDevice(id, domain.AlgorithmECDSA, "Test", kp.Public, kp.Private)
+	if err := s.repository.Create(context.Background(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	return device
+}
+
+// signTestTransaction signs data through the SignTransaction handler.
+func signTestTransaction(t *testing.T, s *Server, id, data string) domain.SignatureResponse {
+	t.Helper()
+
+	body, _ := json.Marshal(SignTransactionRequest{Data: data})
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/sign", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.SignTransaction(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data domain.SignatureResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data
+}
+
+func TestListSignatures(t *testing.T) {
+	tests := []struct {
+		name           string
+		deviceID       string
+		query          string
+		expectedStatus int
+		expectedCount  int
+		expectCursor   bool
+	}{
+		{
+			name:           "success - all signatures",
+			deviceID:       "journal-device",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedCount:  5,
+		},
+		{
+			name:           "success - first page",
+			deviceID:       "journal-device",
+			query:          "?limit=2",
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+			expectCursor:   true,
+		},
+		{
+			name:           "error - invalid limit",
+			deviceID:       "journal-device",
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid cursor",
+			deviceID:       "journal-device",
+			query:          "?cursor=%21%21",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - device not found",
+			deviceID:       "non-existent",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			createTestDevice(t, server, "journal-device")
+			for i := 0; i < 5; i++ {
+				signTestTransaction(t, server, "journal-device", "transaction")
+			}
+
+			w := listTestSignatures(server, tt.deviceID, tt.query)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data ListSignaturesResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if len(response.Data.Signatures) != tt.expectedCount {
+				t.Errorf("expected %d signatures, got %d", tt.expectedCount, len(response.Data.Signatures))
+			}
+			if (response.Data.NextCursor != "") != tt.expectCursor {
+				t.Errorf("expected next cursor present=%v, got %q", tt.expectCursor, response.Data.NextCursor)
+			}
+		})
+	}
+}
+
+func TestListSignatures_ReplayChain(t *testing.T) {
+	server := setupTestServer()
+	createTestDevice(t, server, "journal-device")
+
+	var signed []domain.SignatureResponse
+	for i := 0; i < 7; i++ {
+		signed = append(signed, signTestTransaction(t, server, "journal-device", "transaction"))
+	}
+
+	var records []domain.SignatureRecord
+	cursor := ""
+	for {
+		w := listTestSignatures(server, "journal-device", "?limit=3&cursor="+cursor)
+		var response struct {
+			Data ListSignaturesResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		records = append(records, response.Data.Signatures...)
+		if response.Data.NextCursor == "" {
+			break
+		}
+		cursor = response.Data.NextCursor
+	}
+
+	if len(records) != len(signed) {
+		t.Fatalf("expected %d records, got %d", len(signed), len(records))
+	}
+	for i, record := range records {
+		if record.Counter != i {
+			t.Errorf("expected counter %d, got %d", i, record.Counter)
+		}
+		if record.Signature != signed[i].Signature || record.SignedData != signed[i].SignedData {
+			t.Errorf("record %d does not match the signature returned to the client", i)
+		}
+		if record.Data != "transaction" || record.Algorithm != domain.AlgorithmECDSA {
+			t.Errorf("record %d has unexpected data %q or algorithm %q", i, record.Data, record.Algorithm)
+		}
+	}
+}
+
+// failingJournal fails every Append while fail is set.
+type failingJournal struct {
+	persistence.SignatureJournal
+	fail bool
+}
+
+func (j *failingJournal) Append(ctx context.Context, record domain.SignatureRecord) error {
+	if j.fail {
+		return errors.New("journal unavailable")
+	}
+	return j.SignatureJournal.Append(ctx, record)
+}
+
+func TestSignTransaction_JournalFailure(t *testing.T) {
+	journal := &failingJournal{SignatureJournal: persistence.NewInMemorySignatureJournal(), fail: true}
+	gin.SetMode(gin.TestMode)
+	server := NewServer(":8080", WithSignatureJournal(journal))
+	device := createTestDevice(t, server, "journal-device")
+
+	body, _ := json.Marshal(SignTransactionRequest{Data: "transaction"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/journal-device/sign", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "journal-device"}}
+
+	server.SignTransaction(c)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if snapshot := device.Snapshot(); snapshot.SignatureCounter != 0 || snapshot.LastSignature != "" {
+		t.Fatalf("expected device untouched, got counter %d", snapshot.SignatureCounter)
+	}
+
+	// Once the journal recovers, the chain continues at the unjournaled counter
+	journal.fail = false
+	signed := signTestTransaction(t, server, "journal-device", "transaction")
+
+	page, err := journal.List(context.Background(), "journal-device", "", 0)
+	if err != nil {
+		t.Fatalf("failed to list signatures: %v", err)
+	}
+	if len(page.Records) != 1 || page.Records[0].Counter != 0 || page.Records[0].Signature != signed.Signature {
+		t.Errorf("expected the signature journaled at counter 0, got %+v", page.Records)
+	}
+}
+
+func listTestSignatures(s *Server, id, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+id+"/signatures"+query, nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+
+	s.ListSignatures(c)
+
+	return w
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+// VerifySignatureRequest represents the request body for verifying a signature
+type VerifySignatureRequest struct {
+	SignedData string `json:"signed_data" binding:"required"`
+	Signature  string `json:"signature" binding:"required"`
+}
+
+// VerifySignatureResponse represents the result of a signature verification.
+// Counter is omitted if the signed data could not be parsed.
+type VerifySignatureResponse struct {
+	Valid   bool   `json:"valid"`
+	Counter *int   `json:"counter,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// VerifyChainRequest represents the request body for verifying a signature chain.
+// When no records are given, the device's signature journal is verified, one
+// page of at most MaxSignaturePageSize records at a time.
+type VerifyChainRequest struct {
+	Records []domain.ChainLink `json:"records"`
+	Cursor  string             `json:"cursor,omitempty"` // continues a journal verification after its last page
+}
+
+// BrokenLink describes the first record of a chain that failed verification
+type BrokenLink struct {
+	Index   int    `json:"index"`
+	Counter int    `json:"counter"`
+	Reason  string `json:"reason"`
+}
+
+// VerifyChainResponse represents the result of a chain verification
+type VerifyChainResponse struct {
+	Valid      bool        `json:"valid"`
+	Verified   int         `json:"verified"` // number of records verified before the first broken link
+	BrokenLink *BrokenLink `json:"broken_link,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"` // set while journal records remain to be verified
+}
+
+// VerifySignature checks whether a signature returned by the sign endpoint was
+// produced by the device over the given signed data.
+func (s *Server) VerifySignature(c *gin.Context) {
+	id := c.Param("id")
+
+	var req VerifySignatureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid request body: " + err.Error()},
+		})
+		return
+	}
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	// Verify against the key that signed the counter, falling back to the
+	// current key when the counter cannot be parsed
+	snapshot := device.Snapshot()
+	publicKey := snapshot.PublicKey
+	if parsed, err := domain.ParseSecuredData(req.SignedData); err == nil {
+		publicKey = snapshot.PublicKeyFor(parsed.Counter)
+	}
+	verifier, err := newVerifier(snapshot, publicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get public key: " + err.Error()},
+		})
+		return
+	}
+
+	securedData, err := domain.VerifySignature(verifier, domain.ChainLink{
+		SignedData: req.SignedData,
+		Signature:  req.Signature,
+	})
+
+	response := VerifySignatureResponse{Valid: err == nil}
+	if !errors.Is(err, domain.ErrMalformedSecuredData) {
+		response.Counter = &securedData.Counter
+	}
+	if err != nil {
+		response.Reason = err.Error()
+	}
+
+	c.JSON(http.StatusOK, Response{Data: response})
+}
+
+// VerifyChain checks that a sequence of signing records was produced by the
+// device and forms an unbroken chain, reporting the first broken link. Without
+// records the journal is verified page by page: a valid page that is followed
+// by further records returns a cursor, and the next request with that cursor
+// verifies the following page, including its link to the last record of the
+// previous one.
+func (s *Server) VerifyChain(c *gin.Context) {
+	id := c.Param("id")
+
+	var req VerifyChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"Invalid request body: " + err.Error()},
+		})
+		return
+	}
+	if len(req.Records) > 0 && req.Cursor != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Errors: []string{"A cursor only continues the verification of the journal"},
+		})
+		return
+	}
+
+	device, err := s.repository.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == persistence.ErrDeviceNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Errors: []string{"Device not found"},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Errors: []string{"Failed to get device: " + err.Error()},
+		})
+		return
+	}
+
+	links := req.Records
+	// previous is the number of links that precede the verified ones: the
+	// last record of the previous journal page, if any
+	previous := 0
+	var nextCursor string
+	if len(links) == 0 {
+		page, err := s.journalPage(c, id, req.Cursor)
+		if err != nil {
+			if errors.Is(err, persistence.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Errors: []string{"Invalid cursor"},
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Errors: []string{"Failed to list signatures: " + err.Error()},
+			})
+			return
+		}
+		if req.Cursor != "" {
+			previous = 1
+		}
+		nextCursor = page.NextCursor
+		links = make([]domain.ChainLink, len(page.Records))
+		for i, record := range page.Records {
+			links[i] = domain.ChainLink{SignedData: record.SignedData, Signature: record.Signature}
+		}
+	}
+
+	response := VerifyChainResponse{Valid: true, Verified: len(links) - previous, NextCursor: nextCursor}
+
+	// Every link is verified against the key that signed its counter
+	snapshot := device.Snapshot()
+	verifierFor := func(counter int) (crypto.Verifier, error) {
+		return newVerifier(snapshot, snapshot.PublicKeyFor(counter))
+	}
+
+	if err := domain.VerifyChainFunc(device.ID, verifierFor, links); err != nil {
+		var chainErr *domain.ChainError
+		if !errors.As(err, &chainErr) {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Errors: []string{"Failed to verify chain: " + err.Error()},
+			})
+			return
+		}
+		index := max(chainErr.Index-previous, 0)
+		response = VerifyChainResponse{
+			Valid:    false,
+			Verified: index,
+			BrokenLink: &BrokenLink{
+				Index:   index,
+				Counter: chainErr.Counter,
+				Reason:  chainErr.Reason,
+			},
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{Data: response})
+}
+
+// journalPage returns the page of the device's journal that VerifyChain
+// verifies after cursor. For a non-empty cursor the page starts with the
+// record the cursor points at, so that the link to it is verified too.
+func (s *Server) journalPage(c *gin.Context, id, cursor string) (persistence.SignaturePage, error) {
+	after, err := persistence.DecodeSignatureCursor(cursor)
+	if err != nil {
+		return persistence.SignaturePage{}, err
+	}
+	if after < 0 {
+		return s.journal.List(c.Request.Context(), id, "", MaxSignaturePageSize)
+	}
+
+	from := ""
+	if after > 0 {
+		from = persistence.EncodeSignatureCursor(after - 1)
+	}
+	page, err := s.journal.List(c.Request.Context(), id, from, MaxSignaturePageSize+1)
+	if err != nil {
+		return page, err
+	}
+	if len(page.Records) == 0 || page.Records[0].Counter != after {
+		return page, persistence.ErrInvalidCursor
+	}
+	return page, nil
+}
+
+// newVerifier creates the verifier registered for the device's algorithm for
+// one of the device's current or retired public keys
+func newVerifier(device *domain.Device, publicKey interface{}) (crypto.Verifier, error) {
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		return nil, err
+	}
+	return algorithm.NewVerifier(publicKey, device.KeyOptions())
+}
@@ -0,0 +1,442 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifySignature(t *testing.T) {
+	tests := []struct {
+		name            string
+		deviceID        string
+		request         func(signed domain.SignatureResponse) VerifySignatureRequest
+		expectedStatus  int
+		expectedValid   bool
+		expectedCounter *int
+		expectReason    bool
+	}{
+		{
+			name:     "success - valid signature",
+			deviceID: "verify-device",
+			request: func(signed domain.SignatureResponse) VerifySignatureRequest {
+				return VerifySignatureRequest{SignedData: signed.SignedData, Signature: signed.Signature}
+			},
+			expectedStatus:  http.StatusOK,
+			expectedValid:   true,
+			expectedCounter: intPtr(1),
+		},
+		{
+			name:     "invalid - tampered signed data",
+			deviceID: "verify-device",
+			request: func(signed domain.SignatureResponse) VerifySignatureRequest {
+				return VerifySignatureRequest{SignedData: "7" + signed.SignedData[1:], Signature: signed.Signature}
+			},
+			expectedStatus:  http.StatusOK,
+			expectedCounter: intPtr(7),
+			expectReason:    true,
+		},
+		{
+			name:     "invalid - signature not base64",
+			deviceID: "verify-device",
+			request: func(signed domain.SignatureResponse) VerifySignatureRequest {
+				return VerifySignatureRequest{SignedData: signed.SignedData, Signature: "%%%"}
+			},
+			expectedStatus:  http.StatusOK,
+			expectedCounter: intPtr(1),
+			expectReason:    true,
+		},
+		{
+			name:     "invalid - malformed signed data",
+			deviceID: "verify-device",
+			request: func(signed domain.SignatureResponse) VerifySignatureRequest {
+				return VerifySignatureRequest{SignedData: "garbage", Signature: signed.Signature}
+			},
+			expectedStatus: http.StatusOK,
+			expectReason:   true,
+		},
+		{
+			name:     "error - missing signature",
+			deviceID: "verify-device",
+			request: func(signed domain.SignatureResponse) VerifySignatureRequest {
+				return VerifySignatureRequest{SignedData: signed.SignedData}
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "error - device not found",
+			deviceID: "non-existent",
+			request: func(signed domain.SignatureResponse) VerifySignatureRequest {
+				return VerifySignatureRequest{SignedData: signed.SignedData, Signature: signed.Signature}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			createTestDevice(t, server, "verify-device")
+			signTestTransaction(t, server, "verify-device", "first")
+			signed := signTestTransaction(t, server, "verify-device", "second")
+
+			body, _ := json.Marshal(tt.request(signed))
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+tt.deviceID+"/verify", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.deviceID}}
+
+			server.VerifySignature(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data VerifySignatureResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+
+			if response.Data.Valid != tt.expectedValid {
+				t.Errorf("expected valid=%v, got %v", tt.expectedValid, response.Data.Valid)
+			}
+			if (response.Data.Reason != "") != tt.expectReason {
+				t.Errorf("expected reason present=%v, got %q", tt.expectReason, response.Data.Reason)
+			}
+			switch {
+			case tt.expectedCounter == nil && response.Data.Counter != nil:
+				t.Errorf("expected no counter, got %d", *response.Data.Counter)
+			case tt.expectedCounter != nil && response.Data.Counter == nil:
+				t.Errorf("expected counter %d, got none", *tt.expectedCounter)
+			case tt.expectedCounter != nil && *response.Data.Counter != *tt.expectedCounter:
+				t.Errorf("expected counter %d, got %d", *tt.expectedCounter, *response.Data.Counter)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		name           string
+		deviceID       string
+		body           func(signed []domain.SignatureResponse) interface{}
+		expectedStatus int
+		expectedValid  bool
+		expectedBroken *BrokenLink
+	}{
+		{
+			name:           "success - journal of the device",
+			deviceID:       "chain-device",
+			expectedStatus: http.StatusOK,
+			expectedValid:  true,
+		},
+		{
+			name:     "success - given records",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				return VerifyChainRequest{Records: chainLinks(signed[1:])}
+			},
+			expectedStatus: http.StatusOK,
+			expectedValid:  true,
+		},
+		{
+			name:     "broken - record missing from the chain",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				return VerifyChainRequest{Records: chainLinks(append(signed[:1:1], signed[2:]...))}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBroken: &BrokenLink{Index: 1, Counter: 2},
+		},
+		{
+			name:     "broken - tampered signature",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				links := chainLinks(signed)
+				links[0].Signature = links[1].Signature
+				return VerifyChainRequest{Records: links}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBroken: &BrokenLink{Index: 0, Counter: 0},
+		},
+		{
+			name:     "error - cursor with given records",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				return VerifyChainRequest{Records: chainLinks(signed), Cursor: persistence.EncodeSignatureCursor(1)}
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "error - invalid cursor",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				return VerifyChainRequest{Cursor: "not-a-cursor"}
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "error - cursor past the journal",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				return VerifyChainRequest{Cursor: persistence.EncodeSignatureCursor(10)}
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "error - invalid body",
+			deviceID: "chain-device",
+			body: func(signed []domain.SignatureResponse) interface{} {
+				return "not an object"
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - device not found",
+			deviceID:       "non-existent",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			createTestDevice(t, server, "chain-device")
+			var signed []domain.SignatureResponse
+			for i := 0; i < 4; i++ {
+				signed = append(signed, signTestTransaction(t, server, "chain-device", "transaction"))
+			}
+
+			var body []byte
+			if tt.body != nil {
+				body, _ = json.Marshal(tt.body(signed))
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+tt.deviceID+"/verify-chain", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.deviceID}}
+
+			server.VerifyChain(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data VerifyChainResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+
+			if response.Data.Valid != tt.expectedValid {
+				t.Errorf("expected valid=%v, got %v", tt.expectedValid, response.Data.Valid)
+			}
+			if tt.expectedBroken == nil {
+				if response.Data.BrokenLink != nil {
+					t.Errorf("expected no broken link, got %+v", response.Data.BrokenLink)
+				}
+				return
+			}
+			broken := response.Data.BrokenLink
+			if broken == nil {
+				t.Fatal("expected broken link, got none")
+			}
+			if broken.Index != tt.expectedBroken.Index || broken.Counter != tt.expectedBroken.Counter {
+				t.Errorf("expected broken link %d (counter %d), got %d (counter %d)",
+					tt.expectedBroken.Index, tt.expectedBroken.Counter, broken.Index, broken.Counter)
+			}
+			if broken.Reason == "" {
+				t.Error("expected a reason for the broken link")
+			}
+		})
+	}
+}
+
+func TestVerifyChain_JournalPages(t *testing.T) {
+	server := setupTestServer()
+	device := createTestDevice(t, server, "paged-device")
+	algorithm, _ := crypto.Lookup(string(device.Algorithm))
+	journal := server.journalFunc(context.Background())
+	for i := 0; i < MaxSignaturePageSize+2; i++ {
+		if _, err := device.SignWithCurrentKey(algorithm.NewSigner, "transaction", journal); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+	}
+
+	first := verifyTestChainRequest(server, "paged-device", VerifyChainRequest{})
+	if !first.Valid || first.Verified != MaxSignaturePageSize || first.NextCursor == "" {
+		t.Fatalf("expected a valid first page of %d records with a cursor, got %+v", MaxSignaturePageSize, first)
+	}
+
+	second := verifyTestChainRequest(server, "paged-device", VerifyChainRequest{Cursor: first.NextCursor})
+	if !second.Valid || second.Verified != 2 || second.NextCursor != "" {
+		t.Fatalf("expected a valid last page of 2 records, got %+v", second)
+	}
+
+	// The link between the pages is verified with the second page: a record
+	// signed by the device that does not follow the previous page breaks it.
+	page, _ := server.journal.List(context.Background(), "paged-device", "", 0)
+	signer, _ := algorithm.NewSigner(device.PrivateKey, device.KeyOptions())
+	forged := page.Records[MaxSignaturePageSize]
+	forged.SignedData = strconv.Itoa(forged.Counter) + "_transaction_" + base64.StdEncoding.EncodeToString([]byte("forged"))
+	signature, _ := signer.Sign([]byte(forged.SignedData))
+	forged.Signature = base64.StdEncoding.EncodeToString(signature)
+	tampered := persistence.NewInMemorySignatureJournal()
+	for _, record := range page.Records {
+		if record.Counter == forged.Counter {
+			record = forged
+		}
+		tampered.Append(context.Background(), record)
+	}
+	server.journal = tampered
+
+	broken := verifyTestChainRequest(server, "paged-device", VerifyChainRequest{Cursor: first.NextCursor})
+	if broken.Valid || broken.BrokenLink == nil || broken.BrokenLink.Index != 0 || broken.BrokenLink.Counter != MaxSignaturePageSize ||
+		!strings.Contains(broken.BrokenLink.Reason, "previous signature") {
+		t.Errorf("expected the first link of the page to be broken, got %+v", broken)
+	}
+}
+
+// verifyTestChainRequest calls the VerifyChain handler with req.
+func verifyTestChainRequest(s *Server, id string, req VerifyChainRequest) VerifyChainResponse {
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+id+"/verify-chain", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httpReq
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	s.VerifyChain(c)
+
+	var response struct {
+		Data VerifyChainResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data
+}
+
+func TestVerifySignature_Algorithms(t *testing.T) {
+	tests := []struct {
+		name    string
+		request CreateDeviceRequest
+	}{
+		{
+			name:    "success - RSA",
+			request: CreateDeviceRequest{ID: "rsa-device", Algorithm: domain.AlgorithmRSA},
+		},
+		{
+			name:    "success - RSA PKCS#1 v1.5",
+			request: CreateDeviceRequest{ID: "rsa-pkcs1v15-device", Algorithm: domain.AlgorithmRSA, RSAScheme: crypto.RSASchemePKCS1v15},
+		},
+		{
+			name:    "success - ECDSA P-256",
+			request: CreateDeviceRequest{ID: "p256-device", Algorithm: domain.AlgorithmECDSA, Curve: "P-256"},
+		},
+		{
+			name:    "success - deterministic ECDSA P-256",
+			request: CreateDeviceRequest{ID: "deterministic-device", Algorithm: domain.AlgorithmECDSA, Curve: "P-256", Deterministic: true},
+		},
+		{
+			name:    "success - raw ECDSA P-256",
+			request: CreateDeviceRequest{ID: "raw-device", Algorithm: domain.AlgorithmECDSA, Curve: "P-256", SignatureEncoding: crypto.SignatureEncodingRaw},
+		},
+		{
+			name:    "success - ECDSA P-521",
+			request: CreateDeviceRequest{ID: "p521-device", Algorithm: domain.AlgorithmECDSA, Curve: "P-521"},
+		},
+		{
+			name:    "success - Ed25519",
+			request: CreateDeviceRequest{ID: "ed25519-device", Algorithm: domain.AlgorithmEd25519},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			body, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			server.CreateDevice(c)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("failed to create device: %s", w.Body.String())
+			}
+
+			var signed []domain.SignatureResponse
+			for i := 0; i < 3; i++ {
+				signed = append(signed, signTestTransaction(t, server, tt.request.ID, "transaction"))
+			}
+
+			body, _ = json.Marshal(VerifySignatureRequest{SignedData: signed[2].SignedData, Signature: signed[2].Signature})
+			req = httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+tt.request.ID+"/verify", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w = httptest.NewRecorder()
+			c, _ = gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.request.ID}}
+			server.VerifySignature(c)
+
+			var verified struct {
+				Data VerifySignatureResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &verified)
+			if !verified.Data.Valid {
+				t.Errorf("expected a valid signature, got %+v", verified.Data)
+			}
+
+			req = httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+tt.request.ID+"/verify-chain", nil)
+			w = httptest.NewRecorder()
+			c, _ = gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.request.ID}}
+			server.VerifyChain(c)
+
+			var chain struct {
+				Data VerifyChainResponse `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &chain)
+			if !chain.Data.Valid || chain.Data.Verified != len(signed) {
+				t.Errorf("expected a valid chain of %d records, got %+v", len(signed), chain.Data)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// chainLinks converts signing responses into the links of a chain.
+func chainLinks(signed []domain.SignatureResponse) []domain.ChainLink {
+	links := make([]domain.ChainLink, len(signed))
+	for i, s := range signed {
+		links[i] = domain.ChainLink{SignedData: s.SignedData, Signature: s.Signature}
+	}
+	return links
+}
@@ -1,13 +1,75 @@
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/pem"
+	"errors"
 )
 
+// Named curves supported for ECDSA devices
+const (
+	CurveP256 = "P-256"
+	CurveP384 = "P-384"
+	CurveP521 = "P-521"
+)
+
+// DefaultCurve is the curve used when none is given.
+const DefaultCurve = CurveP384
+
+// ErrUnsupportedCurve is returned for curves other than P-256, P-384 and P-521.
+var ErrUnsupportedCurve = errors.New("curve must be P-256, P-384 or P-521")
+
+// ParseCurve returns the elliptic curve with the given name.
+func ParseCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case CurveP256:
+		return elliptic.P256(), nil
+	case CurveP384:
+		return elliptic.P384(), nil
+	case CurveP521:
+		return elliptic.P521(), nil
+	default:
+		return nil, ErrUnsupportedCurve
+	}
+}
+
+// CurveName returns the name of a supported elliptic curve.
+func CurveName(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return CurveP256, nil
+	case elliptic.P384():
+		return CurveP384, nil
+	case elliptic.P521():
+		return CurveP521, nil
+	default:
+		return "", ErrUnsupportedCurve
+	}
+}
+
+// CurveHash returns the hash paired with a curve so that the digest matches
+// the curve's security level: SHA-256 for P-256, SHA-384 for P-384 and
+// SHA-512 for P-521.
+func CurveHash(curve elliptic.Curve) crypto.Hash {
+	switch curve {
+	case elliptic.P384():
+		return crypto.SHA384
+	case elliptic.P521():
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+// digest hashes data with the hash paired with the key's curve.
+func digest(curve elliptic.Curve, data []byte) []byte {
+	h := CurveHash(curve).New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
 // ECCKeyPair is a DTO that holds ECC private and public keys.
 type ECCKeyPair struct {
 	Public  *ecdsa.PublicKey
@@ -16,26 +78,69 @@ type ECCKeyPair struct {
 
 // ECDSASigner implements ECDSA signing
 type ECDSASigner struct {
-	privateKey *ecdsa.PrivateKey
+	privateKey    *ecdsa.PrivateKey
+	deterministic bool
 }
 
-// NewECDSASigner creates a new ECDSA signer
+// NewECDSASigner creates a new ECDSA signer that draws its nonces from
+// crypto/rand
 func NewECDSASigner(privateKey *ecdsa.PrivateKey) *ECDSASigner {
 	return &ECDSASigner{
 		privateKey: privateKey,
 	}
 }
 
-// Sign signs the data using ECDSA
+// NewDeterministicECDSASigner creates a new ECDSA signer that derives its
+// nonces from the key and the message as specified in RFC 6979, so signing the
+// same data twice yields the same signature
+func NewDeterministicECDSASigner(privateKey *ecdsa.PrivateKey) *ECDSASigner {
+	return &ECDSASigner{
+		privateKey:    privateKey,
+		deterministic: true,
+	}
+}
+
+// Sign signs the data using ECDSA with the hash paired with the key's curve
 func (s *ECDSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
-	hash := sha256.Sum256(dataToBeSigned)
-	signature, err := ecdsa.SignASN1(rand.Reader, s.privateKey, hash[:])
+	hash := digest(s.privateKey.Curve, dataToBeSigned)
+	if s.deterministic {
+		// Without a random source the standard library derives the nonce as
+		// in RFC 6979 and signs in constant time.
+		return s.privateKey.Sign(nil, hash, CurveHash(s.privateKey.Curve))
+	}
+	signature, err := ecdsa.SignASN1(rand.Reader, s.privateKey, hash)
 	if err != nil {
 		return nil, err
 	}
 	return signature, nil
 }
 
+// ECDSAVerifier verifies ECDSA signatures created by ECDSASigner or
+// RawECDSASigner
+type ECDSAVerifier struct {
+	publicKey *ecdsa.PublicKey
+}
+
+// NewECDSAVerifier creates a new ECDSA verifier
+func NewECDSAVerifier(publicKey *ecdsa.PublicKey) *ECDSAVerifier {
+	return &ECDSAVerifier{
+		publicKey: publicKey,
+	}
+}
+
+// Verify checks an ECDSA signature over the data. The signature may be DER or
+// raw r||s encoded; a raw signature is only tried once it fails as DER.
+func (v *ECDSAVerifier) Verify(signedData, signature []byte) error {
+	hash := digest(v.publicKey.Curve, signedData)
+	if ecdsa.VerifyASN1(v.publicKey, hash, signature) {
+		return nil
+	}
+	if der, err := ECDSASignatureToDER(v.publicKey.Curve, signature); err == nil && ecdsa.VerifyASN1(v.publicKey, hash, der) {
+		return nil
+	}
+	return ErrInvalidSignature
+}
+
 // ECCMarshaler can encode and decode an ECC key pair.
 type ECCMarshaler struct{}
 
@@ -44,39 +149,23 @@ func NewECCMarshaler() ECCMarshaler {
 	return ECCMarshaler{}
 }
 
-// Encode takes an ECCKeyPair and encodes it to be written on disk.
+// Encode takes an ECCKeyPair and encodes it to be written on disk, the
+// private key as PKCS#8 and the public key as SubjectPublicKeyInfo.
 // It returns the public and the private key as a byte slice.
 func (m ECCMarshaler) Encode(keyPair ECCKeyPair) ([]byte, []byte, error) {
-	privateKeyBytes, err := x509.MarshalECPrivateKey(keyPair.Private)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	publicKeyBytes, err := x509.MarshalPKIXPublicKey(keyPair.Public)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	encodedPrivate := pem.EncodeToMemory(&pem.Block{
-		Type:  "PRIVATE_KEY",
-		Bytes: privateKeyBytes,
-	})
-
-	encodedPublic := pem.EncodeToMemory(&pem.Block{
-		Type:  "PUBLIC_KEY",
-		Bytes: publicKeyBytes,
-	})
-
-	return encodedPublic, encodedPrivate, nil
+	return NewPKCS8Marshaler().Marshal(keyPair.Private)
 }
 
-// Decode assembles an ECCKeyPair from an encoded private key.
+// Decode assembles an ECCKeyPair from an encoded private key, PKCS#8 or SEC 1.
 func (m ECCMarshaler) Decode(privateKeyBytes []byte) (*ECCKeyPair, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+	_, parsed, err := NewPKCS8Marshaler().Unmarshal(privateKeyBytes)
 	if err != nil {
 		return nil, err
 	}
+	privateKey, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
 
 	return &ECCKeyPair{
 		Private: privateKey,
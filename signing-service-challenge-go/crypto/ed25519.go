@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+)
+
+// Ed25519KeyPair is a DTO that holds Ed25519 private and public keys.
+type Ed25519KeyPair struct {
+	Public  ed25519.PublicKey
+	Private ed25519.PrivateKey
+}
+
+// Ed25519Generator generates an Ed25519 key pair.
+type Ed25519Generator struct{}
+
+// Generate generates a new Ed25519KeyPair.
+func (g *Ed25519Generator) Generate() (*Ed25519KeyPair, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ed25519KeyPair{
+		Public:  public,
+		Private: private,
+	}, nil
+}
+
+// Ed25519Signer implements Ed25519 signing
+type Ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a new Ed25519 signer
+func NewEd25519Signer(privateKey ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{
+		privateKey: privateKey,
+	}
+}
+
+// Sign signs the data using pure Ed25519, which hashes internally and is
+// deterministic.
+func (s *Ed25519Signer) Sign(dataToBeSigned []byte) ([]byte, error) {
+	if len(s.privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid Ed25519 private key")
+	}
+	return ed25519.Sign(s.privateKey, dataToBeSigned), nil
+}
+
+// Ed25519Verifier verifies signatures created by Ed25519Signer
+type Ed25519Verifier struct {
+	publicKey ed25519.PublicKey
+}
+
+// NewEd25519Verifier creates a new Ed25519 verifier
+func NewEd25519Verifier(publicKey ed25519.PublicKey) *Ed25519Verifier {
+	return &Ed25519Verifier{
+		publicKey: publicKey,
+	}
+}
+
+// Verify checks an Ed25519 signature over the data
+func (v *Ed25519Verifier) Verify(signedData, signature []byte) error {
+	if len(v.publicKey) != ed25519.PublicKeySize || !ed25519.Verify(v.publicKey, signedData, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Ed25519Marshaler can encode and decode an Ed25519 key pair.
+type Ed25519Marshaler struct{}
+
+// NewEd25519Marshaler creates a new Ed25519Marshaler.
+func NewEd25519Marshaler() Ed25519Marshaler {
+	return Ed25519Marshaler{}
+}
+
+// Encode takes an Ed25519KeyPair and encodes it to be written on disk, the
+// private key as PKCS#8 and the public key as SubjectPublicKeyInfo.
+// It returns the public and the private key as a byte slice.
+func (m Ed25519Marshaler) Encode(keyPair Ed25519KeyPair) ([]byte, []byte, error) {
+	return NewPKCS8Marshaler().Marshal(keyPair.Private)
+}
+
+// Decode assembles an Ed25519KeyPair from a PEM encoded PKCS#8 private key.
+func (m Ed25519Marshaler) Decode(privateKeyBytes []byte) (*Ed25519KeyPair, error) {
+	_, parsed, err := NewPKCS8Marshaler().Unmarshal(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+
+	return &Ed25519KeyPair{
+		Public:  privateKey.Public().(ed25519.PublicKey),
+		Private: privateKey,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestEd25519Generator_Generate(t *testing.T) {
+	generator := &Ed25519Generator{}
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if keyPair.Public == nil || keyPair.Private == nil {
+		t.Fatal("expected a key pair")
+	}
+	if !keyPair.Public.Equal(keyPair.Private.Public()) {
+		t.Error("public key does not belong to the private key")
+	}
+}
+
+func TestEd25519Signer_Deterministic(t *testing.T) {
+	keyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	signer := NewEd25519Signer(keyPair.Private)
+
+	first, err := signer.Sign([]byte("0_data_ZGV2aWNl"))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	second, _ := signer.Sign([]byte("0_data_ZGV2aWNl"))
+
+	if string(first) != string(second) {
+		t.Error("expected identical signatures for identical data")
+	}
+	if len(first) != 64 {
+		t.Errorf("expected a 64 byte signature, got %d", len(first))
+	}
+}
+
+func TestEd25519Marshaler(t *testing.T) {
+	keyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	marshaler := NewEd25519Marshaler()
+
+	public, private, err := marshaler.Encode(*keyPair)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if len(public) == 0 {
+		t.Error("expected an encoded public key")
+	}
+
+	decoded, err := marshaler.Decode(private)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if !decoded.Private.Equal(keyPair.Private) || !decoded.Public.Equal(keyPair.Public) {
+		t.Error("decoded key pair does not match")
+	}
+
+	eccKeyPair, _ := (&ECCGenerator{}).Generate()
+	_, eccPrivate, _ := NewECCMarshaler().Encode(*eccKeyPair)
+	if _, err := marshaler.Decode(eccPrivate); err == nil {
+		t.Error("expected an error decoding a non-PKCS#8 key")
+	}
+	if _, err := marshaler.Decode([]byte("not PEM")); err == nil {
+		t.Error("expected an error decoding garbage")
+	}
+}
@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// MasterKeySize is the size in bytes of a master key (AES-256).
+const MasterKeySize = 32
+
+var (
+	ErrInvalidMasterKey  = errors.New("master key must be 32 bytes")
+	ErrMasterKeyMismatch = errors.New("wrapped key was sealed under a different master key")
+)
+
+// MasterKey is the key-encryption key of the envelope scheme. It never
+// encrypts private keys directly; it only wraps the per-key data keys.
+type MasterKey struct {
+	id  string
+	key []byte
+}
+
+// NewMasterKey creates a MasterKey from 32 raw bytes.
+func NewMasterKey(key []byte) (*MasterKey, error) {
+	if len(key) != MasterKeySize {
+		return nil, ErrInvalidMasterKey
+	}
+
+	// The ID is a short fingerprint that tells which master key wrapped a
+	// data key, without revealing the key itself.
+	fingerprint := sha256.Sum256(key)
+
+	return &MasterKey{
+		id:  hex.EncodeToString(fingerprint[:8]),
+		key: append([]byte(nil), key...),
+	}, nil
+}
+
+// ParseMasterKey decodes a base64 encoded master key.
+func ParseMasterKey(encoded string) (*MasterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decode master key: %w", err)
+	}
+	return NewMasterKey(key)
+}
+
+// LoadMasterKeyFile reads a base64 encoded master key from a file.
+func LoadMasterKeyFile(path string) (*MasterKey, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read master key file: %w", err)
+	}
+	return ParseMasterKey(string(encoded))
+}
+
+// LoadMasterKeyEnv reads a base64 encoded master key from an environment
+// variable.
+func LoadMasterKeyEnv(name string) (*MasterKey, error) {
+	encoded, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return ParseMasterKey(encoded)
+}
+
+// ID returns the fingerprint identifying this master key.
+func (k *MasterKey) ID() string {
+	return k.id
+}
+
+// WrappedKey is an envelope: the payload sealed with a random data key, and
+// the data key itself sealed with a master key. Both use AES-256-GCM with the
+// nonce prepended to the ciphertext.
+type WrappedKey struct {
+	MasterKeyID string `json:"master_key_id"`
+	DataKey     []byte `json:"data_key"`
+	Ciphertext  []byte `json:"ciphertext"`
+}
+
+// Seal encrypts plaintext under a fresh data key and wraps that data key with
+// the master key. associatedData is authenticated but not encrypted; callers
+// use it to bind the envelope to its owner (e.g. the device ID).
+func (k *MasterKey) Seal(plaintext, associatedData []byte) (*WrappedKey, error) {
+	dataKey := make([]byte, MasterKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := sealGCM(dataKey, plaintext, associatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedDataKey, err := sealGCM(k.key, dataKey, []byte(k.id))
+	if err != nil {
+		return nil, err
+	}
+
+	return &WrappedKey{
+		MasterKeyID: k.id,
+		DataKey:     wrappedDataKey,
+		Ciphertext:  ciphertext,
+	}, nil
+}
+
+// Open unwraps the data key and decrypts the payload of the envelope.
+func (k *MasterKey) Open(wrapped *WrappedKey, associatedData []byte) ([]byte, error) {
+	dataKey, err := k.unwrapDataKey(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := openGCM(dataKey, wrapped.Ciphertext, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt wrapped key: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap re-encrypts the data key of the envelope under next. The sealed
+// payload is left untouched, so rotating the master key never changes the
+// private key it protects.
+func (k *MasterKey) Rewrap(wrapped *WrappedKey, next *MasterKey) (*WrappedKey, error) {
+	dataKey, err := k.unwrapDataKey(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedDataKey, err := sealGCM(next.key, dataKey, []byte(next.id))
+	if err != nil {
+		return nil, err
+	}
+
+	return &WrappedKey{
+		MasterKeyID: next.id,
+		DataKey:     wrappedDataKey,
+		Ciphertext:  wrapped.Ciphertext,
+	}, nil
+}
+
+func (k *MasterKey) unwrapDataKey(wrapped *WrappedKey) ([]byte, error) {
+	if wrapped.MasterKeyID != k.id {
+		return nil, ErrMasterKeyMismatch
+	}
+
+	dataKey, err := openGCM(k.key, wrapped.DataKey, []byte(k.id))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func sealGCM(key, plaintext, associatedData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func openGCM(key, sealed, associatedData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, associatedData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
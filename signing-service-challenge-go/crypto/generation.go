@@ -2,23 +2,64 @@ package crypto
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 )
 
+var (
+	// ErrInvalidSignature is returned by a Verifier when a signature does not match.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInvalidRSAKeySize is returned when generating an RSA key of a size
+	// outside RSAKeySizes.
+	ErrInvalidRSAKeySize = errors.New("RSA key size must be 2048, 3072 or 4096 bits")
+)
+
+// DefaultRSAKeySize is the RSA modulus size in bits used when none is given.
+const DefaultRSAKeySize = 2048
+
+// RSAKeySizes lists the RSA modulus sizes in bits that may be generated.
+var RSAKeySizes = []int{2048, 3072, 4096}
+
+// ValidRSAKeySize reports whether bits is one of RSAKeySizes.
+func ValidRSAKeySize(bits int) bool {
+	for _, size := range RSAKeySizes {
+		if bits == size {
+			return true
+		}
+	}
+	return false
+}
+
 // Signer defines a contract for different types of signing implementations.
 type Signer interface {
 	Sign(dataToBeSigned []byte) ([]byte, error)
 }
 
+// Verifier defines a contract for checking signatures created by a Signer.
+// Verify returns ErrInvalidSignature if the signature does not match.
+type Verifier interface {
+	Verify(signedData, signature []byte) error
+}
+
 // RSAGenerator generates a RSA key pair.
-type RSAGenerator struct{}
+type RSAGenerator struct {
+	// Bits is the modulus size; zero means DefaultRSAKeySize.
+	Bits int
+}
 
-// Generate generates a new RSAKeyPair.
+// Generate generates a new RSAKeyPair. It returns ErrInvalidRSAKeySize if
+// Bits is not one of RSAKeySizes.
 func (g *RSAGenerator) Generate() (*RSAKeyPair, error) {
-	// Security has been ignored for the sake of simplicity.
-	key, err := rsa.GenerateKey(rand.Reader, 512)
+	bits := g.Bits
+	if bits == 0 {
+		bits = DefaultRSAKeySize
+	}
+	if !ValidRSAKeySize(bits) {
+		return nil, ErrInvalidRSAKeySize
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return nil, err
 	}
@@ -30,12 +71,24 @@ func (g *RSAGenerator) Generate() (*RSAKeyPair, error) {
 }
 
 // ECCGenerator generates an ECC key pair.
-type ECCGenerator struct{}
+type ECCGenerator struct {
+	// Curve names the curve; empty means DefaultCurve.
+	Curve string
+}
 
-// Generate generates a new ECCKeyPair.
+// Generate generates a new ECCKeyPair. It returns ErrUnsupportedCurve if
+// Curve is not one of the supported curves.
 func (g *ECCGenerator) Generate() (*ECCKeyPair, error) {
-	// Security has been ignored for the sake of simplicity.
-	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	name := g.Curve
+	if name == "" {
+		name = DefaultCurve
+	}
+	curve, err := ParseCurve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
 	if err != nil {
 		return nil, err
 	}
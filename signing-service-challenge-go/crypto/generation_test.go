@@ -6,23 +6,42 @@ import (
 
 func TestRSAGenerator_Generate(t *testing.T) {
 	tests := []struct {
-		name      string
-		wantError bool
+		name         string
+		bits         int
+		expectedBits int
+		wantError    bool
 	}{
 		{
-			name:      "success - generate RSA key pair",
-			wantError: false,
+			name:         "success - generate RSA key pair",
+			expectedBits: DefaultRSAKeySize,
+			wantError:    false,
+		},
+		{
+			name:         "success - generate 3072-bit RSA key pair",
+			bits:         3072,
+			expectedBits: 3072,
+			wantError:    false,
+		},
+		{
+			name:      "error - weak key size",
+			bits:      512,
+			wantError: true,
+		},
+		{
+			name:      "error - unsupported key size",
+			bits:      2049,
+			wantError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			generator := &RSAGenerator{}
+			generator := &RSAGenerator{Bits: tt.bits}
 			keyPair, err := generator.Generate()
 
 			if tt.wantError {
-				if err == nil {
-					t.Error("expected error, got nil")
+				if err != ErrInvalidRSAKeySize {
+					t.Errorf("expected ErrInvalidRSAKeySize, got %v", err)
 				}
 			} else {
 				if err != nil {
@@ -37,6 +56,9 @@ func TestRSAGenerator_Generate(t *testing.T) {
 				if keyPair.Private == nil {
 					t.Error("expected private key, got nil")
 				}
+				if bits := keyPair.Public.N.BitLen(); bits != tt.expectedBits {
+					t.Errorf("expected %d-bit key, got %d", tt.expectedBits, bits)
+				}
 			}
 		})
 	}
@@ -44,23 +66,41 @@ func TestRSAGenerator_Generate(t *testing.T) {
 
 func TestECCGenerator_Generate(t *testing.T) {
 	tests := []struct {
-		name      string
-		wantError bool
+		name          string
+		curve         string
+		expectedCurve string
+		wantError     bool
 	}{
 		{
-			name:      "success - generate ECC key pair",
-			wantError: false,
+			name:          "success - generate ECC key pair",
+			expectedCurve: DefaultCurve,
+			wantError:     false,
+		},
+		{
+			name:          "success - generate P-256 key pair",
+			curve:         CurveP256,
+			expectedCurve: CurveP256,
+		},
+		{
+			name:          "success - generate P-521 key pair",
+			curve:         CurveP521,
+			expectedCurve: CurveP521,
+		},
+		{
+			name:      "error - unsupported curve",
+			curve:     "P-224",
+			wantError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			generator := &ECCGenerator{}
+			generator := &ECCGenerator{Curve: tt.curve}
 			keyPair, err := generator.Generate()
 
 			if tt.wantError {
-				if err == nil {
-					t.Error("expected error, got nil")
+				if err != ErrUnsupportedCurve {
+					t.Errorf("expected ErrUnsupportedCurve, got %v", err)
 				}
 			} else {
 				if err != nil {
@@ -75,6 +115,9 @@ func TestECCGenerator_Generate(t *testing.T) {
 				if keyPair.Private == nil {
 					t.Error("expected private key, got nil")
 				}
+				if curve, _ := CurveName(keyPair.Public.Curve); curve != tt.expectedCurve {
+					t.Errorf("expected curve %s, got %s", tt.expectedCurve, curve)
+				}
 			}
 		})
 	}
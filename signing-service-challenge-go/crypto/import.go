@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// MinImportRSAKeySize is the smallest RSA modulus in bits accepted for keys
+// brought in from elsewhere. Unlike generated keys, imported keys may have any
+// size from here up to the largest of RSAKeySizes.
+const MinImportRSAKeySize = 2048
+
+// ErrWeakKey is returned for imported keys below the service's minimum strength.
+var ErrWeakKey = errors.New("key does not meet the minimum strength")
+
+// KeyAlgorithm returns the name of the built-in algorithm a private key
+// belongs to.
+func KeyAlgorithm(privateKey interface{}) (string, error) {
+	switch privateKey.(type) {
+	case *rsa.PrivateKey:
+		return AlgorithmNameRSA, nil
+	case *ecdsa.PrivateKey:
+		return AlgorithmNameECDSA, nil
+	case ed25519.PrivateKey:
+		return AlgorithmNameEd25519, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedPrivateKey, privateKey)
+	}
+}
+
+// ValidateKeyStrength checks that an imported private key is as strong as the
+// keys the service generates itself: RSA keys of at least MinImportRSAKeySize
+// bits with a public exponent of at least 65537, ECDSA keys on a supported
+// curve, and well formed Ed25519 keys.
+func ValidateKeyStrength(privateKey interface{}) error {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		bits := key.N.BitLen()
+		if bits < MinImportRSAKeySize {
+			return fmt.Errorf("%w: RSA key has %d bits, at least %d are required", ErrWeakKey, bits, MinImportRSAKeySize)
+		}
+		if maxBits := RSAKeySizes[len(RSAKeySizes)-1]; bits > maxBits {
+			return fmt.Errorf("%w: RSA key has %d bits, at most %d are supported", ErrInvalidRSAKeySize, bits, maxBits)
+		}
+		if key.E < 65537 {
+			return fmt.Errorf("%w: RSA public exponent %d is too small", ErrWeakKey, key.E)
+		}
+		if err := key.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedPrivateKey, err)
+		}
+		return nil
+	case *ecdsa.PrivateKey:
+		if _, err := CurveName(key.Curve); err != nil {
+			return err
+		}
+		return nil
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
+			return ErrMalformedPrivateKey
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedPrivateKey, privateKey)
+	}
+}
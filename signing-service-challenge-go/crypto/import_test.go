@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func TestKeyAlgorithm(t *testing.T) {
+	for name, privateKey := range testPrivateKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			got, err := KeyAlgorithm(privateKey)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != name {
+				t.Errorf("expected %q, got %q", name, got)
+			}
+		})
+	}
+
+	if _, err := KeyAlgorithm("not a key"); !errors.Is(err, ErrUnsupportedPrivateKey) {
+		t.Errorf("expected ErrUnsupportedPrivateKey, got %v", err)
+	}
+}
+
+func TestValidateKeyStrength(t *testing.T) {
+	weakRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate P-224 key: %v", err)
+	}
+	smallExponent := *testPrivateKeys(t)[AlgorithmNameRSA].(*rsa.PrivateKey)
+	smallExponent.E = 3
+
+	for name, privateKey := range testPrivateKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateKeyStrength(privateKey); err != nil {
+				t.Errorf("expected generated key to pass, got %v", err)
+			}
+		})
+	}
+
+	tests := []struct {
+		name      string
+		key       interface{}
+		wantError error
+	}{
+		{name: "RSA 1024", key: weakRSA, wantError: ErrWeakKey},
+		{name: "RSA small exponent", key: &smallExponent, wantError: ErrWeakKey},
+		{name: "ECDSA P-224", key: p224Key, wantError: ErrUnsupportedCurve},
+		{name: "unsupported type", key: "not a key", wantError: ErrUnsupportedPrivateKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateKeyStrength(tt.key); !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
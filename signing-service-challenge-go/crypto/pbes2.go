@@ -0,0 +1,256 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PEMTypeEncryptedPrivateKey is the block type of a password protected
+// PKCS#8 private key.
+const PEMTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+
+var (
+	ErrPasswordRequired      = errors.New("private key is encrypted, a password is required")
+	ErrIncorrectPassword     = errors.New("incorrect password or corrupted private key")
+	ErrUnsupportedEncryption = errors.New("unsupported private key encryption, expected PBES2 with PBKDF2 and AES-CBC")
+	ErrWeakPassword          = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+)
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const (
+	// PBKDF2Iterations is the iteration count used when encrypting keys. It
+	// follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+	PBKDF2Iterations = 600_000
+	// MinPasswordLength is the shortest password accepted for encrypting keys.
+	MinPasswordLength = 8
+
+	// maxPBKDF2Iterations bounds the work an untrusted key can make us do. Keys
+	// are imported without authentication, so it stays close to
+	// PBKDF2Iterations: about a second of CPU time per request at most.
+	maxPBKDF2Iterations = 2_000_000
+	pbes2SaltSize       = 16
+)
+
+// encryptedPrivateKeyInfo is the ASN.1 structure of RFC 5958, section 3.
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+// pbes2Params is the ASN.1 structure of RFC 8018, appendix A.4.
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+// pbkdf2Params is the ASN.1 structure of RFC 8018, appendix A.2.
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                 `asn1:"optional"`
+	PRF            algorithmIdentifier `asn1:"optional"`
+}
+
+// encryptPKCS8 encrypts a DER encoded PKCS#8 PrivateKeyInfo with PBES2, using
+// PBKDF2-HMAC-SHA256 and AES-256-CBC, and returns the DER encoded
+// EncryptedPrivateKeyInfo. The result can be read by decryptPKCS8 and by
+// `openssl pkcs8`.
+func encryptPKCS8(der, password []byte) ([]byte, error) {
+	if len(password) < MinPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	salt := make([]byte, pbes2SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(password, salt, PBKDF2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := pad(der, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: PBKDF2Iterations,
+		PRF:            algorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+}
+
+// decryptPKCS8 decrypts a DER encoded EncryptedPrivateKeyInfo protected with
+// PBES2 (PBKDF2 and AES-CBC), as written by `openssl pkcs8 -topk8 -v2`, and
+// returns the plain PKCS#8 PrivateKeyInfo.
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: invalid EncryptedPrivateKeyInfo", ErrMalformedPrivateKey)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("%w: scheme %v", ErrUnsupportedEncryption, info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("%w: invalid PBES2 parameters", ErrMalformedPrivateKey)
+	}
+
+	keyLength, newCipher, iv, err := pbes2Cipher(params.EncryptionScheme)
+	if err != nil {
+		return nil, err
+	}
+	key, err := pbes2Key(params.KeyDerivationFunc, password, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("%w: encrypted data is not a whole number of blocks", ErrMalformedPrivateKey)
+	}
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	plaintext, ok := unpad(plaintext, block.BlockSize())
+	if !ok {
+		return nil, ErrIncorrectPassword
+	}
+	return plaintext, nil
+}
+
+// pbes2Cipher returns the key length, cipher and IV of a PBES2 encryption
+// scheme.
+func pbes2Cipher(scheme algorithmIdentifier) (int, func([]byte) (cipher.Block, error), []byte, error) {
+	var keyLength int
+	switch {
+	case scheme.Algorithm.Equal(oidAES128CBC):
+		keyLength = 16
+	case scheme.Algorithm.Equal(oidAES192CBC):
+		keyLength = 24
+	case scheme.Algorithm.Equal(oidAES256CBC):
+		keyLength = 32
+	default:
+		return 0, nil, nil, fmt.Errorf("%w: cipher %v", ErrUnsupportedEncryption, scheme.Algorithm)
+	}
+
+	var iv []byte
+	if rest, err := asn1.Unmarshal(scheme.Parameters.FullBytes, &iv); err != nil || len(rest) != 0 || len(iv) != aes.BlockSize {
+		return 0, nil, nil, fmt.Errorf("%w: invalid AES-CBC IV", ErrMalformedPrivateKey)
+	}
+	return keyLength, aes.NewCipher, iv, nil
+}
+
+// pbes2Key derives the encryption key with PBKDF2.
+func pbes2Key(kdf algorithmIdentifier, password []byte, keyLength int) ([]byte, error) {
+	if !kdf.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("%w: key derivation %v", ErrUnsupportedEncryption, kdf.Algorithm)
+	}
+
+	var params pbkdf2Params
+	if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("%w: invalid PBKDF2 parameters", ErrMalformedPrivateKey)
+	}
+	if params.IterationCount < 1 || params.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("%w: PBKDF2 iteration count %d", ErrUnsupportedEncryption, params.IterationCount)
+	}
+	if params.KeyLength != 0 && params.KeyLength != keyLength {
+		return nil, fmt.Errorf("%w: PBKDF2 key length %d does not match the cipher", ErrMalformedPrivateKey, params.KeyLength)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case params.PRF.Algorithm == nil, params.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case params.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case params.PRF.Algorithm.Equal(oidHMACWithSHA384):
+		prf = sha512.New384
+	case params.PRF.Algorithm.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("%w: PBKDF2 PRF %v", ErrUnsupportedEncryption, params.PRF.Algorithm)
+	}
+
+	return pbkdf2.Key(password, params.Salt, params.IterationCount, keyLength, prf), nil
+}
+
+// pad appends PKCS#7 padding to a copy of data.
+func pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	padded := make([]byte, len(data), len(data)+n)
+	copy(padded, data)
+	return append(padded, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// unpad strips PKCS#7 padding, reporting whether it was well formed. The
+// padding bytes are compared in constant time.
+func unpad(data []byte, blockSize int) ([]byte, bool) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, false
+	}
+	padding := bytes.Repeat([]byte{byte(n)}, n)
+	if subtle.ConstantTimeCompare(data[len(data)-n:], padding) != 1 {
+		return nil, false
+	}
+	return data[:len(data)-n], true
+}
@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// PEM block types
+const (
+	PEMTypePrivateKey    = "PRIVATE KEY"     // PKCS#8
+	PEMTypePublicKey     = "PUBLIC KEY"      // SubjectPublicKeyInfo
+	PEMTypeRSAPrivateKey = "RSA PRIVATE KEY" // PKCS#1
+	PEMTypeECPrivateKey  = "EC PRIVATE KEY"  // SEC 1
+
+	// Block types written by earlier versions of the RSA and ECC marshalers.
+	legacyPEMTypeRSAPrivateKey = "RSA_PRIVATE_KEY"
+	legacyPEMTypeECPrivateKey  = "PRIVATE_KEY"
+)
+
+var (
+	ErrNoPEMBlock            = errors.New("no PEM block found")
+	ErrUnsupportedPEMType    = errors.New("unsupported PEM block type")
+	ErrMalformedPrivateKey   = errors.New("malformed private key")
+	ErrUnsupportedPrivateKey = errors.New("unsupported private key type")
+)
+
+// PKCS8Marshaler encodes the private keys of every built-in algorithm as
+// standard PKCS#8 "PRIVATE KEY" PEM and their public keys as "PUBLIC KEY"
+// SubjectPublicKeyInfo PEM. Besides PKCS#8 it reads PKCS#1 RSA and SEC 1 EC
+// private keys, including the non-standard block types earlier versions wrote.
+type PKCS8Marshaler struct{}
+
+// NewPKCS8Marshaler creates a new PKCS8Marshaler.
+func NewPKCS8Marshaler() PKCS8Marshaler {
+	return PKCS8Marshaler{}
+}
+
+// Marshal encodes a private key and its public key. It returns the public and
+// the private key as a byte slice.
+func (m PKCS8Marshaler) Marshal(privateKey interface{}) ([]byte, []byte, error) {
+	publicKey, err := publicKeyOf(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	encodedPublic, err := MarshalPublicKeyPEM(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encodedPrivate := pem.EncodeToMemory(&pem.Block{
+		Type:  PEMTypePrivateKey,
+		Bytes: privateKeyBytes,
+	})
+
+	return encodedPublic, encodedPrivate, nil
+}
+
+// MarshalEncrypted encodes a private key as a password protected PKCS#8
+// "ENCRYPTED PRIVATE KEY" PEM block (PBES2 with PBKDF2-HMAC-SHA256 and
+// AES-256-CBC). Passwords shorter than MinPasswordLength fail with
+// ErrWeakPassword.
+func (m PKCS8Marshaler) MarshalEncrypted(privateKey interface{}, password []byte) ([]byte, error) {
+	if _, err := publicKeyOf(privateKey); err != nil {
+		return nil, err
+	}
+
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptPKCS8(privateKeyBytes, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PEMTypeEncryptedPrivateKey,
+		Bytes: encrypted,
+	}), nil
+}
+
+// Unmarshal decodes the first PEM block of privateKeyBytes into a private key
+// and derives its public key. Errors wrap ErrNoPEMBlock,
+// ErrUnsupportedPEMType, ErrMalformedPrivateKey or ErrUnsupportedPrivateKey;
+// encrypted keys fail with ErrPasswordRequired.
+func (m PKCS8Marshaler) Unmarshal(privateKeyBytes []byte) (interface{}, interface{}, error) {
+	return m.UnmarshalWithPassword(privateKeyBytes, nil)
+}
+
+// UnmarshalWithPassword is like Unmarshal but also decrypts PBES2 encrypted
+// PKCS#8 keys with password. A wrong password fails with ErrIncorrectPassword;
+// other encryption schemes fail with ErrUnsupportedEncryption.
+func (m PKCS8Marshaler) UnmarshalWithPassword(privateKeyBytes, password []byte) (interface{}, interface{}, error) {
+	block, _ := pem.Decode(privateKeyBytes)
+	if block == nil {
+		return nil, nil, ErrNoPEMBlock
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case PEMTypeEncryptedPrivateKey:
+		if len(password) == 0 {
+			return nil, nil, ErrPasswordRequired
+		}
+		var der []byte
+		der, err = decryptPKCS8(block.Bytes, password)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, err = x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			// Padding matched by chance, but the password was still wrong.
+			return nil, nil, ErrIncorrectPassword
+		}
+	case PEMTypePrivateKey:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case PEMTypeRSAPrivateKey, legacyPEMTypeRSAPrivateKey:
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case PEMTypeECPrivateKey, legacyPEMTypeECPrivateKey:
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedPEMType, block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMalformedPrivateKey, err)
+	}
+
+	publicKey, err := publicKeyOf(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return publicKey, privateKey, nil
+}
+
+// publicKeyOf returns the public key of a supported private key.
+func publicKeyOf(privateKey interface{}) (interface{}, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		if _, err := CurveName(key.Curve); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPrivateKey, err)
+		}
+		return &key.PublicKey, nil
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, ErrMalformedPrivateKey
+		}
+		return key.Public(), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedPrivateKey, privateKey)
+	}
+}
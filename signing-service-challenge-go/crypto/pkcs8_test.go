@@ -0,0 +1,373 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/aes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"os"
+	"testing"
+)
+
+// testPrivateKeys returns a fresh private key of every built-in algorithm.
+func testPrivateKeys(t testing.TB) map[string]interface{} {
+	t.Helper()
+
+	rsaKeyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	eccKeyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECC key pair: %v", err)
+	}
+	ed25519KeyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key pair: %v", err)
+	}
+
+	return map[string]interface{}{
+		AlgorithmNameRSA:     rsaKeyPair.Private,
+		AlgorithmNameECDSA:   eccKeyPair.Private,
+		AlgorithmNameEd25519: ed25519KeyPair.Private,
+	}
+}
+
+func TestPKCS8Marshaler_RoundTrip(t *testing.T) {
+	marshaler := NewPKCS8Marshaler()
+
+	for name, privateKey := range testPrivateKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			publicPEM, privatePEM, err := marshaler.Marshal(privateKey)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if block, _ := pem.Decode(privatePEM); block == nil || block.Type != PEMTypePrivateKey {
+				t.Errorf("expected a %q block, got %q", PEMTypePrivateKey, privatePEM)
+			}
+			block, _ := pem.Decode(publicPEM)
+			if block == nil || block.Type != PEMTypePublicKey {
+				t.Fatalf("expected a %q block, got %q", PEMTypePublicKey, publicPEM)
+			}
+			if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				t.Errorf("expected a SubjectPublicKeyInfo, got %v", err)
+			}
+
+			publicKey, decoded, err := marshaler.Unmarshal(privatePEM)
+			if err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if !decoded.(interface {
+				Equal(stdcrypto.PrivateKey) bool
+			}).Equal(privateKey) {
+				t.Error("decoded private key does not match")
+			}
+			if !publicKey.(interface {
+				Equal(stdcrypto.PublicKey) bool
+			}).Equal(privateKey.(stdcrypto.Signer).Public()) {
+				t.Error("derived public key does not match")
+			}
+		})
+	}
+}
+
+func TestPKCS8Marshaler_LegacyBlockTypes(t *testing.T) {
+	keys := testPrivateKeys(t)
+	rsaKey := keys[AlgorithmNameRSA].(*rsa.PrivateKey)
+	ecKey := keys[AlgorithmNameECDSA].(*ecdsa.PrivateKey)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("failed to marshal EC key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		block    *pem.Block
+		expected interface{}
+	}{
+		{name: "legacy RSA_PRIVATE_KEY", block: &pem.Block{Type: "RSA_PRIVATE_KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, expected: rsaKey},
+		{name: "PKCS#1 RSA PRIVATE KEY", block: &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, expected: rsaKey},
+		{name: "legacy PRIVATE_KEY", block: &pem.Block{Type: "PRIVATE_KEY", Bytes: sec1}, expected: ecKey},
+		{name: "SEC 1 EC PRIVATE KEY", block: &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}, expected: ecKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, privateKey, err := NewPKCS8Marshaler().Unmarshal(pem.EncodeToMemory(tt.block))
+			if err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if !privateKey.(interface {
+				Equal(stdcrypto.PrivateKey) bool
+			}).Equal(tt.expected) {
+				t.Error("decoded private key does not match")
+			}
+		})
+	}
+}
+
+func TestPKCS8Marshaler_Errors(t *testing.T) {
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate P-224 key: %v", err)
+	}
+	p224PKCS8, _ := x509.MarshalPKCS8PrivateKey(p224Key)
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate X25519 key: %v", err)
+	}
+	x25519PKCS8, _ := x509.MarshalPKCS8PrivateKey(x25519Key)
+
+	tests := []struct {
+		name      string
+		input     []byte
+		wantError error
+	}{
+		{name: "empty", input: nil, wantError: ErrNoPEMBlock},
+		{name: "not PEM", input: []byte("not PEM"), wantError: ErrNoPEMBlock},
+		{name: "certificate", input: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0x30}}), wantError: ErrUnsupportedPEMType},
+		{name: "public key", input: pem.EncodeToMemory(&pem.Block{Type: PEMTypePublicKey, Bytes: []byte{0x30}}), wantError: ErrUnsupportedPEMType},
+		{name: "malformed PKCS#8", input: pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: []byte("garbage")}), wantError: ErrMalformedPrivateKey},
+		{name: "malformed PKCS#1", input: pem.EncodeToMemory(&pem.Block{Type: "RSA_PRIVATE_KEY", Bytes: []byte("garbage")}), wantError: ErrMalformedPrivateKey},
+		{name: "malformed SEC 1", input: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE_KEY", Bytes: []byte("garbage")}), wantError: ErrMalformedPrivateKey},
+		{name: "unsupported curve", input: pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: p224PKCS8}), wantError: ErrUnsupportedPrivateKey},
+		{name: "unsupported key type", input: pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: x25519PKCS8}), wantError: ErrUnsupportedPrivateKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := NewPKCS8Marshaler().Unmarshal(tt.input); !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+
+	if _, _, err := NewPKCS8Marshaler().Marshal(x25519Key); !errors.Is(err, ErrUnsupportedPrivateKey) {
+		t.Errorf("expected ErrUnsupportedPrivateKey marshalling an X25519 key, got %v", err)
+	}
+}
+
+func TestMarshalers_TypeMismatch(t *testing.T) {
+	keys := testPrivateKeys(t)
+	_, rsaPEM, _ := NewPKCS8Marshaler().Marshal(keys[AlgorithmNameRSA])
+	_, ecPEM, _ := NewPKCS8Marshaler().Marshal(keys[AlgorithmNameECDSA])
+
+	rsaMarshaler := NewRSAMarshaler()
+	if _, err := rsaMarshaler.Unmarshal(ecPEM); err != ErrKeyTypeMismatch {
+		t.Errorf("expected ErrKeyTypeMismatch from the RSA marshaler, got %v", err)
+	}
+	if _, err := NewECCMarshaler().Decode(rsaPEM); err != ErrKeyTypeMismatch {
+		t.Errorf("expected ErrKeyTypeMismatch from the ECC marshaler, got %v", err)
+	}
+	if _, err := rsaMarshaler.Unmarshal([]byte("not PEM")); err != ErrNoPEMBlock {
+		t.Errorf("expected ErrNoPEMBlock from the RSA marshaler, got %v", err)
+	}
+	if _, err := NewECCMarshaler().Decode(nil); err != ErrNoPEMBlock {
+		t.Errorf("expected ErrNoPEMBlock from the ECC marshaler, got %v", err)
+	}
+}
+
+func TestPKCS8Marshaler_UnmarshalWithPassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		plain     string
+		encrypted string
+	}{
+		{name: "ECDSA P-256, AES-256-CBC, HMAC-SHA256", plain: "testdata/ecdsa-p256.pem", encrypted: "testdata/ecdsa-p256.encrypted.pem"},
+		{name: "RSA 2048, AES-128-CBC, HMAC-SHA1", plain: "testdata/rsa-2048.pem", encrypted: "testdata/rsa-2048.encrypted.pem"},
+		{name: "Ed25519, AES-192-CBC, HMAC-SHA512", plain: "testdata/ed25519.pem", encrypted: "testdata/ed25519.encrypted.pem"},
+	}
+
+	marshaler := NewPKCS8Marshaler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plainPEM, err := os.ReadFile(tt.plain)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			encryptedPEM, err := os.ReadFile(tt.encrypted)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			_, expected, err := marshaler.Unmarshal(plainPEM)
+			if err != nil {
+				t.Fatalf("failed to unmarshal plain key: %v", err)
+			}
+
+			_, privateKey, err := marshaler.UnmarshalWithPassword(encryptedPEM, []byte("fiskaly"))
+			if err != nil {
+				t.Fatalf("failed to unmarshal encrypted key: %v", err)
+			}
+			if !privateKey.(interface {
+				Equal(stdcrypto.PrivateKey) bool
+			}).Equal(expected) {
+				t.Error("decrypted private key does not match")
+			}
+
+			if _, _, err := marshaler.UnmarshalWithPassword(encryptedPEM, []byte("wrong")); !errors.Is(err, ErrIncorrectPassword) {
+				t.Errorf("expected ErrIncorrectPassword, got %v", err)
+			}
+			if _, _, err := marshaler.Unmarshal(encryptedPEM); !errors.Is(err, ErrPasswordRequired) {
+				t.Errorf("expected ErrPasswordRequired, got %v", err)
+			}
+		})
+	}
+
+	pbes1PEM, err := os.ReadFile("testdata/ecdsa-p256.pbes1.pem")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	if _, _, err := marshaler.UnmarshalWithPassword(pbes1PEM, []byte("fiskaly")); !errors.Is(err, ErrUnsupportedEncryption) {
+		t.Errorf("expected ErrUnsupportedEncryption for PBES1, got %v", err)
+	}
+}
+
+func TestPKCS8Marshaler_MarshalEncrypted(t *testing.T) {
+	marshaler := NewPKCS8Marshaler()
+
+	for name, privateKey := range testPrivateKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			encryptedPEM, err := marshaler.MarshalEncrypted(privateKey, []byte("correct horse"))
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if block, _ := pem.Decode(encryptedPEM); block == nil || block.Type != PEMTypeEncryptedPrivateKey {
+				t.Fatalf("expected a %q block, got %q", PEMTypeEncryptedPrivateKey, encryptedPEM)
+			}
+
+			_, decoded, err := marshaler.UnmarshalWithPassword(encryptedPEM, []byte("correct horse"))
+			if err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if !decoded.(interface {
+				Equal(stdcrypto.PrivateKey) bool
+			}).Equal(privateKey) {
+				t.Error("decrypted private key does not match")
+			}
+			if _, _, err := marshaler.UnmarshalWithPassword(encryptedPEM, []byte("wrong horse")); !errors.Is(err, ErrIncorrectPassword) {
+				t.Errorf("expected ErrIncorrectPassword, got %v", err)
+			}
+		})
+	}
+
+	if _, err := marshaler.MarshalEncrypted(testPrivateKeys(t)[AlgorithmNameECDSA], []byte("short")); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("expected ErrWeakPassword, got %v", err)
+	}
+	if _, err := marshaler.MarshalEncrypted("not a key", []byte("correct horse")); !errors.Is(err, ErrUnsupportedPrivateKey) {
+		t.Errorf("expected ErrUnsupportedPrivateKey, got %v", err)
+	}
+}
+
+func TestPKCS8Marshaler_IterationLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		iterations int
+	}{
+		{name: "error - no iterations", iterations: 0},
+		{name: "error - one over the limit", iterations: maxPBKDF2Iterations + 1},
+		{name: "error - far over the limit", iterations: 1 << 30},
+	}
+
+	marshaler := NewPKCS8Marshaler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encryptedPEM := pem.EncodeToMemory(&pem.Block{
+				Type:  PEMTypeEncryptedPrivateKey,
+				Bytes: encryptedKeyWithIterations(t, tt.iterations),
+			})
+
+			_, _, err := marshaler.UnmarshalWithPassword(encryptedPEM, []byte("correct horse"))
+			if !errors.Is(err, ErrUnsupportedEncryption) {
+				t.Errorf("expected ErrUnsupportedEncryption, got %v", err)
+			}
+		})
+	}
+}
+
+// encryptedKeyWithIterations returns a DER encoded EncryptedPrivateKeyInfo
+// whose PBKDF2 parameters ask for the given iteration count. The encrypted
+// data is a placeholder, as the count is checked before any key derivation.
+func encryptedKeyWithIterations(t *testing.T, iterations int) []byte {
+	t.Helper()
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           make([]byte, pbes2SaltSize),
+		IterationCount: iterations,
+		PRF:            algorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal PBKDF2 parameters: %v", err)
+	}
+	ivParams, err := asn1.Marshal(make([]byte, aes.BlockSize))
+	if err != nil {
+		t.Fatalf("failed to marshal IV: %v", err)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal PBES2 parameters: %v", err)
+	}
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: make([]byte, aes.BlockSize),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal EncryptedPrivateKeyInfo: %v", err)
+	}
+	return der
+}
+
+func FuzzPKCS8Marshaler_Unmarshal(f *testing.F) {
+	for _, privateKey := range testPrivateKeys(f) {
+		_, privatePEM, err := NewPKCS8Marshaler().Marshal(privateKey)
+		if err != nil {
+			f.Fatalf("failed to marshal: %v", err)
+		}
+		f.Add(privatePEM)
+	}
+	f.Add([]byte("-----BEGIN PRIVATE_KEY-----\nMAA=\n-----END PRIVATE_KEY-----\n"))
+	f.Add([]byte("-----BEGIN RSA_PRIVATE_KEY-----\n-----END RSA_PRIVATE_KEY-----\n"))
+	f.Add([]byte("not PEM"))
+
+	typedErrors := []error{ErrNoPEMBlock, ErrUnsupportedPEMType, ErrMalformedPrivateKey, ErrUnsupportedPrivateKey, ErrPasswordRequired}
+	marshaler := NewPKCS8Marshaler()
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		publicKey, privateKey, err := marshaler.Unmarshal(input)
+		if err != nil {
+			for _, typed := range typedErrors {
+				if errors.Is(err, typed) {
+					return
+				}
+			}
+			t.Fatalf("untyped error: %v", err)
+		}
+		if publicKey == nil || privateKey == nil {
+			t.Fatal("expected keys without an error")
+		}
+
+		// Whatever decodes must survive a round trip through the standard encoding.
+		_, privatePEM, err := marshaler.Marshal(privateKey)
+		if err != nil {
+			t.Fatalf("failed to re-marshal a decoded key: %v", err)
+		}
+		_, again, err := marshaler.Unmarshal(privatePEM)
+		if err != nil {
+			t.Fatalf("failed to unmarshal a re-marshalled key: %v", err)
+		}
+		if !again.(interface {
+			Equal(stdcrypto.PrivateKey) bool
+		}).Equal(privateKey) {
+			t.Fatal("re-marshalled key does not match")
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrUnsupportedPublicKey is returned for public keys that cannot be exported.
+var ErrUnsupportedPublicKey = errors.New("unsupported public key type")
+
+// MarshalPublicKeyDER encodes a public key as an X.509 SubjectPublicKeyInfo.
+func MarshalPublicKeyDER(publicKey interface{}) ([]byte, error) {
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return x509.MarshalPKIXPublicKey(publicKey)
+	default:
+		return nil, ErrUnsupportedPublicKey
+	}
+}
+
+// MarshalPublicKeyPEM encodes a public key as a standard "PUBLIC KEY" PEM
+// block, which is understood by common tooling such as openssl.
+func MarshalPublicKeyPEM(publicKey interface{}) ([]byte, error) {
+	der, err := MarshalPublicKeyDER(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PEMTypePublicKey,
+		Bytes: der,
+	}), nil
+}
+
+// ParsePublicKeyPEM decodes a "PUBLIC KEY" PEM block as written by
+// MarshalPublicKeyPEM.
+func ParsePublicKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+	if block.Type != PEMTypePublicKey {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPEMType, block.Type)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, ErrUnsupportedPublicKey
+	}
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// NewJWK converts a public key into a signature verification JWK whose kid is
+// its RFC 7638 thumbprint.
+func NewJWK(publicKey interface{}) (*JWK, error) {
+	var jwk JWK
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk = JWK{
+			Kty: "RSA",
+			Alg: "PS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		crv, err := CurveName(key.Curve)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPublicKey, err)
+		}
+		// Coordinates are padded to the full field size (RFC 7518, section 6.2.1).
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk = JWK{
+			Kty: "EC",
+			Alg: ecdsaJWSAlgorithms[crv],
+			Crv: crv,
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		// Octet key pair (RFC 8037).
+		jwk = JWK{
+			Kty: "OKP",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+	default:
+		return nil, ErrUnsupportedPublicKey
+	}
+
+	jwk.Use = "sig"
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	jwk.Kid = kid
+
+	return &jwk, nil
+}
+
+// Thumbprint returns the base64url encoded SHA-256 JWK thumbprint (RFC 7638).
+// It only covers the required members of the key type, so it does not depend
+// on kid, use or alg.
+func (k *JWK) Thumbprint() (string, error) {
+	// encoding/json writes struct fields in declaration order, so the members
+	// below are declared in the lexicographic order RFC 7638 requires.
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", fmt.Errorf("%w: kty %q", ErrUnsupportedPublicKey, k.Kty)
+	}
+
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(canonical)
+
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
+
+// ecdsaJWSAlgorithms maps each curve to the JWS algorithm (RFC 7518) of the
+// hash it is paired with.
+var ecdsaJWSAlgorithms = map[string]string{
+	CurveP256: "ES256",
+	CurveP384: "ES384",
+	CurveP521: "ES512",
+}
+
+// JWKSet is a JSON Web Key Set (RFC 7517, section 5).
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,218 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// rfc7638Modulus is the modulus of the example RSA key in RFC 7638, section 3.1.
+const rfc7638Modulus = "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+
+func TestJWK_Thumbprint(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString(rfc7638Modulus)
+	if err != nil {
+		t.Fatalf("failed to decode modulus: %v", err)
+	}
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	jwk, err := NewJWK(publicKey)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if jwk.N != rfc7638Modulus || jwk.E != "AQAB" {
+		t.Errorf("unexpected RSA members n=%q e=%q", jwk.N, jwk.E)
+	}
+	if expected := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; jwk.Kid != expected {
+		t.Errorf("expected kid %q, got %q", expected, jwk.Kid)
+	}
+}
+
+func TestJWK_Thumbprint_OKP(t *testing.T) {
+	// Example key and thumbprint from RFC 8037, appendix A.
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	if err != nil {
+		t.Fatalf("failed to decode public key: %v", err)
+	}
+
+	jwk, err := NewJWK(ed25519.PublicKey(x))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" {
+		t.Errorf("unexpected JWK %+v", jwk)
+	}
+	if expected := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; jwk.Kid != expected {
+		t.Errorf("expected kid %q, got %q", expected, jwk.Kid)
+	}
+}
+
+func TestNewJWK(t *testing.T) {
+	rsaKeyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	eccKeyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECC key pair: %v", err)
+	}
+	p521KeyPair, err := (&ECCGenerator{Curve: CurveP521}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate P-521 key pair: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		publicKey   interface{}
+		expectedKty string
+		expectedCrv string
+		expectedAlg string
+		coordSize   int
+		wantError   bool
+	}{
+		{
+			name:        "success - RSA key",
+			publicKey:   rsaKeyPair.Public,
+			expectedKty: "RSA",
+			expectedAlg: "PS256",
+		},
+		{
+			name:        "success - ECDSA P-384 key",
+			publicKey:   eccKeyPair.Public,
+			expectedKty: "EC",
+			expectedCrv: "P-384",
+			expectedAlg: "ES384",
+			coordSize:   48,
+		},
+		{
+			name:        "success - ECDSA P-521 key",
+			publicKey:   p521KeyPair.Public,
+			expectedKty: "EC",
+			expectedCrv: "P-521",
+			expectedAlg: "ES512",
+			coordSize:   66,
+		},
+		{
+			name:      "error - unsupported key",
+			publicKey: "not a key",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk, err := NewJWK(tt.publicKey)
+
+			if tt.wantError {
+				if !errors.Is(err, ErrUnsupportedPublicKey) {
+					t.Errorf("expected ErrUnsupportedPublicKey, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if jwk.Kty != tt.expectedKty || jwk.Crv != tt.expectedCrv || jwk.Alg != tt.expectedAlg || jwk.Use != "sig" {
+				t.Errorf("unexpected JWK %+v", jwk)
+			}
+			if tt.coordSize > 0 {
+				x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+				y, _ := base64.RawURLEncoding.DecodeString(jwk.Y)
+				if len(x) != tt.coordSize || len(y) != tt.coordSize {
+					t.Errorf("expected %d byte coordinates, got %d and %d", tt.coordSize, len(x), len(y))
+				}
+			}
+
+			again, _ := NewJWK(tt.publicKey)
+			if jwk.Kid == "" || again.Kid != jwk.Kid {
+				t.Errorf("expected a stable kid, got %q and %q", jwk.Kid, again.Kid)
+			}
+		})
+	}
+}
+
+func TestMarshalPublicKeyPEM(t *testing.T) {
+	rsaKeyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	eccKeyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECC key pair: %v", err)
+	}
+
+	ed25519KeyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key pair: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		publicKey interface {
+			Equal(stdcrypto.PublicKey) bool
+		}
+	}{
+		{
+			name:      "success - RSA key",
+			publicKey: rsaKeyPair.Public,
+		},
+		{
+			name:      "success - ECDSA key",
+			publicKey: eccKeyPair.Public,
+		},
+		{
+			name:      "success - Ed25519 key",
+			publicKey: ed25519KeyPair.Public,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := MarshalPublicKeyPEM(tt.publicKey)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			block, _ := pem.Decode(encoded)
+			if block == nil || block.Type != "PUBLIC KEY" {
+				t.Fatalf("expected a PUBLIC KEY PEM block, got %q", encoded)
+			}
+			parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				t.Fatalf("failed to parse SubjectPublicKeyInfo: %v", err)
+			}
+			if !tt.publicKey.Equal(parsed) {
+				t.Error("parsed public key does not match")
+			}
+
+			decoded, err := ParsePublicKeyPEM(encoded)
+			if err != nil {
+				t.Fatalf("failed to decode PEM: %v", err)
+			}
+			if !tt.publicKey.Equal(decoded) {
+				t.Error("decoded public key does not match")
+			}
+		})
+	}
+
+	if _, err := ParsePublicKeyPEM([]byte("not PEM")); !errors.Is(err, ErrNoPEMBlock) {
+		t.Errorf("expected ErrNoPEMBlock, got %v", err)
+	}
+	if _, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: []byte{0x30}})); !errors.Is(err, ErrUnsupportedPEMType) {
+		t.Errorf("expected ErrUnsupportedPEMType, got %v", err)
+	}
+
+	if _, err := MarshalPublicKeyDER((*ecdsa.PrivateKey)(nil)); !errors.Is(err, ErrUnsupportedPublicKey) {
+		t.Errorf("expected ErrUnsupportedPublicKey for a private key, got %v", err)
+	}
+}
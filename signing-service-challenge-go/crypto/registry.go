@@ -0,0 +1,343 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Names of the built-in algorithms
+const (
+	AlgorithmNameRSA     = "RSA"
+	AlgorithmNameECDSA   = "ECDSA"
+	AlgorithmNameEd25519 = "Ed25519"
+)
+
+var (
+	ErrUnknownAlgorithm           = errors.New("unknown signature algorithm")
+	ErrAlgorithmAlreadyRegistered = errors.New("signature algorithm already registered")
+	ErrUnsupportedKeyOption       = errors.New("key option not supported by this algorithm")
+	ErrKeyTypeMismatch            = errors.New("key does not belong to this algorithm")
+)
+
+// KeyOptions carries the algorithm specific parameters of a key: those chosen
+// at generation time and those that govern how it signs. Algorithms reject
+// options they do not support.
+type KeyOptions struct {
+	Bits          int    // RSA modulus size in bits
+	Curve         string // ECDSA curve name
+	Scheme        string // RSA signature scheme
+	Deterministic bool   // ECDSA nonces derived as in RFC 6979 instead of drawn at random
+	Encoding      string // ECDSA signature encoding
+}
+
+// Algorithm bundles everything the service needs to know about a signature
+// algorithm: how to generate, use and store its keys. Keys are passed around
+// as interface{} so that callers never have to switch on the key type.
+type Algorithm interface {
+	// Name is the identifier clients use to select the algorithm.
+	Name() string
+	// ValidateOptions reports whether opts can be used to generate a key.
+	ValidateOptions(opts KeyOptions) error
+	// GenerateKey returns a new public and private key.
+	GenerateKey(opts KeyOptions) (publicKey, privateKey interface{}, err error)
+	// NewSigner returns a Signer for the private key.
+	NewSigner(privateKey interface{}, opts KeyOptions) (Signer, error)
+	// NewVerifier returns a Verifier for the public key.
+	NewVerifier(publicKey interface{}, opts KeyOptions) (Verifier, error)
+	// MarshalPrivateKey PEM encodes the private key and its public key for storage.
+	MarshalPrivateKey(privateKey interface{}) (publicPEM, privatePEM []byte, err error)
+	// UnmarshalPrivateKey decodes a private key written by MarshalPrivateKey
+	// and derives its public key.
+	UnmarshalPrivateKey(privatePEM []byte) (publicKey, privateKey interface{}, err error)
+}
+
+// Registry maps algorithm names to their implementation.
+type Registry struct {
+	algorithms map[string]Algorithm
+	mu         sync.RWMutex
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		algorithms: make(map[string]Algorithm),
+	}
+}
+
+// DefaultRegistry holds the built-in algorithms. Further algorithms can be
+// added with Register.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	for _, algorithm := range []Algorithm{rsaAlgorithm{}, ecdsaAlgorithm{}, ed25519Algorithm{}} {
+		if err := DefaultRegistry.Register(algorithm); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds an algorithm. It returns ErrAlgorithmAlreadyRegistered if an
+// algorithm with the same name exists.
+func (r *Registry) Register(algorithm Algorithm) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.algorithms[algorithm.Name()]; exists {
+		return fmt.Errorf("%w: %s", ErrAlgorithmAlreadyRegistered, algorithm.Name())
+	}
+	r.algorithms[algorithm.Name()] = algorithm
+	return nil
+}
+
+// Lookup returns the algorithm with the given name, or ErrUnknownAlgorithm.
+func (r *Registry) Lookup(name string) (Algorithm, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	algorithm, ok := r.algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+	}
+	return algorithm, nil
+}
+
+// Names returns the names of all registered algorithms in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.algorithms))
+	for name := range r.algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds an algorithm to the DefaultRegistry.
+func Register(algorithm Algorithm) error {
+	return DefaultRegistry.Register(algorithm)
+}
+
+// Lookup returns an algorithm of the DefaultRegistry.
+func Lookup(name string) (Algorithm, error) {
+	return DefaultRegistry.Lookup(name)
+}
+
+// rsaAlgorithm implements Algorithm for RSA with PSS or PKCS#1 v1.5 signatures.
+type rsaAlgorithm struct{}
+
+func (rsaAlgorithm) Name() string {
+	return AlgorithmNameRSA
+}
+
+func (rsaAlgorithm) ValidateOptions(opts KeyOptions) error {
+	if opts.Curve != "" {
+		return fmt.Errorf("%w: RSA does not use a curve", ErrUnsupportedKeyOption)
+	}
+	if opts.Bits != 0 && !ValidRSAKeySize(opts.Bits) {
+		return ErrInvalidRSAKeySize
+	}
+	if !ValidRSAScheme(opts.Scheme) {
+		return ErrUnsupportedRSAScheme
+	}
+	if opts.Deterministic {
+		return fmt.Errorf("%w: use the PKCS1v15 scheme for deterministic RSA signatures", ErrUnsupportedKeyOption)
+	}
+	if opts.Encoding != "" {
+		return fmt.Errorf("%w: RSA signatures have a single encoding", ErrUnsupportedKeyOption)
+	}
+	return nil
+}
+
+func (a rsaAlgorithm) GenerateKey(opts KeyOptions) (interface{}, interface{}, error) {
+	if err := a.ValidateOptions(opts); err != nil {
+		return nil, nil, err
+	}
+	keyPair, err := (&RSAGenerator{Bits: opts.Bits}).Generate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair.Public, keyPair.Private, nil
+}
+
+func (rsaAlgorithm) NewSigner(privateKey interface{}, opts KeyOptions) (Signer, error) {
+	key, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+	if !ValidRSAScheme(opts.Scheme) {
+		return nil, ErrUnsupportedRSAScheme
+	}
+	return NewRSASigner(key, opts.Scheme), nil
+}
+
+func (rsaAlgorithm) NewVerifier(publicKey interface{}, opts KeyOptions) (Verifier, error) {
+	key, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+	if !ValidRSAScheme(opts.Scheme) {
+		return nil, ErrUnsupportedRSAScheme
+	}
+	return NewRSAVerifier(key, opts.Scheme), nil
+}
+
+func (rsaAlgorithm) MarshalPrivateKey(privateKey interface{}) ([]byte, []byte, error) {
+	key, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, ErrKeyTypeMismatch
+	}
+	marshaler := NewRSAMarshaler()
+	return marshaler.Marshal(RSAKeyPair{Public: &key.PublicKey, Private: key})
+}
+
+func (rsaAlgorithm) UnmarshalPrivateKey(privatePEM []byte) (interface{}, interface{}, error) {
+	marshaler := NewRSAMarshaler()
+	keyPair, err := marshaler.Unmarshal(privatePEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair.Public, keyPair.Private, nil
+}
+
+// ecdsaAlgorithm implements Algorithm for ECDSA with DER or raw signatures.
+type ecdsaAlgorithm struct{}
+
+func (ecdsaAlgorithm) Name() string {
+	return AlgorithmNameECDSA
+}
+
+func (ecdsaAlgorithm) ValidateOptions(opts KeyOptions) error {
+	if opts.Bits != 0 {
+		return fmt.Errorf("%w: the ECDSA key size is given by the curve", ErrUnsupportedKeyOption)
+	}
+	if opts.Scheme != "" {
+		return fmt.Errorf("%w: ECDSA has no signature schemes", ErrUnsupportedKeyOption)
+	}
+	if !ValidSignatureEncoding(opts.Encoding) {
+		return ErrUnsupportedSignatureEncoding
+	}
+	if opts.Curve != "" {
+		if _, err := ParseCurve(opts.Curve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a ecdsaAlgorithm) GenerateKey(opts KeyOptions) (interface{}, interface{}, error) {
+	if err := a.ValidateOptions(opts); err != nil {
+		return nil, nil, err
+	}
+	keyPair, err := (&ECCGenerator{Curve: opts.Curve}).Generate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair.Public, keyPair.Private, nil
+}
+
+func (ecdsaAlgorithm) NewSigner(privateKey interface{}, opts KeyOptions) (Signer, error) {
+	key, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+	if !ValidSignatureEncoding(opts.Encoding) {
+		return nil, ErrUnsupportedSignatureEncoding
+	}
+
+	signer := NewECDSASigner(key)
+	if opts.Deterministic {
+		signer = NewDeterministicECDSASigner(key)
+	}
+	if opts.Encoding == SignatureEncodingRaw {
+		return NewRawECDSASigner(signer), nil
+	}
+	return signer, nil
+}
+
+func (ecdsaAlgorithm) NewVerifier(publicKey interface{}, opts KeyOptions) (Verifier, error) {
+	key, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+	return NewECDSAVerifier(key), nil
+}
+
+func (ecdsaAlgorithm) MarshalPrivateKey(privateKey interface{}) ([]byte, []byte, error) {
+	key, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, ErrKeyTypeMismatch
+	}
+	return NewECCMarshaler().Encode(ECCKeyPair{Public: &key.PublicKey, Private: key})
+}
+
+func (ecdsaAlgorithm) UnmarshalPrivateKey(privatePEM []byte) (interface{}, interface{}, error) {
+	keyPair, err := NewECCMarshaler().Decode(privatePEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair.Public, keyPair.Private, nil
+}
+
+// ed25519Algorithm implements Algorithm for pure Ed25519.
+type ed25519Algorithm struct{}
+
+func (ed25519Algorithm) Name() string {
+	return AlgorithmNameEd25519
+}
+
+func (ed25519Algorithm) ValidateOptions(opts KeyOptions) error {
+	if opts != (KeyOptions{}) {
+		return fmt.Errorf("%w: Ed25519 keys have no options", ErrUnsupportedKeyOption)
+	}
+	return nil
+}
+
+func (a ed25519Algorithm) GenerateKey(opts KeyOptions) (interface{}, interface{}, error) {
+	if err := a.ValidateOptions(opts); err != nil {
+		return nil, nil, err
+	}
+	keyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair.Public, keyPair.Private, nil
+}
+
+func (ed25519Algorithm) NewSigner(privateKey interface{}, opts KeyOptions) (Signer, error) {
+	key, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+	return NewEd25519Signer(key), nil
+}
+
+func (ed25519Algorithm) NewVerifier(publicKey interface{}, opts KeyOptions) (Verifier, error) {
+	key, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
+	return NewEd25519Verifier(key), nil
+}
+
+func (ed25519Algorithm) MarshalPrivateKey(privateKey interface{}) ([]byte, []byte, error) {
+	key, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, nil, ErrKeyTypeMismatch
+	}
+	return NewEd25519Marshaler().Encode(Ed25519KeyPair{Public: key.Public().(ed25519.PublicKey), Private: key})
+}
+
+func (ed25519Algorithm) UnmarshalPrivateKey(privatePEM []byte) (interface{}, interface{}, error) {
+	keyPair, err := NewEd25519Marshaler().Decode(privatePEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair.Public, keyPair.Private, nil
+}
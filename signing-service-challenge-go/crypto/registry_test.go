@@ -0,0 +1,140 @@
+package crypto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		wantError error
+	}{
+		{name: "success - RSA", algorithm: AlgorithmNameRSA},
+		{name: "success - ECDSA", algorithm: AlgorithmNameECDSA},
+		{name: "success - Ed25519", algorithm: AlgorithmNameEd25519},
+		{name: "error - unknown algorithm", algorithm: "DSA", wantError: ErrUnknownAlgorithm},
+		{name: "error - names are case sensitive", algorithm: "rsa", wantError: ErrUnknownAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, err := Lookup(tt.algorithm)
+
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("expected error %v, got %v", tt.wantError, err)
+			}
+			if tt.wantError == nil && algorithm.Name() != tt.algorithm {
+				t.Errorf("expected algorithm %q, got %q", tt.algorithm, algorithm.Name())
+			}
+		})
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.Register(ed25519Algorithm{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := registry.Register(rsaAlgorithm{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := registry.Register(rsaAlgorithm{}); !errors.Is(err, ErrAlgorithmAlreadyRegistered) {
+		t.Errorf("expected ErrAlgorithmAlreadyRegistered, got %v", err)
+	}
+
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{AlgorithmNameEd25519, AlgorithmNameRSA}) {
+		t.Errorf("unexpected names %v", names)
+	}
+}
+
+func TestAlgorithm_ValidateOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		options   KeyOptions
+		wantError error
+	}{
+		{name: "success - RSA defaults", algorithm: AlgorithmNameRSA},
+		{name: "success - RSA key size", algorithm: AlgorithmNameRSA, options: KeyOptions{Bits: 4096}},
+		{name: "error - RSA weak key size", algorithm: AlgorithmNameRSA, options: KeyOptions{Bits: 1024}, wantError: ErrInvalidRSAKeySize},
+		{name: "error - RSA curve", algorithm: AlgorithmNameRSA, options: KeyOptions{Curve: CurveP256}, wantError: ErrUnsupportedKeyOption},
+		{name: "success - RSA PKCS#1 v1.5 scheme", algorithm: AlgorithmNameRSA, options: KeyOptions{Scheme: RSASchemePKCS1v15}},
+		{name: "error - RSA unknown scheme", algorithm: AlgorithmNameRSA, options: KeyOptions{Scheme: "OAEP"}, wantError: ErrUnsupportedRSAScheme},
+		{name: "error - ECDSA scheme", algorithm: AlgorithmNameECDSA, options: KeyOptions{Scheme: RSASchemePSS}, wantError: ErrUnsupportedKeyOption},
+		{name: "success - ECDSA curve", algorithm: AlgorithmNameECDSA, options: KeyOptions{Curve: CurveP521}},
+		{name: "error - ECDSA unknown curve", algorithm: AlgorithmNameECDSA, options: KeyOptions{Curve: "P-192"}, wantError: ErrUnsupportedCurve},
+		{name: "error - ECDSA key size", algorithm: AlgorithmNameECDSA, options: KeyOptions{Bits: 2048}, wantError: ErrUnsupportedKeyOption},
+		{name: "success - ECDSA deterministic", algorithm: AlgorithmNameECDSA, options: KeyOptions{Deterministic: true}},
+		{name: "success - ECDSA raw encoding", algorithm: AlgorithmNameECDSA, options: KeyOptions{Encoding: SignatureEncodingRaw}},
+		{name: "error - ECDSA unknown encoding", algorithm: AlgorithmNameECDSA, options: KeyOptions{Encoding: "PEM"}, wantError: ErrUnsupportedSignatureEncoding},
+		{name: "error - RSA encoding", algorithm: AlgorithmNameRSA, options: KeyOptions{Encoding: SignatureEncodingRaw}, wantError: ErrUnsupportedKeyOption},
+		{name: "error - RSA deterministic", algorithm: AlgorithmNameRSA, options: KeyOptions{Deterministic: true}, wantError: ErrUnsupportedKeyOption},
+		{name: "success - Ed25519 defaults", algorithm: AlgorithmNameEd25519},
+		{name: "error - Ed25519 curve", algorithm: AlgorithmNameEd25519, options: KeyOptions{Curve: CurveP256}, wantError: ErrUnsupportedKeyOption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, err := Lookup(tt.algorithm)
+			if err != nil {
+				t.Fatalf("failed to look up algorithm: %v", err)
+			}
+
+			if err := algorithm.ValidateOptions(tt.options); !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestAlgorithm_RoundTrip(t *testing.T) {
+	for _, name := range DefaultRegistry.Names() {
+		t.Run(name, func(t *testing.T) {
+			algorithm, _ := Lookup(name)
+
+			publicKey, privateKey, err := algorithm.GenerateKey(KeyOptions{})
+			if err != nil {
+				t.Fatalf("failed to generate key: %v", err)
+			}
+
+			_, privatePEM, err := algorithm.MarshalPrivateKey(privateKey)
+			if err != nil {
+				t.Fatalf("failed to marshal private key: %v", err)
+			}
+			decodedPublicKey, decodedPrivateKey, err := algorithm.UnmarshalPrivateKey(privatePEM)
+			if err != nil {
+				t.Fatalf("failed to unmarshal private key: %v", err)
+			}
+
+			signer, err := algorithm.NewSigner(decodedPrivateKey, KeyOptions{})
+			if err != nil {
+				t.Fatalf("failed to create signer: %v", err)
+			}
+			signature, err := signer.Sign([]byte("0_data_ZGV2aWNl"))
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+
+			for _, key := range []interface{}{publicKey, decodedPublicKey} {
+				verifier, err := algorithm.NewVerifier(key, KeyOptions{})
+				if err != nil {
+					t.Fatalf("failed to create verifier: %v", err)
+				}
+				if err := verifier.Verify([]byte("0_data_ZGV2aWNl"), signature); err != nil {
+					t.Errorf("expected valid signature, got %v", err)
+				}
+			}
+
+			if _, err := algorithm.NewSigner("not a key", KeyOptions{}); !errors.Is(err, ErrKeyTypeMismatch) {
+				t.Errorf("expected ErrKeyTypeMismatch, got %v", err)
+			}
+			if _, err := algorithm.NewVerifier(privateKey, KeyOptions{}); !errors.Is(err, ErrKeyTypeMismatch) {
+				t.Errorf("expected ErrKeyTypeMismatch for a private key, got %v", err)
+			}
+		})
+	}
+}
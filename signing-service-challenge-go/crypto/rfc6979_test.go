@@ -0,0 +1,155 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// rfc6979Key builds the private key of an RFC 6979 test vector.
+func rfc6979Key(t *testing.T, curve elliptic.Curve, x string) *ecdsa.PrivateKey {
+	t.Helper()
+
+	d, _ := hex.DecodeString(x)
+	ecdhCurve, err := ecdhCurve(curve)
+	if err != nil {
+		t.Fatalf("unsupported curve: %v", err)
+	}
+	ecdhKey, err := ecdhCurve.NewPrivateKey(d)
+	if err != nil {
+		t.Fatalf("invalid private key: %v", err)
+	}
+	point := ecdhKey.PublicKey().Bytes()
+	size := (len(point) - 1) / 2
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(point[1 : 1+size]),
+			Y:     new(big.Int).SetBytes(point[1+size:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+}
+
+// Known answers from RFC 6979, appendix A.2.5 to A.2.7, each using the hash
+// paired with the curve.
+func TestDeterministicECDSASigner_KnownAnswers(t *testing.T) {
+	const (
+		p256Key = "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721"
+		p384Key = "6B9D3DAD2E1B8C1C05B19875B6659F4DE23C3B667BF297BA9AA47740787137D896D5724E4C70A825F872C9EA60D2EDF5"
+		p521Key = "00FAD06DAA62BA3B25D2FB40133DA757205DE67F5BB0018FEE8C86E1B68C7E75CAA896EB32F1F47C70855836A6D16FCC1466F6D8FBEC67DB89EC0C08B0E996B83538"
+	)
+
+	tests := []struct {
+		name    string
+		curve   elliptic.Curve
+		key     string
+		message string
+		r, s    string
+	}{
+		{
+			name:    "P-256 SHA-256 sample",
+			curve:   elliptic.P256(),
+			key:     p256Key,
+			message: "sample",
+			r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			name:    "P-256 SHA-256 test",
+			curve:   elliptic.P256(),
+			key:     p256Key,
+			message: "test",
+			r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+		{
+			name:    "P-384 SHA-384 sample",
+			curve:   elliptic.P384(),
+			key:     p384Key,
+			message: "sample",
+			r:       "94EDBB92A5ECB8AAD4736E56C691916B3F88140666CE9FA73D64C4EA95AD133C81A648152E44ACF96E36DD1E80FABE46",
+			s:       "99EF4AEB15F178CEA1FE40DB2603138F130E740A19624526203B6351D0A3A94FA329C145786E679E7B82C71A38628AC8",
+		},
+		{
+			name:    "P-384 SHA-384 test",
+			curve:   elliptic.P384(),
+			key:     p384Key,
+			message: "test",
+			r:       "8203B63D3C853E8D77227FB377BCF7B7B772E97892A80F36AB775D509D7A5FEB0542A7F0812998DA8F1DD3CA3CF023DB",
+			s:       "DDD0760448D42D8A43AF45AF836FCE4DE8BE06B485E9B61B827C2F13173923E06A739F040649A667BF3B828246BAA5A5",
+		},
+		{
+			name:    "P-521 SHA-512 sample",
+			curve:   elliptic.P521(),
+			key:     p521Key,
+			message: "sample",
+			r:       "00C328FAFCBD79DD77850370C46325D987CB525569FB63C5D3BC53950E6D4C5F174E25A1EE9017B5D450606ADD152B534931D7D4E8455CC91F9B15BF05EC36E377FA",
+			s:       "00617CCE7CF5064806C467F678D3B4080D6F1CC50AF26CA209417308281B68AF282623EAA63E5B5C0723D8B8C37FF0777B1A20F8CCB1DCCC43997F1EE0E44DA4A67A",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateKey := rfc6979Key(t, tt.curve, tt.key)
+
+			signature, err := NewDeterministicECDSASigner(privateKey).Sign([]byte(tt.message))
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			var rs struct{ R, S *big.Int }
+			if _, err := asn1.Unmarshal(signature, &rs); err != nil {
+				t.Fatalf("signature is not ASN.1 encoded: %v", err)
+			}
+			if want, _ := new(big.Int).SetString(tt.r, 16); rs.R.Cmp(want) != 0 {
+				t.Errorf("expected r %s, got %X", tt.r, rs.R)
+			}
+			if want, _ := new(big.Int).SetString(tt.s, 16); rs.S.Cmp(want) != 0 {
+				t.Errorf("expected s %s, got %X", tt.s, rs.S)
+			}
+
+			if err := NewECDSAVerifier(&privateKey.PublicKey).Verify([]byte(tt.message), signature); err != nil {
+				t.Errorf("expected a valid signature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeterministicECDSASigner_Reproducible(t *testing.T) {
+	keyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECC key pair: %v", err)
+	}
+	data := []byte("0_data_ZGV2aWNl")
+
+	first, _ := NewDeterministicECDSASigner(keyPair.Private).Sign(data)
+	second, _ := NewDeterministicECDSASigner(keyPair.Private).Sign(data)
+	if !bytes.Equal(first, second) {
+		t.Error("expected identical signatures for the same key and data")
+	}
+
+	other, _ := NewDeterministicECDSASigner(keyPair.Private).Sign([]byte("1_data_ZGV2aWNl"))
+	if bytes.Equal(first, other) {
+		t.Error("expected different signatures for different data")
+	}
+}
+
+// ecdhCurve returns the crypto/ecdh counterpart of a supported curve.
+func ecdhCurve(curve elliptic.Curve) (ecdh.Curve, error) {
+	switch curve {
+	case elliptic.P256():
+		return ecdh.P256(), nil
+	case elliptic.P384():
+		return ecdh.P384(), nil
+	case elliptic.P521():
+		return ecdh.P521(), nil
+	default:
+		return nil, ErrUnsupportedCurve
+	}
+}
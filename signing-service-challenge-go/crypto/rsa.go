@@ -5,10 +5,37 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
-	"encoding/pem"
+	"errors"
 )
 
+// RSA signature schemes
+const (
+	// RSASchemePSS is RSA-PSS with a salt as long as the SHA-256 digest.
+	RSASchemePSS = "PSS"
+	// RSASchemePKCS1v15 is the deterministic RSASSA-PKCS1-v1_5 scheme.
+	RSASchemePKCS1v15 = "PKCS1v15"
+)
+
+// DefaultRSAScheme is the scheme used when none is given.
+const DefaultRSAScheme = RSASchemePSS
+
+// ErrUnsupportedRSAScheme is returned for schemes other than PSS and PKCS1v15.
+var ErrUnsupportedRSAScheme = errors.New("RSA scheme must be PSS or PKCS1v15")
+
+// ValidRSAScheme reports whether scheme is a supported RSA scheme. The empty
+// scheme stands for DefaultRSAScheme.
+func ValidRSAScheme(scheme string) bool {
+	return scheme == "" || scheme == RSASchemePSS || scheme == RSASchemePKCS1v15
+}
+
+// RSAJWSAlgorithm returns the JWS algorithm (RFC 7518) of an RSA scheme.
+func RSAJWSAlgorithm(scheme string) string {
+	if scheme == RSASchemePKCS1v15 {
+		return "RS256"
+	}
+	return "PS256"
+}
+
 // RSAKeyPair is a DTO that holds RSA private and public keys.
 type RSAKeyPair struct {
 	Public  *rsa.PublicKey
@@ -18,23 +45,70 @@ type RSAKeyPair struct {
 // RSASigner implements RSA signing
 type RSASigner struct {
 	privateKey *rsa.PrivateKey
+	scheme     string
 }
 
-// NewRSASigner creates a new RSA signer
-func NewRSASigner(privateKey *rsa.PrivateKey) *RSASigner {
+// NewRSASigner creates a new RSA signer for the given scheme; an empty scheme
+// means DefaultRSAScheme.
+func NewRSASigner(privateKey *rsa.PrivateKey, scheme string) *RSASigner {
 	return &RSASigner{
 		privateKey: privateKey,
+		scheme:     scheme,
 	}
 }
 
-// Sign signs the data using RSA-PSS
+// Sign signs the SHA-256 digest of the data using the signer's scheme
 func (s *RSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
 	hash := sha256.Sum256(dataToBeSigned)
-	signature, err := rsa.SignPSS(rand.Reader, s.privateKey, crypto.SHA256, hash[:], nil)
+
+	switch s.scheme {
+	case "", RSASchemePSS:
+		return rsa.SignPSS(rand.Reader, s.privateKey, crypto.SHA256, hash[:], &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+		})
+	case RSASchemePKCS1v15:
+		return rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, hash[:])
+	default:
+		return nil, ErrUnsupportedRSAScheme
+	}
+}
+
+// RSAVerifier verifies RSA signatures created by RSASigner
+type RSAVerifier struct {
+	publicKey *rsa.PublicKey
+	scheme    string
+}
+
+// NewRSAVerifier creates a new RSA verifier for the given scheme; an empty
+// scheme means DefaultRSAScheme.
+func NewRSAVerifier(publicKey *rsa.PublicKey, scheme string) *RSAVerifier {
+	return &RSAVerifier{
+		publicKey: publicKey,
+		scheme:    scheme,
+	}
+}
+
+// Verify checks an RSA signature over the data using the verifier's scheme.
+// PSS signatures must use a salt as long as the digest, as RSASigner makes
+// them.
+func (v *RSAVerifier) Verify(signedData, signature []byte) error {
+	hash := sha256.Sum256(signedData)
+
+	var err error
+	switch v.scheme {
+	case "", RSASchemePSS:
+		err = rsa.VerifyPSS(v.publicKey, crypto.SHA256, hash[:], signature, &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+		})
+	case RSASchemePKCS1v15:
+		err = rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, hash[:], signature)
+	default:
+		return ErrUnsupportedRSAScheme
+	}
 	if err != nil {
-		return nil, err
+		return ErrInvalidSignature
 	}
-	return signature, nil
+	return nil
 }
 
 // RSAMarshaler can encode and decode an RSA key pair.
@@ -45,32 +119,24 @@ func NewRSAMarshaler() RSAMarshaler {
 	return RSAMarshaler{}
 }
 
-// Marshal takes an RSAKeyPair and encodes it to be written on disk.
+// Marshal takes an RSAKeyPair and encodes it to be written on disk, the
+// private key as PKCS#8 and the public key as SubjectPublicKeyInfo.
 // It returns the public and the private key as a byte slice.
 func (m *RSAMarshaler) Marshal(keyPair RSAKeyPair) ([]byte, []byte, error) {
-	privateKeyBytes := x509.MarshalPKCS1PrivateKey(keyPair.Private)
-	publicKeyBytes := x509.MarshalPKCS1PublicKey(keyPair.Public)
-
-	encodedPrivate := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA_PRIVATE_KEY",
-		Bytes: privateKeyBytes,
-	})
-
-	encodePublic := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA_PUBLIC_KEY",
-		Bytes: publicKeyBytes,
-	})
-
-	return encodePublic, encodedPrivate, nil
+	return NewPKCS8Marshaler().Marshal(keyPair.Private)
 }
 
-// Unmarshal takes an encoded RSA private key and transforms it into a rsa.PrivateKey.
+// Unmarshal takes an encoded RSA private key, PKCS#8 or PKCS#1, and
+// transforms it into an RSAKeyPair.
 func (m *RSAMarshaler) Unmarshal(privateKeyBytes []byte) (*RSAKeyPair, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	_, parsed, err := NewPKCS8Marshaler().Unmarshal(privateKeyBytes)
 	if err != nil {
 		return nil, err
 	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrKeyTypeMismatch
+	}
 
 	return &RSAKeyPair{
 		Private: privateKey,
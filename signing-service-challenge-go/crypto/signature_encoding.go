@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// ECDSA signature encodings
+const (
+	// SignatureEncodingDER is the ASN.1 DER SEQUENCE { r, s } of X.509 and
+	// most TLS stacks.
+	SignatureEncodingDER = "DER"
+	// SignatureEncodingRaw is the fixed-length IEEE P1363 concatenation r||s
+	// used by JWS (RFC 7518, section 3.4).
+	SignatureEncodingRaw = "RAW"
+)
+
+// DefaultSignatureEncoding is the encoding used when none is given.
+const DefaultSignatureEncoding = SignatureEncodingDER
+
+var (
+	ErrUnsupportedSignatureEncoding = errors.New("signature encoding must be DER or RAW")
+	ErrMalformedECDSASignature      = errors.New("malformed ECDSA signature")
+)
+
+// ValidSignatureEncoding reports whether encoding is a supported ECDSA
+// signature encoding. The empty encoding stands for DefaultSignatureEncoding.
+func ValidSignatureEncoding(encoding string) bool {
+	return encoding == "" || encoding == SignatureEncodingDER || encoding == SignatureEncodingRaw
+}
+
+// ecdsaSignature is the ASN.1 structure of a DER encoded ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// scalarSize returns the byte length of r and s in the raw encoding.
+func scalarSize(curve elliptic.Curve) int {
+	return (curve.Params().N.BitLen() + 7) / 8
+}
+
+// ECDSASignatureToRaw converts a DER encoded ECDSA signature to the raw r||s
+// encoding, each half left-padded to the size of the curve order.
+func ECDSASignatureToRaw(curve elliptic.Curve, der []byte) ([]byte, error) {
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrMalformedECDSASignature
+	}
+
+	size := scalarSize(curve)
+	if !validScalar(sig.R, curve) || !validScalar(sig.S, curve) {
+		return nil, ErrMalformedECDSASignature
+	}
+
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// ECDSASignatureToDER converts a raw r||s ECDSA signature to the DER encoding.
+func ECDSASignatureToDER(curve elliptic.Curve, raw []byte) ([]byte, error) {
+	size := scalarSize(curve)
+	if len(raw) != 2*size {
+		return nil, ErrMalformedECDSASignature
+	}
+
+	sig := ecdsaSignature{
+		R: new(big.Int).SetBytes(raw[:size]),
+		S: new(big.Int).SetBytes(raw[size:]),
+	}
+	if !validScalar(sig.R, curve) || !validScalar(sig.S, curve) {
+		return nil, ErrMalformedECDSASignature
+	}
+	return asn1.Marshal(sig)
+}
+
+// validScalar reports whether v lies in [1, N-1] for the curve order N.
+func validScalar(v *big.Int, curve elliptic.Curve) bool {
+	return v.Sign() > 0 && v.Cmp(curve.Params().N) < 0
+}
+
+// RawECDSASigner produces raw r||s signatures by converting the output of an
+// ECDSASigner, whichever way that signer draws its nonces.
+type RawECDSASigner struct {
+	signer *ECDSASigner
+}
+
+// NewRawECDSASigner creates a signer returning raw r||s signatures
+func NewRawECDSASigner(signer *ECDSASigner) *RawECDSASigner {
+	return &RawECDSASigner{
+		signer: signer,
+	}
+}
+
+// Sign signs the data and returns the raw r||s encoding of the signature
+func (s *RawECDSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	der, err := s.signer.Sign(dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
+	return ECDSASignatureToRaw(s.signer.privateKey.Curve, der)
+}
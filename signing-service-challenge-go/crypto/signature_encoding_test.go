@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"testing"
+)
+
+func TestECDSASignatureEncoding_RoundTrip(t *testing.T) {
+	tests := []struct {
+		curve   string
+		rawSize int
+	}{
+		{curve: CurveP256, rawSize: 64},
+		{curve: CurveP384, rawSize: 96},
+		{curve: CurveP521, rawSize: 132},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.curve, func(t *testing.T) {
+			keyPair, err := (&ECCGenerator{Curve: tt.curve}).Generate()
+			if err != nil {
+				t.Fatalf("failed to generate key pair: %v", err)
+			}
+			curve := keyPair.Public.Curve
+			data := []byte("0_data_ZGV2aWNl")
+
+			der, err := NewECDSASigner(keyPair.Private).Sign(data)
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			raw, err := ECDSASignatureToRaw(curve, der)
+			if err != nil {
+				t.Fatalf("failed to convert to raw: %v", err)
+			}
+			if len(raw) != tt.rawSize {
+				t.Errorf("expected %d raw bytes, got %d", tt.rawSize, len(raw))
+			}
+			back, err := ECDSASignatureToDER(curve, raw)
+			if err != nil {
+				t.Fatalf("failed to convert to DER: %v", err)
+			}
+			if !bytes.Equal(back, der) {
+				t.Error("expected the DER signature to survive a round trip")
+			}
+
+			rawSignature, err := NewRawECDSASigner(NewECDSASigner(keyPair.Private)).Sign(data)
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			if len(rawSignature) != tt.rawSize {
+				t.Errorf("expected a %d byte raw signature, got %d", tt.rawSize, len(rawSignature))
+			}
+
+			verifier := NewECDSAVerifier(keyPair.Public)
+			for name, signature := range map[string][]byte{"DER": der, "converted raw": raw, "raw": rawSignature} {
+				if err := verifier.Verify(data, signature); err != nil {
+					t.Errorf("expected %s signature to verify, got %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestECDSASignatureEncoding_Malformed(t *testing.T) {
+	curve := elliptic.P256()
+	n := curve.Params().N.FillBytes(make([]byte, 32))
+
+	tests := []struct {
+		name    string
+		convert func() ([]byte, error)
+	}{
+		{
+			name:    "DER - garbage",
+			convert: func() ([]byte, error) { return ECDSASignatureToRaw(curve, []byte("not a signature")) },
+		},
+		{
+			name: "DER - trailing data",
+			convert: func() ([]byte, error) {
+				return ECDSASignatureToRaw(curve, []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01, 0x00})
+			},
+		},
+		{
+			name: "DER - zero scalar",
+			convert: func() ([]byte, error) {
+				return ECDSASignatureToRaw(curve, []byte{0x30, 0x06, 0x02, 0x01, 0x00, 0x02, 0x01, 0x01})
+			},
+		},
+		{
+			name:    "raw - wrong length",
+			convert: func() ([]byte, error) { return ECDSASignatureToDER(curve, make([]byte, 63)) },
+		},
+		{
+			name:    "raw - zero scalars",
+			convert: func() ([]byte, error) { return ECDSASignatureToDER(curve, make([]byte, 64)) },
+		},
+		{
+			name:    "raw - scalar not below the order",
+			convert: func() ([]byte, error) { return ECDSASignatureToDER(curve, append(n, n...)) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.convert(); err != ErrMalformedECDSASignature {
+				t.Errorf("expected ErrMalformedECDSASignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRawECDSASigner_Deterministic(t *testing.T) {
+	keyPair, err := (&ECCGenerator{Curve: CurveP256}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	data := []byte("0_data_ZGV2aWNl")
+
+	der, _ := NewDeterministicECDSASigner(keyPair.Private).Sign(data)
+	raw, _ := NewRawECDSASigner(NewDeterministicECDSASigner(keyPair.Private)).Sign(data)
+	want, _ := ECDSASignatureToRaw(keyPair.Public.Curve, der)
+	if !bytes.Equal(raw, want) {
+		t.Error("expected the raw signature to encode the deterministic DER signature")
+	}
+}
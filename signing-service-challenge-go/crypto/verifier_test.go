@@ -0,0 +1,253 @@
+package crypto
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKeyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	eccKeyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECC key pair: %v", err)
+	}
+	p256KeyPair, err := (&ECCGenerator{Curve: CurveP256}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate P-256 key pair: %v", err)
+	}
+	p521KeyPair, err := (&ECCGenerator{Curve: CurveP521}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate P-521 key pair: %v", err)
+	}
+	ed25519KeyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key pair: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		signer     Signer
+		verifier   Verifier
+		signedData string
+		verifyData string
+		wantError  bool
+	}{
+		{
+			name:       "success - RSA signature",
+			signer:     NewRSASigner(rsaKeyPair.Private, RSASchemePSS),
+			verifier:   NewRSAVerifier(rsaKeyPair.Public, RSASchemePSS),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "success - RSA PKCS#1 v1.5 signature",
+			signer:     NewRSASigner(rsaKeyPair.Private, RSASchemePKCS1v15),
+			verifier:   NewRSAVerifier(rsaKeyPair.Public, RSASchemePKCS1v15),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "success - RSA default scheme is PSS",
+			signer:     NewRSASigner(rsaKeyPair.Private, ""),
+			verifier:   NewRSAVerifier(rsaKeyPair.Public, RSASchemePSS),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "error - RSA PSS signature checked as PKCS#1 v1.5",
+			signer:     NewRSASigner(rsaKeyPair.Private, RSASchemePSS),
+			verifier:   NewRSAVerifier(rsaKeyPair.Public, RSASchemePKCS1v15),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+			wantError:  true,
+		},
+		{
+			name:       "error - RSA PKCS#1 v1.5 signature checked as PSS",
+			signer:     NewRSASigner(rsaKeyPair.Private, RSASchemePKCS1v15),
+			verifier:   NewRSAVerifier(rsaKeyPair.Public, RSASchemePSS),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+			wantError:  true,
+		},
+		{
+			name:       "success - ECDSA signature",
+			signer:     NewECDSASigner(eccKeyPair.Private),
+			verifier:   NewECDSAVerifier(eccKeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "success - ECDSA P-256 signature",
+			signer:     NewECDSASigner(p256KeyPair.Private),
+			verifier:   NewECDSAVerifier(p256KeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "success - ECDSA P-521 signature",
+			signer:     NewECDSASigner(p521KeyPair.Private),
+			verifier:   NewECDSAVerifier(p521KeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "success - Ed25519 signature",
+			signer:     NewEd25519Signer(ed25519KeyPair.Private),
+			verifier:   NewEd25519Verifier(ed25519KeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+		},
+		{
+			name:       "error - Ed25519 tampered data",
+			signer:     NewEd25519Signer(ed25519KeyPair.Private),
+			verifier:   NewEd25519Verifier(ed25519KeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_date_ZGV2aWNl",
+			wantError:  true,
+		},
+		{
+			name:       "error - ECDSA key of another curve",
+			signer:     NewECDSASigner(p256KeyPair.Private),
+			verifier:   NewECDSAVerifier(p521KeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_data_ZGV2aWNl",
+			wantError:  true,
+		},
+		{
+			name:       "error - RSA tampered data",
+			signer:     NewRSASigner(rsaKeyPair.Private, RSASchemePSS),
+			verifier:   NewRSAVerifier(rsaKeyPair.Public, RSASchemePSS),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_date_ZGV2aWNl",
+			wantError:  true,
+		},
+		{
+			name:       "error - ECDSA tampered data",
+			signer:     NewECDSASigner(eccKeyPair.Private),
+			verifier:   NewECDSAVerifier(eccKeyPair.Public),
+			signedData: "0_data_ZGV2aWNl",
+			verifyData: "0_date_ZGV2aWNl",
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signature, err := tt.signer.Sign([]byte(tt.signedData))
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+
+			err = tt.verifier.Verify([]byte(tt.verifyData), signature)
+
+			if tt.wantError {
+				if err != ErrInvalidSignature {
+					t.Errorf("expected ErrInvalidSignature, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestECDSASigner_CurveHash(t *testing.T) {
+	tests := []struct {
+		curve string
+		size  int
+	}{
+		{curve: CurveP256, size: 32},
+		{curve: CurveP384, size: 48},
+		{curve: CurveP521, size: 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.curve, func(t *testing.T) {
+			keyPair, err := (&ECCGenerator{Curve: tt.curve}).Generate()
+			if err != nil {
+				t.Fatalf("failed to generate key pair: %v", err)
+			}
+			hash := CurveHash(keyPair.Public.Curve)
+			if hash.Size() != tt.size {
+				t.Fatalf("expected a %d byte hash for %s, got %d", tt.size, tt.curve, hash.Size())
+			}
+
+			signature, err := NewECDSASigner(keyPair.Private).Sign([]byte("data"))
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+
+			h := hash.New()
+			h.Write([]byte("data"))
+			if !ecdsa.VerifyASN1(keyPair.Public, h.Sum(nil), signature) {
+				t.Errorf("signature was not made over the %v digest", hash)
+			}
+		})
+	}
+}
+
+func TestRSASigner_Schemes(t *testing.T) {
+	keyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	data := []byte("0_data_ZGV2aWNl")
+	digest := sha256.Sum256(data)
+
+	t.Run("PSS salt length equals hash size", func(t *testing.T) {
+		signature, err := NewRSASigner(keyPair.Private, RSASchemePSS).Sign(data)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		err = rsa.VerifyPSS(keyPair.Public, stdcrypto.SHA256, digest[:], signature, &rsa.PSSOptions{
+			SaltLength: sha256.Size,
+		})
+		if err != nil {
+			t.Errorf("expected a %d byte salt, got %v", sha256.Size, err)
+		}
+	})
+
+	t.Run("PSS verification rejects other salt lengths", func(t *testing.T) {
+		signature, err := rsa.SignPSS(rand.Reader, keyPair.Private, stdcrypto.SHA256, digest[:], &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthAuto,
+		})
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		if err := NewRSAVerifier(keyPair.Public, RSASchemePSS).Verify(data, signature); err == nil {
+			t.Error("expected a signature with a maximum length salt to be rejected")
+		}
+	})
+
+	t.Run("PKCS#1 v1.5 is deterministic", func(t *testing.T) {
+		signer := NewRSASigner(keyPair.Private, RSASchemePKCS1v15)
+		first, err := signer.Sign(data)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		second, err := signer.Sign(data)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Error("expected identical PKCS#1 v1.5 signatures")
+		}
+		if err := rsa.VerifyPKCS1v15(keyPair.Public, stdcrypto.SHA256, digest[:], first); err != nil {
+			t.Errorf("expected a valid PKCS#1 v1.5 signature, got %v", err)
+		}
+	})
+
+	t.Run("unknown scheme", func(t *testing.T) {
+		if _, err := NewRSASigner(keyPair.Private, "OAEP").Sign(data); err != ErrUnsupportedRSAScheme {
+			t.Errorf("expected ErrUnsupportedRSAScheme, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"math/big"
+)
+
+// ZeroizePrivateKey overwrites the secret material of a private key in place,
+// so that it no longer lingers in memory once the key is dropped. The key must
+// not be used afterwards. Unsupported key types are left untouched.
+//
+// This is best effort: copies the runtime or the standard library made, for
+// example of precomputed values, cannot be reached.
+func ZeroizePrivateKey(privateKey interface{}) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		zeroizeInt(key.D)
+		for _, prime := range key.Primes {
+			zeroizeInt(prime)
+		}
+		zeroizeInt(key.Precomputed.Dp)
+		zeroizeInt(key.Precomputed.Dq)
+		zeroizeInt(key.Precomputed.Qinv)
+	case *ecdsa.PrivateKey:
+		zeroizeInt(key.D)
+	case ed25519.PrivateKey:
+		clear(key)
+	}
+}
+
+// zeroizeInt overwrites the words of x and sets it to zero.
+func zeroizeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	clear(x.Bits())
+	x.SetInt64(0)
+}
+
+// ClonePrivateKey returns a deep copy of a private key that shares no secret
+// material with it, so that either one can be zeroized while the other is
+// still in use. Unsupported key types are returned as they are.
+func ClonePrivateKey(privateKey interface{}) interface{} {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		clone := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: cloneInt(key.N), E: key.E},
+			D:         cloneInt(key.D),
+			Primes:    make([]*big.Int, len(key.Primes)),
+		}
+		for i, prime := range key.Primes {
+			clone.Primes[i] = cloneInt(prime)
+		}
+		// Precomputing again is slow; the clone recomputes its values on use.
+		return clone
+	case *ecdsa.PrivateKey:
+		return &ecdsa.PrivateKey{PublicKey: key.PublicKey, D: cloneInt(key.D)}
+	case ed25519.PrivateKey:
+		return append(ed25519.PrivateKey(nil), key...)
+	default:
+		return privateKey
+	}
+}
+
+// cloneInt returns a copy of x, keeping nil as nil.
+func cloneInt(x *big.Int) *big.Int {
+	if x == nil {
+		return nil
+	}
+	return new(big.Int).Set(x)
+}
@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestZeroizePrivateKey(t *testing.T) {
+	rsaKeyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	eccKeyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key pair: %v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		key    interface{}
+		zeroed func() bool
+	}{
+		{
+			name: "RSA",
+			key:  rsaKeyPair.Private,
+			zeroed: func() bool {
+				key := rsaKeyPair.Private
+				return key.D.Sign() == 0 && key.Primes[0].Sign() == 0 && key.Primes[1].Sign() == 0 &&
+					key.Precomputed.Dp.Sign() == 0 && key.Precomputed.Dq.Sign() == 0 && key.Precomputed.Qinv.Sign() == 0
+			},
+		},
+		{
+			name: "ECDSA",
+			key:  eccKeyPair.Private,
+			zeroed: func() bool {
+				return eccKeyPair.Private.D.Sign() == 0
+			},
+		},
+		{
+			name: "Ed25519",
+			key:  ed25519Key,
+			zeroed: func() bool {
+				for _, b := range ed25519Key {
+					if b != 0 {
+						return false
+					}
+				}
+				return true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ZeroizePrivateKey(tt.key)
+
+			if !tt.zeroed() {
+				t.Error("expected private key material to be zeroized")
+			}
+		})
+	}
+}
+
+func TestClonePrivateKey(t *testing.T) {
+	rsaKeyPair, err := (&RSAGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+	eccKeyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key pair: %v", err)
+	}
+	ed25519Public, ed25519Key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		key       interface{}
+		publicKey interface{}
+	}{
+		{name: "RSA", algorithm: AlgorithmNameRSA, key: rsaKeyPair.Private, publicKey: rsaKeyPair.Public},
+		{name: "ECDSA", algorithm: AlgorithmNameECDSA, key: eccKeyPair.Private, publicKey: eccKeyPair.Public},
+		{name: "Ed25519", algorithm: AlgorithmNameEd25519, key: ed25519Key, publicKey: ed25519Public},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, err := Lookup(tt.algorithm)
+			if err != nil {
+				t.Fatalf("failed to look up algorithm: %v", err)
+			}
+
+			clone := ClonePrivateKey(tt.key)
+			ZeroizePrivateKey(tt.key)
+
+			signer, err := algorithm.NewSigner(clone, KeyOptions{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			signature, err := signer.Sign([]byte("data"))
+			if err != nil {
+				t.Fatalf("expected the clone to survive zeroizing the original, got %v", err)
+			}
+			verifier, err := algorithm.NewVerifier(tt.publicKey, KeyOptions{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := verifier.Verify([]byte("data"), signature); err != nil {
+				t.Errorf("expected the clone to sign with the original key, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+var (
+	// ErrMalformedSecuredData is returned when signed data does not follow the
+	// <signature_counter>_<data_to_be_signed>_<last_signature_base64_encoded> format.
+	ErrMalformedSecuredData = errors.New("signed data is malformed")
+	// ErrMalformedSignature is returned when a signature is not valid base64.
+	ErrMalformedSignature = errors.New("signature is not valid base64")
+	// ErrJournalMismatch is returned when journaled records do not continue a
+	// device's chain.
+	ErrJournalMismatch = errors.New("journal does not continue the device's chain")
+)
+
+// SecuredData is the parsed form of the string a device signs.
+type SecuredData struct {
+	Counter       int
+	Data          string
+	LastSignature string
+}
+
+// ParseSecuredData splits signed data into its counter, data and last signature.
+// The data itself may contain underscores; the counter ends at the first one and
+// the base64 encoded last signature, which never contains one, starts after the
+// last one.
+func ParseSecuredData(securedData string) (SecuredData, error) {
+	first := strings.Index(securedData, "_")
+	last := strings.LastIndex(securedData, "_")
+	if first < 0 || first == last {
+		return SecuredData{}, ErrMalformedSecuredData
+	}
+
+	counter, err := strconv.Atoi(securedData[:first])
+	if err != nil || counter < 0 {
+		return SecuredData{}, ErrMalformedSecuredData
+	}
+
+	return SecuredData{
+		Counter:       counter,
+		Data:          securedData[first+1 : last],
+		LastSignature: securedData[last+1:],
+	}, nil
+}
+
+// ChainLink is one signed element of a device's signature chain.
+type ChainLink struct {
+	SignedData string `json:"signed_data"`
+	Signature  string `json:"signature"` // base64 encoded signature
+}
+
+// ChainError describes the first link of a chain that failed verification.
+type ChainError struct {
+	Index   int    // position of the link in the verified sequence
+	Counter int    // counter embedded in the link, or -1 if it could not be parsed
+	Reason  string // why the link is broken
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("chain broken at link %d (counter %d): %s", e.Index, e.Counter, e.Reason)
+}
+
+// VerifySignature checks a single link against verifier and returns its parsed
+// secured data. It returns ErrMalformedSecuredData, ErrMalformedSignature or
+// crypto.ErrInvalidSignature if the link is not valid. The parsed secured data
+// is returned whenever it could be parsed, even if the signature is invalid.
+func VerifySignature(verifier crypto.Verifier, link ChainLink) (SecuredData, error) {
+	securedData, err := ParseSecuredData(link.SignedData)
+	if err != nil {
+		return SecuredData{}, err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(link.Signature)
+	if err != nil {
+		return securedData, ErrMalformedSignature
+	}
+	if err := verifier.Verify([]byte(link.SignedData), signature); err != nil {
+		return securedData, err
+	}
+
+	return securedData, nil
+}
+
+// VerifierFunc returns the verifier for the key that signed a counter.
+type VerifierFunc func(counter int) (crypto.Verifier, error)
+
+// VerifyChain checks a sequence of links signed by the device with the given
+// ID. Every signature must verify against verifier, and every link must carry
+// the counter following its predecessor and embed the predecessor's signature.
+// A link with counter 0 must embed the base64 encoded device ID instead. The
+// first link may start later in the chain, in which case its predecessor is
+// not checked. No link may follow a decommission record, which seals the
+// chain. The first broken link is returned as a *ChainError.
+func VerifyChain(deviceID string, verifier crypto.Verifier, links []ChainLink) error {
+	return VerifyChainFunc(deviceID, func(int) (crypto.Verifier, error) {
+		return verifier, nil
+	}, links)
+}
+
+// VerifyChainFunc is like VerifyChain, but verifies each link against the
+// verifier verifierFor returns for its counter, so that a chain spanning key
+// rotations checks every signature against the key in use at the time. Errors
+// of verifierFor are returned as they are.
+func VerifyChainFunc(deviceID string, verifierFor VerifierFunc, links []ChainLink) error {
+	genesis := base64.StdEncoding.EncodeToString([]byte(deviceID))
+
+	for i, link := range links {
+		securedData, err := ParseSecuredData(link.SignedData)
+		if err != nil {
+			return &ChainError{Index: i, Counter: -1, Reason: err.Error()}
+		}
+		broken := func(reason string) error {
+			return &ChainError{Index: i, Counter: securedData.Counter, Reason: reason}
+		}
+
+		verifier, err := verifierFor(securedData.Counter)
+		if err != nil {
+			return err
+		}
+		if _, err := VerifySignature(verifier, link); err != nil {
+			return broken(err.Error())
+		}
+
+		if securedData.Counter == 0 && securedData.LastSignature != genesis {
+			return broken("first signature does not reference the device ID")
+		}
+		if i == 0 {
+			continue
+		}
+
+		previous, _ := ParseSecuredData(links[i-1].SignedData)
+		if previous.Data == DecommissionData {
+			return broken("chain continues after the decommission record")
+		}
+		if securedData.Counter != previous.Counter+1 {
+			return broken(fmt.Sprintf("counter does not follow previous counter %d", previous.Counter))
+		}
+		if securedData.LastSignature != links[i-1].Signature {
+			return broken("last signature does not match previous signature")
+		}
+	}
+
+	return nil
+}
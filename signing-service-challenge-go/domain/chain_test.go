@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+func TestParseSecuredData(t *testing.T) {
+	tests := []struct {
+		name        string
+		securedData string
+		expected    SecuredData
+		wantError   bool
+	}{
+		{
+			name:        "success - simple data",
+			securedData: "3_payment_c2ln",
+			expected:    SecuredData{Counter: 3, Data: "payment", LastSignature: "c2ln"},
+		},
+		{
+			name:        "success - data containing underscores",
+			securedData: "0_a_b_c_ZGV2aWNl",
+			expected:    SecuredData{Counter: 0, Data: "a_b_c", LastSignature: "ZGV2aWNl"},
+		},
+		{
+			name:        "success - empty data",
+			securedData: "1__c2ln",
+			expected:    SecuredData{Counter: 1, Data: "", LastSignature: "c2ln"},
+		},
+		{
+			name:        "error - missing separators",
+			securedData: "3_payment",
+			wantError:   true,
+		},
+		{
+			name:        "error - counter is not a number",
+			securedData: "x_payment_c2ln",
+			wantError:   true,
+		},
+		{
+			name:        "error - negative counter",
+			securedData: "-1_payment_c2ln",
+			wantError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseSecuredData(tt.securedData)
+
+			if tt.wantError {
+				if !errors.Is(err, ErrMalformedSecuredData) {
+					t.Errorf("expected ErrMalformedSecuredData, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if parsed != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	keyPair, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	otherKeyPair, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+
+	device := NewDevice("chain-device", AlgorithmECDSA, "", keyPair.Public, keyPair.Private)
+	signer := crypto.NewECDSASigner(keyPair.Private)
+	var chain []ChainLink
+	for _, data := range []string{"first", "second_with_underscore", "third", "fourth"} {
+		record, err := device.Sign(signer, data, nil)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		chain = append(chain, ChainLink{SignedData: record.SignedData, Signature: record.Signature})
+	}
+
+	// forged signs arbitrary secured data with the device key, so that only the
+	// chain linkage is wrong.
+	forged := func(securedData string) ChainLink {
+		signature, err := signer.Sign([]byte(securedData))
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		return ChainLink{SignedData: securedData, Signature: base64.StdEncoding.EncodeToString(signature)}
+	}
+	replace := func(index int, link ChainLink) []ChainLink {
+		links := append([]ChainLink(nil), chain...)
+		links[index] = link
+		return links
+	}
+
+	tests := []struct {
+		name            string
+		verifier        crypto.Verifier
+		links           []ChainLink
+		expectedIndex   int // -1 if the chain is valid
+		expectedCounter int
+	}{
+		{
+			name:          "success - full chain",
+			verifier:      crypto.NewECDSAVerifier(keyPair.Public),
+			links:         chain,
+			expectedIndex: -1,
+		},
+		{
+			name:          "success - chain starting after genesis",
+			verifier:      crypto.NewECDSAVerifier(keyPair.Public),
+			links:         chain[2:],
+			expectedIndex: -1,
+		},
+		{
+			name:          "success - empty chain",
+			verifier:      crypto.NewECDSAVerifier(keyPair.Public),
+			links:         nil,
+			expectedIndex: -1,
+		},
+		{
+			name:            "error - wrong public key",
+			verifier:        crypto.NewECDSAVerifier(otherKeyPair.Public),
+			links:           chain,
+			expectedIndex:   0,
+			expectedCounter: 0,
+		},
+		{
+			name:            "error - tampered signed data",
+			verifier:        crypto.NewECDSAVerifier(keyPair.Public),
+			links:           replace(1, ChainLink{SignedData: chain[1].SignedData + "x", Signature: chain[1].Signature}),
+			expectedIndex:   1,
+			expectedCounter: 1,
+		},
+		{
+			name:            "error - signature not base64",
+			verifier:        crypto.NewECDSAVerifier(keyPair.Public),
+			links:           replace(2, ChainLink{SignedData: chain[2].SignedData, Signature: "not base64!"}),
+			expectedIndex:   2,
+			expectedCounter: 2,
+		},
+		{
+			name:            "error - malformed signed data",
+			verifier:        crypto.NewECDSAVerifier(keyPair.Public),
+			links:           replace(3, forged("no separators")),
+			expectedIndex:   3,
+			expectedCounter: -1,
+		},
+		{
+			name:            "error - genesis does not reference device ID",
+			verifier:        crypto.NewECDSAVerifier(keyPair.Public),
+			links:           replace(0, forged("0_first_"+base64.StdEncoding.EncodeToString([]byte("other-device")))),
+			expectedIndex:   0,
+			expectedCounter: 0,
+		},
+		{
+			name:            "error - counter gap",
+			verifier:        crypto.NewECDSAVerifier(keyPair.Public),
+			links:           []ChainLink{chain[0], chain[2]},
+			expectedIndex:   1,
+			expectedCounter: 2,
+		},
+		{
+			name:            "error - last signature does not link",
+			verifier:        crypto.NewECDSAVerifier(keyPair.Public),
+			links:           replace(2, forged("2_third_"+chain[0].Signature)),
+			expectedIndex:   2,
+			expectedCounter: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyChain(device.ID, tt.verifier, tt.links)
+
+			if tt.expectedIndex < 0 {
+				if err != nil {
+					t.Errorf("expected valid chain, got %v", err)
+				}
+				return
+			}
+
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("expected *ChainError, got %v", err)
+			}
+			if chainErr.Index != tt.expectedIndex {
+				t.Errorf("expected broken link at index %d, got %d (%s)", tt.expectedIndex, chainErr.Index, chainErr.Reason)
+			}
+			if chainErr.Counter != tt.expectedCounter {
+				t.Errorf("expected broken link counter %d, got %d", tt.expectedCounter, chainErr.Counter)
+			}
+		})
+	}
+}
@@ -2,24 +2,50 @@ package domain
 
 import (
 	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 )
 
+// SignatureAlgorithm names an algorithm of the crypto registry.
 type SignatureAlgorithm string
 
 const (
-	AlgorithmRSA   SignatureAlgorithm = "RSA"
-	AlgorithmECDSA SignatureAlgorithm = "ECDSA"
+	AlgorithmRSA     SignatureAlgorithm = crypto.AlgorithmNameRSA
+	AlgorithmECDSA   SignatureAlgorithm = crypto.AlgorithmNameECDSA
+	AlgorithmEd25519 SignatureAlgorithm = crypto.AlgorithmNameEd25519
 )
 
 type Device struct {
-	ID               string             `json:"id"`
-	Algorithm        SignatureAlgorithm `json:"algorithm"`
-	Label            string             `json:"label,omitempty"`
-	SignatureCounter int                `json:"signature_counter"`
-	PublicKey        interface{}        `json:"-"`                        // Can be *rsa.PublicKey or *ecdsa.PublicKey
-	PrivateKey       interface{}        `json:"-"`                        // Can be *rsa.PrivateKey or *ecdsa.PrivateKey
-	LastSignature    string             `json:"last_signature,omitempty"` // base64 encoded
-	mu               sync.Mutex         `json:"-"`                        // Mutex to ensure thread-safe counter increment
+	ID                string             `json:"id"`
+	Algorithm         SignatureAlgorithm `json:"algorithm"`
+	Label             string             `json:"label,omitempty"`
+	Metadata          map[string]string  `json:"metadata,omitempty"`           // client defined key/value pairs, see ValidateMetadata
+	Curve             string             `json:"curve,omitempty"`              // named curve of ECDSA devices, e.g. "P-256"
+	RSAScheme         string             `json:"rsa_scheme,omitempty"`         // signature scheme of RSA devices, empty means crypto.DefaultRSAScheme
+	Deterministic     bool               `json:"deterministic,omitempty"`      // ECDSA devices sign with RFC 6979 nonces
+	SignatureEncoding string             `json:"signature_encoding,omitempty"` // signature encoding of ECDSA devices, empty means crypto.DefaultSignatureEncoding
+	SignatureCounter  int                `json:"signature_counter"`
+	PublicKey         interface{}        `json:"-"`                        // Can be *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+	PrivateKey        interface{}        `json:"-"`                        // Can be *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+	LastSignature     string             `json:"last_signature,omitempty"` // base64 encoded
+	RetiredKeys       []RetiredKey       `json:"-"`                        // public keys replaced by key rotations, oldest first
+	Status            DeviceStatus       `json:"status,omitempty"`         // lifecycle state, empty means StatusActive
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`     // last change of label, metadata, lifecycle state or key
+	LastSignedAt      time.Time          `json:"last_signed_at"` // zero if the device has not signed yet
+	mu                sync.Mutex         `json:"-"`              // Mutex to ensure thread-safe counter increment
+	rotating          bool               // a key rotation is being persisted, see RotateKey
+	rotated           *sync.Cond         // signalled on d.mu when rotating is cleared
+}
+
+// RetiredKey is a public key a device signed with before a key rotation,
+// together with the range of signature counters it signed.
+type RetiredKey struct {
+	PublicKey    interface{} // same types as Device.PublicKey
+	FirstCounter int         // first counter signed with the key
+	LastCounter  int         // last counter signed with the key: the rotation record
+	RetiredAt    time.Time
 }
 
 // SignatureResponse represents the response returned after signing data
@@ -27,3 +53,28 @@ type SignatureResponse struct {
 	Signature  string `json:"signature"`   // base64 encoded signature
 	SignedData string `json:"signed_data"` // the secured data that was signed
 }
+
+// SignatureRecord is a single signing event, as kept in the signature journal.
+type SignatureRecord struct {
+	DeviceID   string             `json:"device_id"`
+	Counter    int                `json:"counter"`
+	Data       string             `json:"data"`        // the data_to_be_signed provided by the client
+	SignedData string             `json:"signed_data"` // the secured data that was signed
+	Signature  string             `json:"signature"`   // base64 encoded signature
+	Algorithm  SignatureAlgorithm `json:"algorithm"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// Response returns the client facing view of the signing event.
+func (r *SignatureRecord) Response() SignatureResponse {
+	return SignatureResponse{
+		Signature:  r.Signature,
+		SignedData: r.SignedData,
+	}
+}
+
+// JournalFunc durably records a signing event in the signature journal.
+// Devices call it under their lock before committing the event, so records are
+// journaled in counter order and a failure leaves the device untouched. A nil
+// JournalFunc records nothing.
+type JournalFunc func(record SignatureRecord) error
@@ -2,21 +2,36 @@ package domain
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 )
 
-// NewDevice creates a new signature device
+// NewDevice creates a new signature device. The curve of an ECDSA device is
+// taken from its public key.
 func NewDevice(id string, algorithm SignatureAlgorithm, label string, publicKey, privateKey interface{}) *Device {
+	var curve string
+	if key, ok := publicKey.(*ecdsa.PublicKey); ok {
+		curve, _ = crypto.CurveName(key.Curve)
+	}
+	now := time.Now().UTC()
+
 	return &Device{
 		ID:               id,
 		Algorithm:        algorithm,
 		Label:            label,
+		Curve:            curve,
 		SignatureCounter: 0,
 		PublicKey:        publicKey,
 		PrivateKey:       privateKey,
 		LastSignature:    "",
+		Status:           StatusActive,
+		CreatedAt:        now,
+		UpdatedAt:        now,
 	}
 }
 
@@ -26,13 +41,22 @@ func (d *Device) GetSecuredDataToSign(dataToBeSigned string) string {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	lastSig := d.LastSignature
-	if lastSig == "" {
+	return d.securedDataToSign(dataToBeSigned)
+}
+
+// securedDataToSign builds the secured data string. The caller must hold d.mu.
+func (d *Device) securedDataToSign(dataToBeSigned string) string {
+	return securedData(d.ID, d.SignatureCounter, dataToBeSigned, d.LastSignature)
+}
+
+// securedData builds the secured data string of a device's chain link.
+func securedData(deviceID string, counter int, dataToBeSigned, lastSignature string) string {
+	if lastSignature == "" {
 		// Base case: use base64 encoded device ID
-		lastSig = base64.StdEncoding.EncodeToString([]byte(d.ID))
+		lastSignature = base64.StdEncoding.EncodeToString([]byte(deviceID))
 	}
 
-	return fmt.Sprintf("%d_%s_%s", d.SignatureCounter, dataToBeSigned, lastSig)
+	return fmt.Sprintf("%d_%s_%s", counter, dataToBeSigned, lastSignature)
 }
 
 // IncrementCounter increments the signature counter and updates the last signature
@@ -43,52 +67,236 @@ func (d *Device) IncrementCounter(newSignature string) {
 
 	d.SignatureCounter++
 	d.LastSignature = newSignature
+	d.LastSignedAt = time.Now().UTC()
+}
+
+// Sign reserves the current signature counter, builds the secured data, signs it
+// with the given signer, journals the record and commits the new counter and
+// last signature as one atomic operation. The device lock is held throughout,
+// so concurrent calls on the same device can never sign the same counter or
+// skip a link in the chain, and records reach the journal in counter order. If
+// signing or journaling fails the device state is left untouched. The returned
+// record describes the signing event. Devices that are not active fail with
+// ErrDeviceSuspended, ErrDeviceRetired or ErrDeviceDeleted, and data reserved
+// for records the service signs itself fails with ErrReservedData.
+func (d *Device) Sign(signer crypto.Signer, dataToBeSigned string, journal JournalFunc) (*SignatureRecord, error) {
+	d.lockForSigning()
+	defer d.mu.Unlock()
+
+	if err := d.checkSignableLocked(dataToBeSigned); err != nil {
+		return nil, err
+	}
+	return d.signLocked(signer, dataToBeSigned, journal)
 }
 
-// GetRSAPrivateKey returns the private key as *rsa.PrivateKey
-func (d *Device) GetRSAPrivateKey() (*rsa.PrivateKey, error) {
-	if d.Algorithm != AlgorithmRSA {
-		return nil, fmt.Errorf("device algorithm is not RSA")
+// SignWithCurrentKey is like Sign, but creates the signer for the device's
+// current private key under the device lock. A concurrent key rotation can
+// therefore never slip in between and leave a signature made with the retired
+// key at a counter that belongs to the new one.
+func (d *Device) SignWithCurrentKey(newSigner SignerFactory, dataToBeSigned string, journal JournalFunc) (*SignatureRecord, error) {
+	d.lockForSigning()
+	defer d.mu.Unlock()
+
+	if err := d.checkSignableLocked(dataToBeSigned); err != nil {
+		return nil, err
 	}
-	key, ok := d.PrivateKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("private key is not of type *rsa.PrivateKey")
+	signer, err := newSigner(d.PrivateKey, d.KeyOptions())
+	if err != nil {
+		return nil, err
 	}
-	return key, nil
+	return d.signLocked(signer, dataToBeSigned, journal)
 }
 
-// GetECDSAPrivateKey returns the private key as *ecdsa.PrivateKey
-func (d *Device) GetECDSAPrivateKey() (*ecdsa.PrivateKey, error) {
-	if d.Algorithm != AlgorithmECDSA {
-		return nil, fmt.Errorf("device algorithm is not ECDSA")
+// checkSignableLocked checks that clients may sign dataToBeSigned with the
+// device. The caller must hold d.mu.
+func (d *Device) checkSignableLocked(dataToBeSigned string) error {
+	if err := d.checkActiveLocked(); err != nil {
+		return err
 	}
-	key, ok := d.PrivateKey.(*ecdsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("private key is not of type *ecdsa.PrivateKey")
+	if IsReservedData(dataToBeSigned) {
+		return ErrReservedData
 	}
-	return key, nil
+	return nil
 }
 
-// GetRSAPublicKey returns the public key as *rsa.PublicKey
-func (d *Device) GetRSAPublicKey() (*rsa.PublicKey, error) {
-	if d.Algorithm != AlgorithmRSA {
-		return nil, fmt.Errorf("device algorithm is not RSA")
+// signLocked signs the next link of the chain and journals it before
+// committing the new counter. The caller must hold d.mu.
+func (d *Device) signLocked(signer crypto.Signer, dataToBeSigned string, journal JournalFunc) (*SignatureRecord, error) {
+	securedData := d.securedDataToSign(dataToBeSigned)
+
+	signature, err := signer.Sign([]byte(securedData))
+	if err != nil {
+		return nil, err
 	}
-	key, ok := d.PublicKey.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("public key is not of type *rsa.PublicKey")
+
+	record := &SignatureRecord{
+		DeviceID:   d.ID,
+		Counter:    d.SignatureCounter,
+		Data:       dataToBeSigned,
+		SignedData: securedData,
+		Signature:  base64.StdEncoding.EncodeToString(signature),
+		Algorithm:  d.Algorithm,
+		CreatedAt:  time.Now().UTC(),
 	}
-	return key, nil
+	if journal != nil {
+		if err := journal(*record); err != nil {
+			return nil, fmt.Errorf("record signature: %w", err)
+		}
+	}
+
+	d.SignatureCounter++
+	d.LastSignature = record.Signature
+	d.LastSignedAt = record.CreatedAt
+
+	return record, nil
 }
 
-// GetECDSAPublicKey returns the public key as *ecdsa.PublicKey
-func (d *Device) GetECDSAPublicKey() (*ecdsa.PublicKey, error) {
-	if d.Algorithm != AlgorithmECDSA {
-		return nil, fmt.Errorf("device algorithm is not ECDSA")
+// Reconcile moves the device past signing events that reached the journal but
+// not the stored device, as after a crash between the two writes. records are
+// the device's journal records from its current counter on, in counter order;
+// each must be the next link of the device's chain, or Reconcile fails with
+// ErrJournalMismatch and leaves the device untouched. A decommission record
+// retires the device. The new key of a rotation record was never stored, so
+// the device keeps its current key and the rotation has to be repeated. It
+// reports whether the device changed.
+func (d *Device) Reconcile(records []SignatureRecord) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	counter, lastSignature, lastSignedAt, status := d.SignatureCounter, d.LastSignature, d.LastSignedAt, d.statusLocked()
+	for _, record := range records {
+		if record.DeviceID != d.ID || record.Counter != counter ||
+			record.SignedData != securedData(d.ID, counter, record.Data, lastSignature) {
+			return false, fmt.Errorf("%w at counter %d", ErrJournalMismatch, record.Counter)
+		}
+		counter++
+		lastSignature = record.Signature
+		lastSignedAt = record.CreatedAt
+		if record.Data == DecommissionData && status != StatusDeleted {
+			status = StatusRetired
+		}
+	}
+	if len(records) == 0 {
+		return false, nil
 	}
-	key, ok := d.PublicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("public key is not of type *ecdsa.PublicKey")
+
+	d.SignatureCounter = counter
+	d.LastSignature = lastSignature
+	d.LastSignedAt = lastSignedAt
+	if status != d.statusLocked() {
+		d.Status = status
+		d.touchLocked()
+	}
+	return true, nil
+}
+
+// touchLocked records a change of the device's details, lifecycle state or
+// key in UpdatedAt. The caller must hold d.mu.
+func (d *Device) touchLocked() {
+	d.UpdatedAt = time.Now().UTC()
+}
+
+// Snapshot returns a copy of the device taken under its lock, so that callers
+// such as handlers and listings see a consistent counter and last signature
+// even while the device keeps signing. The copy has no private key; use Clone
+// where the key is needed.
+func (d *Device) Snapshot() *Device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.snapshotLocked()
+}
+
+// Clone is like Snapshot, but the copy also holds a deep copy of the private
+// key, so that Delete can zeroize the device's key while the copy is still
+// being encoded or exported. Callers done with the copy must zeroize its key
+// with crypto.ZeroizePrivateKey.
+func (d *Device) Clone() *Device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	clone := d.snapshotLocked()
+	clone.PrivateKey = crypto.ClonePrivateKey(d.PrivateKey)
+	return clone
+}
+
+// snapshotLocked returns a copy of the device without its private key. The
+// caller must hold d.mu.
+func (d *Device) snapshotLocked() *Device {
+	return &Device{
+		ID:                d.ID,
+		Algorithm:         d.Algorithm,
+		Label:             d.Label,
+		Metadata:          cloneMetadata(d.Metadata),
+		Curve:             d.Curve,
+		RSAScheme:         d.RSAScheme,
+		Deterministic:     d.Deterministic,
+		SignatureEncoding: d.SignatureEncoding,
+		SignatureCounter:  d.SignatureCounter,
+		PublicKey:         d.PublicKey,
+		LastSignature:     d.LastSignature,
+		RetiredKeys:       append([]RetiredKey(nil), d.RetiredKeys...),
+		Status:            d.Status,
+		CreatedAt:         d.CreatedAt,
+		UpdatedAt:         d.UpdatedAt,
+		LastSignedAt:      d.LastSignedAt,
+	}
+}
+
+// Counter returns the next signature counter of the device.
+func (d *Device) Counter() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.SignatureCounter
+}
+
+// cloneMetadata returns a copy of metadata, keeping nil as nil.
+func cloneMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		clone[key] = value
+	}
+	return clone
+}
+
+// KeyOptions returns the options that govern how the device's key signs and
+// verifies.
+func (d *Device) KeyOptions() crypto.KeyOptions {
+	return crypto.KeyOptions{
+		Curve:         d.Curve,
+		Scheme:        d.RSAScheme,
+		Deterministic: d.Deterministic,
+		Encoding:      d.SignatureEncoding,
+	}
+}
+
+// SetKeyOptions stores the options that govern how the device signs. The
+// curve is not taken from opts; it always follows the key.
+func (d *Device) SetKeyOptions(opts crypto.KeyOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.RSAScheme = opts.Scheme
+	d.Deterministic = opts.Deterministic
+	d.SignatureEncoding = opts.Encoding
+}
+
+// KeySize returns the size in bits of the device's key: the modulus size for
+// RSA, the curve size for ECDSA and 256 for Ed25519. It returns 0 for an
+// unknown key type.
+func (d *Device) KeySize() int {
+	switch key := d.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return len(key) * 8
+	default:
+		return 0
 	}
-	return key, nil
 }
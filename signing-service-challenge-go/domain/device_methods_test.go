@@ -5,12 +5,29 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
+// stubSigner returns the SHA-256 digest of the data as signature, or a fixed error.
+type stubSigner struct {
+	err error
+}
+
+func (s stubSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	digest := sha256.Sum256(dataToBeSigned)
+	return digest[:], nil
+}
+
 func TestGetSecuredDataToSign(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -127,189 +144,394 @@ func TestIncrementCounter_Concurrency(t *testing.T) {
 	}
 }
 
-func TestGetRSAPrivateKey(t *testing.T) {
-	rsaKey, _ := rsa.GenerateKey(rand.Reader, 512)
-	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
-
+func TestSign(t *testing.T) {
 	tests := []struct {
-		name      string
-		device    *Device
-		wantError bool
+		name               string
+		signer             stubSigner
+		journalErr         error
+		initialCounter     int
+		initialSignature   string
+		wantError          bool
+		expectedCounter    int
+		expectedSignedData string
 	}{
 		{
-			name: "success - RSA device",
-			device: &Device{
-				Algorithm:  AlgorithmRSA,
-				PrivateKey: rsaKey,
-			},
-			wantError: false,
+			name:               "success - first signature uses device ID",
+			signer:             stubSigner{},
+			expectedCounter:    1,
+			expectedSignedData: "0_data_" + base64.StdEncoding.EncodeToString([]byte("device-id")),
 		},
 		{
-			name: "error - ECDSA device",
-			device: &Device{
-				Algorithm:  AlgorithmECDSA,
-				PrivateKey: ecdsaKey,
-			},
-			wantError: true,
+			name:               "success - subsequent signature uses last signature",
+			signer:             stubSigner{},
+			initialCounter:     3,
+			initialSignature:   "previousSignature",
+			expectedCounter:    4,
+			expectedSignedData: "3_data_previousSignature",
+		},
+		{
+			name:             "error - signer failure leaves device untouched",
+			signer:           stubSigner{err: errors.New("boom")},
+			initialCounter:   3,
+			initialSignature: "previousSignature",
+			wantError:        true,
+			expectedCounter:  3,
+		},
+		{
+			name:             "error - journal failure leaves device untouched",
+			signer:           stubSigner{},
+			journalErr:       errors.New("disk full"),
+			initialCounter:   3,
+			initialSignature: "previousSignature",
+			wantError:        true,
+			expectedCounter:  3,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			key, err := tt.device.GetRSAPrivateKey()
+			device := &Device{
+				ID:               "device-id",
+				SignatureCounter: tt.initialCounter,
+				LastSignature:    tt.initialSignature,
+			}
+			var journaled []SignatureRecord
+			journal := func(record SignatureRecord) error {
+				if tt.journalErr != nil {
+					return tt.journalErr
+				}
+				journaled = append(journaled, record)
+				return nil
+			}
+
+			response, err := device.Sign(tt.signer, "data", journal)
 
 			if tt.wantError {
 				if err == nil {
 					t.Error("expected error, got nil")
 				}
+				if device.LastSignature != tt.initialSignature {
+					t.Errorf("expected last signature %q, got %q", tt.initialSignature, device.LastSignature)
+				}
 			} else {
 				if err != nil {
-					t.Errorf("expected no error, got %v", err)
+					t.Fatalf("expected no error, got %v", err)
 				}
-				if key == nil {
-					t.Error("expected key, got nil")
+				if response.SignedData != tt.expectedSignedData {
+					t.Errorf("expected signed data %q, got %q", tt.expectedSignedData, response.SignedData)
 				}
+				if device.LastSignature != response.Signature {
+					t.Errorf("expected last signature %q, got %q", response.Signature, device.LastSignature)
+				}
+				if response.Counter != tt.initialCounter || response.Data != "data" {
+					t.Errorf("expected record for counter %d, got %d with data %q", tt.initialCounter, response.Counter, response.Data)
+				}
+				if len(journaled) != 1 || journaled[0] != *response {
+					t.Errorf("expected the record to be journaled, got %+v", journaled)
+				}
+			}
+
+			if device.SignatureCounter != tt.expectedCounter {
+				t.Errorf("expected counter %d, got %d", tt.expectedCounter, device.SignatureCounter)
 			}
 		})
 	}
 }
 
-func TestGetECDSAPrivateKey(t *testing.T) {
-	rsaKey, _ := rsa.GenerateKey(rand.Reader, 512)
-	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+func TestDeviceTimestamps(t *testing.T) {
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	label := "renamed"
 
 	tests := []struct {
-		name      string
-		device    *Device
-		wantError bool
+		name          string
+		change        func(*Device) error
+		expectUpdated bool
+		expectSigned  bool
 	}{
 		{
-			name: "success - ECDSA device",
-			device: &Device{
-				Algorithm:  AlgorithmECDSA,
-				PrivateKey: ecdsaKey,
-			},
-			wantError: false,
+			name:         "sign sets last signed at",
+			change:       func(d *Device) error { _, err := d.Sign(stubSigner{}, "data", nil); return err },
+			expectSigned: true,
 		},
 		{
-			name: "error - RSA device",
-			device: &Device{
-				Algorithm:  AlgorithmRSA,
-				PrivateKey: rsaKey,
-			},
-			wantError: true,
+			name:         "failed sign leaves timestamps untouched",
+			change:       func(d *Device) error { d.Sign(stubSigner{err: errors.New("boom")}, "data", nil); return nil },
+			expectSigned: false,
+		},
+		{
+			name:          "update details sets updated at",
+			change:        func(d *Device) error { return d.UpdateDetails(&label, nil) },
+			expectUpdated: true,
+		},
+		{
+			name:          "suspend sets updated at",
+			change:        func(d *Device) error { return d.Suspend() },
+			expectUpdated: true,
+		},
+		{
+			name:          "retire sets both",
+			change:        func(d *Device) error { _, err := d.Retire(stubSignerFactory(stubSigner{}), nil); return err },
+			expectUpdated: true,
+			expectSigned:  true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			key, err := tt.device.GetECDSAPrivateKey()
+			device := NewDevice("device-id", AlgorithmECDSA, "label", nil, nil)
+			if device.CreatedAt.IsZero() || !device.UpdatedAt.Equal(device.CreatedAt) {
+				t.Fatalf("expected new device with equal created and updated at, got %v and %v", device.CreatedAt, device.UpdatedAt)
+			}
+			if !device.LastSignedAt.IsZero() {
+				t.Fatalf("expected new device without last signed at, got %v", device.LastSignedAt)
+			}
+			device.CreatedAt, device.UpdatedAt = past, past
 
-			if tt.wantError {
-				if err == nil {
-					t.Error("expected error, got nil")
-				}
-			} else {
-				if err != nil {
-					t.Errorf("expected no error, got %v", err)
-				}
-				if key == nil {
-					t.Error("expected key, got nil")
-				}
+			if err := tt.change(device); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !device.CreatedAt.Equal(past) {
+				t.Errorf("expected created at to stay %v, got %v", past, device.CreatedAt)
+			}
+			if updated := !device.UpdatedAt.Equal(past); updated != tt.expectUpdated {
+				t.Errorf("expected updated at changed %v, got %v", tt.expectUpdated, device.UpdatedAt)
+			}
+			if signed := !device.LastSignedAt.IsZero(); signed != tt.expectSigned {
+				t.Errorf("expected last signed at set %v, got %v", tt.expectSigned, device.LastSignedAt)
 			}
 		})
 	}
 }
 
-func TestGetRSAPublicKey(t *testing.T) {
-	rsaKey, _ := rsa.GenerateKey(rand.Reader, 512)
-	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+func TestSign_Concurrency(t *testing.T) {
+	device := &Device{ID: "device-id"}
+
+	var wg sync.WaitGroup
+	iterations := 500
+	responses := make([]*SignatureRecord, iterations)
+
+	// The journal is called under the device lock, so it needs no lock itself
+	var journaled []int
+	journal := func(record SignatureRecord) error {
+		journaled = append(journaled, record.Counter)
+		return nil
+	}
+
+	for i := 0; i < iterations; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			response, err := device.Sign(stubSigner{}, "data", journal)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			responses[index] = response
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := make(map[int]bool, iterations)
+	for _, response := range responses {
+		counter, err := strconv.Atoi(strings.SplitN(response.SignedData, "_", 2)[0])
+		if err != nil {
+			t.Fatalf("failed to parse counter from %q: %v", response.SignedData, err)
+		}
+		if seen[counter] {
+			t.Errorf("counter %d was signed more than once", counter)
+		}
+		seen[counter] = true
+	}
+
+	for i := 0; i < iterations; i++ {
+		if !seen[i] {
+			t.Errorf("counter %d was never signed", i)
+		}
+	}
+
+	if len(journaled) != iterations {
+		t.Fatalf("expected %d journaled records, got %d", iterations, len(journaled))
+	}
+	for i, counter := range journaled {
+		if counter != i {
+			t.Fatalf("expected counter %d journaled at position %d, got %d", i, i, counter)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	// journaled signs three records with one device, then retires it, and
+	// returns the records as its journal would hold them.
+	journaled := func(t *testing.T) []SignatureRecord {
+		t.Helper()
+
+		var records []SignatureRecord
+		journal := func(record SignatureRecord) error {
+			records = append(records, record)
+			return nil
+		}
+		device := &Device{ID: "device-id"}
+		for i := 0; i < 3; i++ {
+			if _, err := device.Sign(stubSigner{}, "data", journal); err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+		}
+		if _, err := device.Retire(stubSignerFactory(stubSigner{}), journal); err != nil {
+			t.Fatalf("failed to retire: %v", err)
+		}
+		return records
+	}
 
 	tests := []struct {
-		name      string
-		device    *Device
-		wantError bool
+		name           string
+		records        func(all []SignatureRecord) []SignatureRecord
+		initialCounter int
+		wantError      error
+		wantChanged    bool
+		wantCounter    int
+		wantStatus     DeviceStatus
 	}{
 		{
-			name: "success - RSA device",
-			device: &Device{
-				Algorithm: AlgorithmRSA,
-				PublicKey: &rsaKey.PublicKey,
-			},
-			wantError: false,
+			name:        "success - nothing journaled past the device",
+			records:     func(all []SignatureRecord) []SignatureRecord { return nil },
+			wantCounter: 0,
+			wantStatus:  StatusActive,
 		},
 		{
-			name: "error - ECDSA device",
-			device: &Device{
-				Algorithm: AlgorithmECDSA,
-				PublicKey: &ecdsaKey.PublicKey,
+			name:        "success - device moves past the journaled signatures",
+			records:     func(all []SignatureRecord) []SignatureRecord { return all[:2] },
+			wantChanged: true,
+			wantCounter: 2,
+			wantStatus:  StatusActive,
+		},
+		{
+			name:        "success - decommission record retires the device",
+			records:     func(all []SignatureRecord) []SignatureRecord { return all },
+			wantChanged: true,
+			wantCounter: 4,
+			wantStatus:  StatusRetired,
+		},
+		{
+			name:        "error - gap in the journaled counters",
+			records:     func(all []SignatureRecord) []SignatureRecord { return all[1:] },
+			wantError:   ErrJournalMismatch,
+			wantCounter: 0,
+			wantStatus:  StatusActive,
+		},
+		{
+			name: "error - record does not continue the chain",
+			records: func(all []SignatureRecord) []SignatureRecord {
+				all[1].SignedData = "1_data_forged"
+				return all
 			},
-			wantError: true,
+			wantError:   ErrJournalMismatch,
+			wantCounter: 0,
+			wantStatus:  StatusActive,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			key, err := tt.device.GetRSAPublicKey()
+			records := tt.records(journaled(t))
+			device := &Device{ID: "device-id", Status: StatusActive}
 
-			if tt.wantError {
-				if err == nil {
-					t.Error("expected error, got nil")
-				}
-			} else {
-				if err != nil {
-					t.Errorf("expected no error, got %v", err)
+			changed, err := device.Reconcile(records)
+
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("expected error %v, got %v", tt.wantError, err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("expected changed %v, got %v", tt.wantChanged, changed)
+			}
+			if device.SignatureCounter != tt.wantCounter {
+				t.Errorf("expected counter %d, got %d", tt.wantCounter, device.SignatureCounter)
+			}
+			if device.Status != tt.wantStatus {
+				t.Errorf("expected status %s, got %s", tt.wantStatus, device.Status)
+			}
+			if tt.wantChanged {
+				last := records[len(records)-1]
+				if device.LastSignature != last.Signature || !device.LastSignedAt.Equal(last.CreatedAt) {
+					t.Errorf("expected last signature %q from the journal, got %q", last.Signature, device.LastSignature)
 				}
-				if key == nil {
-					t.Error("expected key, got nil")
+				// The device continues the journaled chain.
+				record, err := device.Sign(stubSigner{}, "data", nil)
+				if tt.wantStatus == StatusActive && (err != nil || record.Counter != tt.wantCounter) {
+					t.Errorf("expected to sign counter %d, got %v", tt.wantCounter, err)
 				}
 			}
 		})
 	}
 }
 
-func TestGetECDSAPublicKey(t *testing.T) {
-	rsaKey, _ := rsa.GenerateKey(rand.Reader, 512)
-	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+func TestKeySize(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
 
 	tests := []struct {
-		name      string
-		device    *Device
-		wantError bool
+		name     string
+		device   *Device
+		expected int
 	}{
 		{
-			name: "success - ECDSA device",
-			device: &Device{
-				Algorithm: AlgorithmECDSA,
-				PublicKey: &ecdsaKey.PublicKey,
-			},
-			wantError: false,
+			name:     "success - RSA modulus size",
+			device:   &Device{Algorithm: AlgorithmRSA, PublicKey: &rsaKey.PublicKey},
+			expected: 2048,
 		},
 		{
-			name: "error - RSA device",
-			device: &Device{
-				Algorithm: AlgorithmRSA,
-				PublicKey: &rsaKey.PublicKey,
-			},
-			wantError: true,
+			name:     "success - ECDSA curve size",
+			device:   &Device{Algorithm: AlgorithmECDSA, PublicKey: &ecdsaKey.PublicKey},
+			expected: 384,
+		},
+		{
+			name:     "success - missing key",
+			device:   &Device{Algorithm: AlgorithmRSA},
+			expected: 0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			key, err := tt.device.GetECDSAPublicKey()
+			if size := tt.device.KeySize(); size != tt.expected {
+				t.Errorf("expected key size %d, got %d", tt.expected, size)
+			}
+		})
+	}
+}
 
-			if tt.wantError {
-				if err == nil {
-					t.Error("expected error, got nil")
-				}
-			} else {
-				if err != nil {
-					t.Errorf("expected no error, got %v", err)
-				}
-				if key == nil {
-					t.Error("expected key, got nil")
-				}
+func TestNewDevice_Curve(t *testing.T) {
+	tests := []struct {
+		name     string
+		curve    elliptic.Curve
+		expected string
+	}{
+		{name: "success - P-256", curve: elliptic.P256(), expected: "P-256"},
+		{name: "success - P-384", curve: elliptic.P384(), expected: "P-384"},
+		{name: "success - P-521", curve: elliptic.P521(), expected: "P-521"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+			if err != nil {
+				t.Fatalf("failed to generate key: %v", err)
+			}
+
+			device := NewDevice("curve-device", AlgorithmECDSA, "", &key.PublicKey, key)
+
+			if device.Curve != tt.expected {
+				t.Errorf("expected curve %q, got %q", tt.expected, device.Curve)
+			}
+			if snapshot := device.Snapshot(); snapshot.Curve != tt.expected {
+				t.Errorf("expected snapshot curve %q, got %q", tt.expected, snapshot.Curve)
 			}
 		})
 	}
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// DeviceStatus is the lifecycle state of a device.
+type DeviceStatus string
+
+const (
+	// StatusActive devices sign. Devices stored before lifecycle states were
+	// introduced have an empty status, which counts as active.
+	StatusActive DeviceStatus = "ACTIVE"
+	// StatusSuspended devices are temporarily taken out of service.
+	StatusSuspended DeviceStatus = "SUSPENDED"
+	// StatusRetired devices are permanently out of service; their chain is
+	// sealed by a decommission record.
+	StatusRetired DeviceStatus = "RETIRED"
+	// StatusDeleted devices are soft deleted: their private key is erased, but
+	// public keys and chain state are kept for audits.
+	StatusDeleted DeviceStatus = "DELETED"
+)
+
+// DecommissionData is the data of the record a device signs when it is
+// retired. No record may follow it in the chain.
+const DecommissionData = "decommission"
+
+var (
+	ErrDeviceSuspended   = errors.New("device is suspended")
+	ErrDeviceRetired     = errors.New("device is retired")
+	ErrDeviceDeleted     = errors.New("device is deleted")
+	ErrInvalidTransition = errors.New("invalid device status transition")
+	ErrReservedData      = errors.New("data is reserved for records signed by the service")
+)
+
+// transitions lists the states each state may move to.
+var transitions = map[DeviceStatus][]DeviceStatus{
+	StatusActive:    {StatusSuspended, StatusRetired, StatusDeleted},
+	StatusSuspended: {StatusActive, StatusRetired, StatusDeleted},
+	StatusRetired:   {StatusDeleted},
+}
+
+// ValidDeviceStatus reports whether s is a known lifecycle state.
+func ValidDeviceStatus(s DeviceStatus) bool {
+	switch s {
+	case StatusActive, StatusSuspended, StatusRetired, StatusDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsReservedData reports whether data is reserved for the rotation and
+// decommission records the service signs itself. Clients may not sign it, so
+// that such records in a chain are always genuine.
+func IsReservedData(data string) bool {
+	return data == DecommissionData || strings.HasPrefix(data, KeyRotationPrefix)
+}
+
+// statusLocked returns the lifecycle state, treating an empty status as
+// active. The caller must hold d.mu.
+func (d *Device) statusLocked() DeviceStatus {
+	if d.Status == "" {
+		return StatusActive
+	}
+	return d.Status
+}
+
+// checkActiveLocked returns ErrDeviceSuspended, ErrDeviceRetired or
+// ErrDeviceDeleted unless the device is active. The caller must hold d.mu.
+func (d *Device) checkActiveLocked() error {
+	if d.statusLocked() == StatusSuspended {
+		return ErrDeviceSuspended
+	}
+	return d.checkInServiceLocked()
+}
+
+// checkInServiceLocked returns ErrDeviceRetired or ErrDeviceDeleted if the
+// device has been taken out of service for good. The caller must hold d.mu.
+func (d *Device) checkInServiceLocked() error {
+	switch d.statusLocked() {
+	case StatusRetired:
+		return ErrDeviceRetired
+	case StatusDeleted:
+		return ErrDeviceDeleted
+	default:
+		return nil
+	}
+}
+
+// Suspend takes an active device out of service until it is reactivated.
+func (d *Device) Suspend() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.transitionLocked(StatusSuspended)
+}
+
+// Reactivate puts a suspended device back into service.
+func (d *Device) Reactivate() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.transitionLocked(StatusActive)
+}
+
+// Retire permanently takes an active or suspended device out of service. The
+// current key signs a decommission record as the last link of the chain,
+// which is journaled and returned. If signing or journaling fails the device
+// is left untouched.
+func (d *Device) Retire(newSigner SignerFactory, journal JournalFunc) (*SignatureRecord, error) {
+	d.lockForSigning()
+	defer d.mu.Unlock()
+
+	if err := d.checkTransitionLocked(StatusRetired); err != nil {
+		return nil, err
+	}
+
+	signer, err := newSigner(d.PrivateKey, d.KeyOptions())
+	if err != nil {
+		return nil, err
+	}
+	record, err := d.signLocked(signer, DecommissionData, journal)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Status = StatusRetired
+	d.touchLocked()
+	return record, nil
+}
+
+// Delete soft deletes the device. A device that has not been retired is
+// retired first, so that its chain is sealed by a decommission record, which
+// is journaled and returned; for retired devices the record is nil. The
+// private key is then zeroized and dropped, while the public keys and the
+// chain state are kept for audits. If signing or journaling fails the device
+// is left untouched.
+func (d *Device) Delete(newSigner SignerFactory, journal JournalFunc) (*SignatureRecord, error) {
+	d.lockForSigning()
+	defer d.mu.Unlock()
+
+	if err := d.checkTransitionLocked(StatusDeleted); err != nil {
+		return nil, err
+	}
+
+	var record *SignatureRecord
+	if d.statusLocked() != StatusRetired {
+		signer, err := newSigner(d.PrivateKey, d.KeyOptions())
+		if err != nil {
+			return nil, err
+		}
+		if record, err = d.signLocked(signer, DecommissionData, journal); err != nil {
+			return nil, err
+		}
+	}
+
+	crypto.ZeroizePrivateKey(d.PrivateKey)
+	d.PrivateKey = nil
+	d.Status = StatusDeleted
+	d.touchLocked()
+	return record, nil
+}
+
+// transitionLocked moves the device to status. The caller must hold d.mu.
+func (d *Device) transitionLocked(status DeviceStatus) error {
+	if err := d.checkTransitionLocked(status); err != nil {
+		return err
+	}
+	d.Status = status
+	d.touchLocked()
+	return nil
+}
+
+// checkTransitionLocked returns ErrInvalidTransition unless the device may
+// move to status. The caller must hold d.mu.
+func (d *Device) checkTransitionLocked(status DeviceStatus) error {
+	current := d.statusLocked()
+	for _, allowed := range transitions[current] {
+		if allowed == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current, status)
+}
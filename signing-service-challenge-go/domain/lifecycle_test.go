@@ -0,0 +1,297 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+func TestDeviceTransitions(t *testing.T) {
+	suspend := func(d *Device) error { return d.Suspend() }
+	reactivate := func(d *Device) error { return d.Reactivate() }
+	retire := func(d *Device) error {
+		_, err := d.Retire(stubSignerFactory(stubSigner{}), nil)
+		return err
+	}
+	remove := func(d *Device) error {
+		_, err := d.Delete(stubSignerFactory(stubSigner{}), nil)
+		return err
+	}
+
+	tests := []struct {
+		name           string
+		initial        DeviceStatus
+		transition     func(*Device) error
+		wantError      bool
+		expectedStatus DeviceStatus
+	}{
+		{name: "success - suspend active device", initial: StatusActive, transition: suspend, expectedStatus: StatusSuspended},
+		{name: "success - suspend legacy device without status", initial: "", transition: suspend, expectedStatus: StatusSuspended},
+		{name: "success - reactivate suspended device", initial: StatusSuspended, transition: reactivate, expectedStatus: StatusActive},
+		{name: "success - retire active device", initial: StatusActive, transition: retire, expectedStatus: StatusRetired},
+		{name: "success - retire suspended device", initial: StatusSuspended, transition: retire, expectedStatus: StatusRetired},
+		{name: "error - suspend suspended device", initial: StatusSuspended, transition: suspend, wantError: true, expectedStatus: StatusSuspended},
+		{name: "error - reactivate active device", initial: StatusActive, transition: reactivate, wantError: true, expectedStatus: StatusActive},
+		{name: "error - suspend retired device", initial: StatusRetired, transition: suspend, wantError: true, expectedStatus: StatusRetired},
+		{name: "error - reactivate retired device", initial: StatusRetired, transition: reactivate, wantError: true, expectedStatus: StatusRetired},
+		{name: "error - retire retired device", initial: StatusRetired, transition: retire, wantError: true, expectedStatus: StatusRetired},
+		{name: "success - delete active device", initial: StatusActive, transition: remove, expectedStatus: StatusDeleted},
+		{name: "success - delete suspended device", initial: StatusSuspended, transition: remove, expectedStatus: StatusDeleted},
+		{name: "success - delete retired device", initial: StatusRetired, transition: remove, expectedStatus: StatusDeleted},
+		{name: "error - delete deleted device", initial: StatusDeleted, transition: remove, wantError: true, expectedStatus: StatusDeleted},
+		{name: "error - reactivate deleted device", initial: StatusDeleted, transition: reactivate, wantError: true, expectedStatus: StatusDeleted},
+		{name: "error - retire deleted device", initial: StatusDeleted, transition: retire, wantError: true, expectedStatus: StatusDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := &Device{ID: "device-id", Status: tt.initial}
+
+			err := tt.transition(device)
+
+			if tt.wantError {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Errorf("expected ErrInvalidTransition, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if device.statusLocked() != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, device.statusLocked())
+			}
+		})
+	}
+}
+
+func TestRetire(t *testing.T) {
+	t.Run("success - decommission record is the last link", func(t *testing.T) {
+		device := &Device{ID: "device-id", SignatureCounter: 3, LastSignature: "previousSignature"}
+
+		record, err := device.Retire(stubSignerFactory(stubSigner{}), nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if record.Counter != 3 || record.Data != DecommissionData {
+			t.Errorf("expected decommission record for counter 3, got %d with data %q", record.Counter, record.Data)
+		}
+		if record.SignedData != "3_decommission_previousSignature" {
+			t.Errorf("unexpected signed data %q", record.SignedData)
+		}
+		if device.SignatureCounter != 4 || device.LastSignature != record.Signature {
+			t.Errorf("expected chain to advance to counter 4, got %d", device.SignatureCounter)
+		}
+		if _, err := device.Sign(stubSigner{}, "data", nil); !errors.Is(err, ErrDeviceRetired) {
+			t.Errorf("expected ErrDeviceRetired after retirement, got %v", err)
+		}
+	})
+
+	t.Run("error - signer failure leaves device untouched", func(t *testing.T) {
+		device := &Device{ID: "device-id", SignatureCounter: 3}
+
+		if _, err := device.Retire(stubSignerFactory(stubSigner{err: errors.New("boom")}), nil); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if device.statusLocked() != StatusActive || device.SignatureCounter != 3 {
+			t.Errorf("expected active device at counter 3, got %q at %d", device.statusLocked(), device.SignatureCounter)
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          DeviceStatus
+		wantRecord      bool
+		expectedCounter int
+	}{
+		{name: "success - active device is sealed first", status: StatusActive, wantRecord: true, expectedCounter: 4},
+		{name: "success - suspended device is sealed first", status: StatusSuspended, wantRecord: true, expectedCounter: 4},
+		{name: "success - retired device is already sealed", status: StatusRetired, expectedCounter: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newTestECDSADevice(t, "device-id")
+			device.SignatureCounter = 3
+			device.Status = tt.status
+			privateKey := device.PrivateKey.(*ecdsa.PrivateKey)
+			publicKey := device.PublicKey
+
+			record, err := device.Delete(stubSignerFactory(stubSigner{}), nil)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if tt.wantRecord && (record == nil || record.Data != DecommissionData || record.Counter != 3) {
+				t.Errorf("expected decommission record for counter 3, got %+v", record)
+			}
+			if !tt.wantRecord && record != nil {
+				t.Errorf("expected no record, got %+v", record)
+			}
+			if device.SignatureCounter != tt.expectedCounter {
+				t.Errorf("expected counter %d, got %d", tt.expectedCounter, device.SignatureCounter)
+			}
+			if device.PrivateKey != nil || privateKey.D.Sign() != 0 {
+				t.Error("expected private key to be zeroized and dropped")
+			}
+			if device.PublicKey != publicKey {
+				t.Error("expected public key to be kept")
+			}
+			if _, err := device.SignWithCurrentKey(stubSignerFactory(stubSigner{}), "data", nil); !errors.Is(err, ErrDeviceDeleted) {
+				t.Errorf("expected ErrDeviceDeleted, got %v", err)
+			}
+		})
+	}
+
+	t.Run("success - clone keeps its copy of the private key", func(t *testing.T) {
+		device := newTestECDSADevice(t, "device-id")
+		clone := device.Clone()
+
+		if _, err := device.Delete(stubSignerFactory(stubSigner{}), nil); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		privateKey := clone.PrivateKey.(*ecdsa.PrivateKey)
+		if privateKey.D.Sign() == 0 || !privateKey.PublicKey.Equal(device.PublicKey) {
+			t.Error("expected the clone's private key to survive the deletion")
+		}
+	})
+
+	t.Run("success - snapshot holds no private key", func(t *testing.T) {
+		device := newTestECDSADevice(t, "device-id")
+
+		if snapshot := device.Snapshot(); snapshot.PrivateKey != nil || snapshot.PublicKey != device.PublicKey {
+			t.Error("expected the snapshot to carry the public key only")
+		}
+	})
+
+	t.Run("error - signer failure leaves device untouched", func(t *testing.T) {
+		device := newTestECDSADevice(t, "device-id")
+
+		if _, err := device.Delete(stubSignerFactory(stubSigner{err: errors.New("boom")}), nil); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if device.statusLocked() != StatusActive || device.PrivateKey == nil {
+			t.Errorf("expected active device with its private key, got %q", device.statusLocked())
+		}
+	})
+}
+
+func TestSign_Lifecycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        DeviceStatus
+		data          string
+		expectedError error
+	}{
+		{name: "success - active device", status: StatusActive, data: "data"},
+		{name: "success - data merely containing a reserved word", status: StatusActive, data: "no decommission"},
+		{name: "error - suspended device", status: StatusSuspended, data: "data", expectedError: ErrDeviceSuspended},
+		{name: "error - retired device", status: StatusRetired, data: "data", expectedError: ErrDeviceRetired},
+		{name: "error - deleted device", status: StatusDeleted, data: "data", expectedError: ErrDeviceDeleted},
+		{name: "error - decommission data is reserved", status: StatusActive, data: DecommissionData, expectedError: ErrReservedData},
+		{name: "error - key rotation data is reserved", status: StatusActive, data: KeyRotationPrefix + "kid", expectedError: ErrReservedData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, sign := range map[string]func(*Device) error{
+				"Sign": func(d *Device) error {
+					_, err := d.Sign(stubSigner{}, tt.data, nil)
+					return err
+				},
+				"SignWithCurrentKey": func(d *Device) error {
+					_, err := d.SignWithCurrentKey(stubSignerFactory(stubSigner{}), tt.data, nil)
+					return err
+				},
+			} {
+				device := &Device{ID: "device-id", Status: tt.status}
+
+				err := sign(device)
+
+				if !errors.Is(err, tt.expectedError) {
+					t.Errorf("expected error %v, got %v", tt.expectedError, err)
+				}
+				if tt.expectedError != nil && device.SignatureCounter != 0 {
+					t.Errorf("expected counter 0 after rejection, got %d", device.SignatureCounter)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateKey_Lifecycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        DeviceStatus
+		expectedError error
+	}{
+		{name: "success - suspended device may rotate", status: StatusSuspended},
+		{name: "error - retired device", status: StatusRetired, expectedError: ErrDeviceRetired},
+		{name: "error - deleted device", status: StatusDeleted, expectedError: ErrDeviceDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newTestECDSADevice(t, "device-id")
+			device.Status = tt.status
+			keyPair, err := (&crypto.ECCGenerator{}).Generate()
+			if err != nil {
+				t.Fatalf("failed to generate key pair: %v", err)
+			}
+
+			_, err = device.RotateKey(stubSignerFactory(stubSigner{}), keyPair.Public, keyPair.Private, nil, nil)
+
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestVerifyChain_Decommission(t *testing.T) {
+	keyPair, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	device := NewDevice("retired-device", AlgorithmECDSA, "", keyPair.Public, keyPair.Private)
+	newSigner := func(interface{}, crypto.KeyOptions) (crypto.Signer, error) {
+		return crypto.NewECDSASigner(keyPair.Private), nil
+	}
+	verifier := crypto.NewECDSAVerifier(keyPair.Public)
+
+	record, err := device.SignWithCurrentKey(newSigner, "data", nil)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	decommission, err := device.Retire(newSigner, nil)
+	if err != nil {
+		t.Fatalf("failed to retire: %v", err)
+	}
+	chain := []ChainLink{
+		{SignedData: record.SignedData, Signature: record.Signature},
+		{SignedData: decommission.SignedData, Signature: decommission.Signature},
+	}
+
+	if err := VerifyChain(device.ID, verifier, chain); err != nil {
+		t.Fatalf("expected sealed chain to verify, got %v", err)
+	}
+
+	// A link signed with the device key after the seal, as only a leaked key
+	// could produce.
+	securedData := "2_data_" + decommission.Signature
+	signature, err := crypto.NewECDSASigner(keyPair.Private).Sign([]byte(securedData))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	chain = append(chain, ChainLink{SignedData: securedData, Signature: base64.StdEncoding.EncodeToString(signature)})
+
+	var chainErr *ChainError
+	if err := VerifyChain(device.ID, verifier, chain); !errors.As(err, &chainErr) || chainErr.Index != 2 {
+		t.Errorf("expected chain broken at link 2, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxMetadataEntries is the largest number of metadata entries a device
+	// may carry.
+	MaxMetadataEntries = 32
+	// MaxMetadataKeyLength is the longest metadata key in bytes.
+	MaxMetadataKeyLength = 64
+	// MaxMetadataValueLength is the longest metadata value in bytes.
+	MaxMetadataValueLength = 256
+)
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// ValidateMetadata checks the size of metadata and its keys and values. Keys
+// consist of ASCII letters, digits, '_', '-' and '.', so that they can be used
+// in filters.
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("%w: at most %d entries are allowed", ErrInvalidMetadata, MaxMetadataEntries)
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("%w: key %q must have 1 to %d characters", ErrInvalidMetadata, key, MaxMetadataKeyLength)
+		}
+		for _, r := range key {
+			if !isMetadataKeyRune(r) {
+				return fmt.Errorf("%w: key %q may only contain letters, digits, '_', '-' and '.'", ErrInvalidMetadata, key)
+			}
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: value of %q is longer than %d characters", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+func isMetadataKeyRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'
+}
+
+// UpdateDetails changes the label and metadata of the device; algorithm and
+// keys can never change this way. A nil label is left unchanged. The metadata
+// is merged as in a JSON merge patch (RFC 7396): entries with a nil value are
+// removed, all others are set. If the resulting metadata is invalid the error
+// wraps ErrInvalidMetadata and the device is left untouched. Deleted devices
+// fail with ErrDeviceDeleted. UpdatedAt is set on success.
+func (d *Device) UpdateDetails(label *string, metadata map[string]*string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.statusLocked() == StatusDeleted {
+		return ErrDeviceDeleted
+	}
+
+	merged := make(map[string]string, len(d.Metadata)+len(metadata))
+	for key, value := range d.Metadata {
+		merged[key] = value
+	}
+	for key, value := range metadata {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *value
+	}
+	if err := ValidateMetadata(merged); err != nil {
+		return err
+	}
+
+	if label != nil {
+		d.Label = *label
+	}
+	if len(merged) == 0 {
+		merged = nil
+	}
+	d.Metadata = merged
+	d.touchLocked()
+	return nil
+}
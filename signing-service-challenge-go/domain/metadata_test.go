@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := make(map[string]string, MaxMetadataEntries+1)
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooMany["key"+strings.Repeat("x", i)] = "value"
+	}
+
+	tests := []struct {
+		name      string
+		metadata  map[string]string
+		wantError bool
+	}{
+		{name: "success - nil", metadata: nil},
+		{name: "success - typical tags", metadata: map[string]string{"store_id": "42", "register.number": "7", "tenant-id": "acme"}},
+		{name: "success - empty value", metadata: map[string]string{"note": ""}},
+		{name: "error - empty key", metadata: map[string]string{"": "value"}, wantError: true},
+		{name: "error - key with colon", metadata: map[string]string{"store:id": "42"}, wantError: true},
+		{name: "error - key with space", metadata: map[string]string{"store id": "42"}, wantError: true},
+		{name: "error - key too long", metadata: map[string]string{strings.Repeat("k", MaxMetadataKeyLength+1): "v"}, wantError: true},
+		{name: "error - value too long", metadata: map[string]string{"key": strings.Repeat("v", MaxMetadataValueLength+1)}, wantError: true},
+		{name: "error - too many entries", metadata: tooMany, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMetadata(tt.metadata)
+
+			if tt.wantError && !errors.Is(err, ErrInvalidMetadata) {
+				t.Errorf("expected ErrInvalidMetadata, got %v", err)
+			}
+			if !tt.wantError && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateDetails(t *testing.T) {
+	stringPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name             string
+		status           DeviceStatus
+		label            *string
+		metadata         map[string]*string
+		expectedError    error
+		expectedLabel    string
+		expectedMetadata map[string]string
+	}{
+		{
+			name:             "success - nothing changes",
+			expectedLabel:    "label",
+			expectedMetadata: map[string]string{"store": "1", "tenant": "acme"},
+		},
+		{
+			name:             "success - label only",
+			label:            stringPtr("new label"),
+			expectedLabel:    "new label",
+			expectedMetadata: map[string]string{"store": "1", "tenant": "acme"},
+		},
+		{
+			name:             "success - merge sets and removes entries",
+			metadata:         map[string]*string{"store": stringPtr("2"), "tenant": nil, "register": stringPtr("7")},
+			expectedLabel:    "label",
+			expectedMetadata: map[string]string{"store": "2", "register": "7"},
+		},
+		{
+			name:          "success - removing every entry",
+			metadata:      map[string]*string{"store": nil, "tenant": nil},
+			expectedLabel: "label",
+		},
+		{
+			name:             "success - retired device",
+			status:           StatusRetired,
+			label:            stringPtr("archived"),
+			expectedLabel:    "archived",
+			expectedMetadata: map[string]string{"store": "1", "tenant": "acme"},
+		},
+		{
+			name:             "error - invalid metadata leaves device untouched",
+			label:            stringPtr("new label"),
+			metadata:         map[string]*string{"bad key": stringPtr("x")},
+			expectedError:    ErrInvalidMetadata,
+			expectedLabel:    "label",
+			expectedMetadata: map[string]string{"store": "1", "tenant": "acme"},
+		},
+		{
+			name:             "error - deleted device",
+			status:           StatusDeleted,
+			label:            stringPtr("new label"),
+			expectedError:    ErrDeviceDeleted,
+			expectedLabel:    "label",
+			expectedMetadata: map[string]string{"store": "1", "tenant": "acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := &Device{
+				ID:       "device-id",
+				Label:    "label",
+				Metadata: map[string]string{"store": "1", "tenant": "acme"},
+				Status:   tt.status,
+			}
+
+			err := device.UpdateDetails(tt.label, tt.metadata)
+
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if device.Label != tt.expectedLabel {
+				t.Errorf("expected label %q, got %q", tt.expectedLabel, device.Label)
+			}
+			if !reflect.DeepEqual(device.Metadata, tt.expectedMetadata) {
+				t.Errorf("expected metadata %v, got %v", tt.expectedMetadata, device.Metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// KeyRotationPrefix starts the data of the record a device signs with its old
+// key when it rotates to a new one. It is followed by the RFC 7638 thumbprint
+// of the new public key.
+const KeyRotationPrefix = "key-rotation:"
+
+// SignerFactory creates a signer for a private key, like
+// crypto.Algorithm.NewSigner.
+type SignerFactory func(privateKey interface{}, opts crypto.KeyOptions) (crypto.Signer, error)
+
+// KeyRotationData returns the data of the rotation record that commits to
+// publicKey.
+func KeyRotationData(publicKey interface{}) (string, error) {
+	jwk, err := crypto.NewJWK(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return KeyRotationPrefix + jwk.Kid, nil
+}
+
+// PersistFunc durably stores the device, like
+// persistence.DeviceRepository.Update. It is called without the device lock.
+type PersistFunc func() error
+
+// RotateKey replaces the device's key pair. The old key signs a rotation
+// record committing to the new public key as the next link of the chain, so
+// the counter and chain simply continue; the new key signs every following
+// counter. The old public key is retired together with the range of counters
+// it signed, so that historical signatures can still be verified. If signing
+// or journaling the rotation record fails the device is left untouched.
+// Suspended devices may rotate, so that a compromised key can be replaced
+// before the device is reactivated; retired and deleted devices fail with
+// ErrDeviceRetired or ErrDeviceDeleted.
+//
+// The device then persists the new key with persist, if given, and nothing
+// signs with either key until it is done. Once the new key is stored, or if
+// persist is nil, the old private key is zeroized. If persist fails the new
+// key is zeroized and the device keeps the old one instead: the rotation
+// record stays in the chain, as after Reconcile, and the rotation has to be
+// repeated.
+func (d *Device) RotateKey(newSigner SignerFactory, publicKey, privateKey interface{}, journal JournalFunc, persist PersistFunc) (*SignatureRecord, error) {
+	data, err := KeyRotationData(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	d.lockForSigning()
+	defer d.mu.Unlock()
+
+	if err := d.checkInServiceLocked(); err != nil {
+		return nil, err
+	}
+	signer, err := newSigner(d.PrivateKey, d.KeyOptions())
+	if err != nil {
+		return nil, err
+	}
+	record, err := d.signLocked(signer, data, journal)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPublicKey, oldPrivateKey := d.PublicKey, d.PrivateKey
+	d.RetiredKeys = append(d.RetiredKeys, RetiredKey{
+		PublicKey:    d.PublicKey,
+		FirstCounter: d.keyValidFromLocked(),
+		LastCounter:  record.Counter,
+		RetiredAt:    record.CreatedAt,
+	})
+	d.PublicKey = publicKey
+	d.PrivateKey = privateKey
+	d.touchLocked()
+
+	if persist != nil {
+		d.rotating = true
+		d.mu.Unlock()
+		err = persist()
+		d.mu.Lock()
+		d.rotating = false
+		d.rotatedLocked().Broadcast()
+	}
+	if err != nil {
+		crypto.ZeroizePrivateKey(privateKey)
+		d.PublicKey, d.PrivateKey = oldPublicKey, oldPrivateKey
+		d.RetiredKeys = d.RetiredKeys[:len(d.RetiredKeys)-1]
+		return nil, fmt.Errorf("persist key rotation: %w", err)
+	}
+
+	crypto.ZeroizePrivateKey(oldPrivateKey)
+	return record, nil
+}
+
+// lockForSigning locks d.mu once no key rotation is being persisted, so that
+// nothing signs with a key that may still be rolled back.
+func (d *Device) lockForSigning() {
+	d.mu.Lock()
+	for d.rotating {
+		d.rotatedLocked().Wait()
+	}
+}
+
+// rotatedLocked returns the condition signalled when a key rotation has been
+// persisted. The caller must hold d.mu.
+func (d *Device) rotatedLocked() *sync.Cond {
+	if d.rotated == nil {
+		d.rotated = sync.NewCond(&d.mu)
+	}
+	return d.rotated
+}
+
+// KeyVersion returns the version of the device's current key. It starts at 1
+// and increases with every rotation.
+func (d *Device) KeyVersion() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.RetiredKeys) + 1
+}
+
+// KeyValidFrom returns the first signature counter of the current key.
+func (d *Device) KeyValidFrom() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.keyValidFromLocked()
+}
+
+// keyValidFromLocked returns the first counter of the current key. The caller
+// must hold d.mu.
+func (d *Device) keyValidFromLocked() int {
+	if len(d.RetiredKeys) == 0 {
+		return 0
+	}
+	return d.RetiredKeys[len(d.RetiredKeys)-1].LastCounter + 1
+}
+
+// PublicKeyFor returns the public key that signed the given counter: a
+// retired key for counters before the last rotation, the current key
+// otherwise.
+func (d *Device) PublicKeyFor(counter int) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, retired := range d.RetiredKeys {
+		if counter >= retired.FirstCounter && counter <= retired.LastCounter {
+			return retired.PublicKey
+		}
+	}
+	return d.PublicKey
+}
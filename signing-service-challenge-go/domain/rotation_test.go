@@ -0,0 +1,340 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// stubSignerFactory returns stubSigner for any key.
+func stubSignerFactory(signer stubSigner) SignerFactory {
+	return func(interface{}, crypto.KeyOptions) (crypto.Signer, error) {
+		return signer, nil
+	}
+}
+
+// newTestECDSADevice returns an ECDSA device with a real key pair.
+func newTestECDSADevice(t *testing.T, id string) *Device {
+	t.Helper()
+
+	keyPair, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	return NewDevice(id, AlgorithmECDSA, "", keyPair.Public, keyPair.Private)
+}
+
+func TestRotateKey(t *testing.T) {
+	tests := []struct {
+		name            string
+		newSigner       SignerFactory
+		initialCounter  int
+		rotations       int
+		wantError       bool
+		expectedCounter int
+		expectedRanges  [][2]int
+	}{
+		{
+			name:            "success - first rotation retires the key from counter 0",
+			newSigner:       stubSignerFactory(stubSigner{}),
+			initialCounter:  3,
+			rotations:       1,
+			expectedCounter: 4,
+			expectedRanges:  [][2]int{{0, 3}},
+		},
+		{
+			name:            "success - rotation of a new device",
+			newSigner:       stubSignerFactory(stubSigner{}),
+			rotations:       1,
+			expectedCounter: 1,
+			expectedRanges:  [][2]int{{0, 0}},
+		},
+		{
+			name:            "success - consecutive rotations have adjacent ranges",
+			newSigner:       stubSignerFactory(stubSigner{}),
+			initialCounter:  3,
+			rotations:       2,
+			expectedCounter: 5,
+			expectedRanges:  [][2]int{{0, 3}, {4, 4}},
+		},
+		{
+			name:            "error - signer failure leaves device untouched",
+			newSigner:       stubSignerFactory(stubSigner{err: errors.New("boom")}),
+			initialCounter:  3,
+			rotations:       1,
+			wantError:       true,
+			expectedCounter: 3,
+		},
+		{
+			name: "error - signer factory failure leaves device untouched",
+			newSigner: func(interface{}, crypto.KeyOptions) (crypto.Signer, error) {
+				return nil, errors.New("boom")
+			},
+			initialCounter:  3,
+			rotations:       1,
+			wantError:       true,
+			expectedCounter: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newTestECDSADevice(t, "device-id")
+			device.SignatureCounter = tt.initialCounter
+			originalKey := device.PublicKey
+
+			var newKey interface{}
+			for i := 0; i < tt.rotations; i++ {
+				keyPair, _ := (&crypto.ECCGenerator{}).Generate()
+				newKey = keyPair.Public
+
+				record, err := device.RotateKey(tt.newSigner, keyPair.Public, keyPair.Private, nil, nil)
+				if tt.wantError {
+					if err == nil {
+						t.Fatal("expected error, got nil")
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+
+				expectedData, _ := KeyRotationData(keyPair.Public)
+				if record.Data != expectedData || !strings.HasPrefix(record.Data, KeyRotationPrefix) {
+					t.Errorf("expected rotation data %q, got %q", expectedData, record.Data)
+				}
+			}
+
+			if device.SignatureCounter != tt.expectedCounter {
+				t.Errorf("expected counter %d, got %d", tt.expectedCounter, device.SignatureCounter)
+			}
+			if tt.wantError {
+				if device.PublicKey != originalKey || len(device.RetiredKeys) != 0 {
+					t.Error("expected the key to be kept")
+				}
+				if device.KeyVersion() != 1 {
+					t.Errorf("expected key version 1, got %d", device.KeyVersion())
+				}
+				return
+			}
+
+			if device.PublicKey != newKey {
+				t.Error("expected the new key to be current")
+			}
+			if device.KeyVersion() != tt.rotations+1 {
+				t.Errorf("expected key version %d, got %d", tt.rotations+1, device.KeyVersion())
+			}
+			if len(device.RetiredKeys) != len(tt.expectedRanges) {
+				t.Fatalf("expected %d retired keys, got %d", len(tt.expectedRanges), len(device.RetiredKeys))
+			}
+			for i, expected := range tt.expectedRanges {
+				retired := device.RetiredKeys[i]
+				if retired.FirstCounter != expected[0] || retired.LastCounter != expected[1] {
+					t.Errorf("expected retired key %d to cover %d-%d, got %d-%d",
+						i, expected[0], expected[1], retired.FirstCounter, retired.LastCounter)
+				}
+				if device.PublicKeyFor(expected[0]) != retired.PublicKey || device.PublicKeyFor(expected[1]) != retired.PublicKey {
+					t.Errorf("expected counters %d-%d to resolve to retired key %d", expected[0], expected[1], i)
+				}
+			}
+			if device.RetiredKeys[0].PublicKey != originalKey {
+				t.Error("expected the original key to be retired first")
+			}
+			if device.KeyValidFrom() != tt.expectedCounter {
+				t.Errorf("expected the current key to be valid from %d, got %d", tt.expectedCounter, device.KeyValidFrom())
+			}
+			if device.PublicKeyFor(tt.expectedCounter) != newKey {
+				t.Error("expected the next counter to resolve to the current key")
+			}
+		})
+	}
+}
+
+func TestRotateKey_Persist(t *testing.T) {
+	tests := []struct {
+		name       string
+		persist    func() error
+		wantError  bool
+		wantNewKey bool
+	}{
+		{
+			name:       "success - without persisting",
+			wantNewKey: true,
+		},
+		{
+			name:       "success - new key persisted",
+			persist:    func() error { return nil },
+			wantNewKey: true,
+		},
+		{
+			name:      "error - persist failure keeps the old key",
+			persist:   func() error { return errors.New("disk full") },
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newTestECDSADevice(t, "device-id")
+			algorithm, _ := crypto.Lookup(crypto.AlgorithmNameECDSA)
+			oldPublicKey, oldPrivateKey := device.PublicKey, device.PrivateKey.(*ecdsa.PrivateKey)
+			keyPair, _ := (&crypto.ECCGenerator{}).Generate()
+			newPrivateKey := keyPair.Private
+
+			// A signature requested while the rotation is persisted waits for
+			// it, and is made with whichever key the device ends up with.
+			signed := make(chan *SignatureRecord, 1)
+			var persist PersistFunc
+			if tt.persist != nil {
+				persist = func() error {
+					go func() {
+						record, err := device.SignWithCurrentKey(algorithm.NewSigner, "data", nil)
+						if err != nil {
+							t.Errorf("failed to sign: %v", err)
+						}
+						signed <- record
+					}()
+					return tt.persist()
+				}
+			}
+
+			_, err := device.RotateKey(algorithm.NewSigner, keyPair.Public, keyPair.Private, nil, persist)
+
+			if tt.wantError != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.wantError, err)
+			}
+			if tt.wantNewKey {
+				if device.PublicKey != keyPair.Public || len(device.RetiredKeys) != 1 {
+					t.Error("expected the new key to be current")
+				}
+				if oldPrivateKey.D.Sign() != 0 {
+					t.Error("expected the old private key to be zeroized")
+				}
+			} else {
+				if device.PublicKey != oldPublicKey || device.PrivateKey != oldPrivateKey || len(device.RetiredKeys) != 0 {
+					t.Error("expected the old key to be kept")
+				}
+				if newPrivateKey.D.Sign() != 0 {
+					t.Error("expected the discarded new private key to be zeroized")
+				}
+			}
+
+			if persist == nil {
+				return
+			}
+			record := <-signed
+			if record == nil {
+				return
+			}
+			// The rotation record stays in the chain even if the rotation is
+			// rolled back, so the waiting signature follows it.
+			if record.Counter != 1 {
+				t.Errorf("expected the waiting signature at counter 1, got %d", record.Counter)
+			}
+			verifier := crypto.NewECDSAVerifier(device.PublicKey.(*ecdsa.PublicKey))
+			if _, err := VerifySignature(verifier, ChainLink{SignedData: record.SignedData, Signature: record.Signature}); err != nil {
+				t.Errorf("expected the waiting signature to verify with the current key, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRotateKey_ConcurrentSigning(t *testing.T) {
+	device := newTestECDSADevice(t, "device-id")
+	algorithm, err := crypto.Lookup(crypto.AlgorithmNameECDSA)
+	if err != nil {
+		t.Fatalf("failed to look up algorithm: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var links []ChainLink
+	record := func(r *SignatureRecord, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		mu.Lock()
+		links = append(links, ChainLink{SignedData: r.SignedData, Signature: r.Signature})
+		mu.Unlock()
+	}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record(device.SignWithCurrentKey(algorithm.NewSigner, "data", nil))
+		}()
+		if i%10 == 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keyPair, _ := (&crypto.ECCGenerator{}).Generate()
+				record(device.RotateKey(algorithm.NewSigner, keyPair.Public, keyPair.Private, nil, nil))
+			}()
+		}
+	}
+	wg.Wait()
+
+	// Order the links by counter and verify each against the key for its counter.
+	ordered := make([]ChainLink, len(links))
+	for _, link := range links {
+		securedData, err := ParseSecuredData(link.SignedData)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", link.SignedData, err)
+		}
+		ordered[securedData.Counter] = link
+	}
+	err = VerifyChainFunc(device.ID, func(counter int) (crypto.Verifier, error) {
+		return crypto.NewECDSAVerifier(device.PublicKeyFor(counter).(*ecdsa.PublicKey)), nil
+	}, ordered)
+	if err != nil {
+		t.Errorf("expected a valid chain across rotations, got %v", err)
+	}
+}
+
+func TestVerifyChainFunc_KeyRotation(t *testing.T) {
+	device := newTestECDSADevice(t, "device-id")
+	algorithm, _ := crypto.Lookup(crypto.AlgorithmNameECDSA)
+
+	var links []ChainLink
+	sign := func(r *SignatureRecord, err error) {
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		links = append(links, ChainLink{SignedData: r.SignedData, Signature: r.Signature})
+	}
+	sign(device.SignWithCurrentKey(algorithm.NewSigner, "before", nil))
+	keyPair, _ := (&crypto.ECCGenerator{}).Generate()
+	sign(device.RotateKey(algorithm.NewSigner, keyPair.Public, keyPair.Private, nil, nil))
+	sign(device.SignWithCurrentKey(algorithm.NewSigner, "after", nil))
+
+	byCounter := func(counter int) (crypto.Verifier, error) {
+		return crypto.NewECDSAVerifier(device.PublicKeyFor(counter).(*ecdsa.PublicKey)), nil
+	}
+	if err := VerifyChainFunc(device.ID, byCounter, links); err != nil {
+		t.Errorf("expected valid chain, got %v", err)
+	}
+
+	// A single key cannot verify the whole chain
+	var chainErr *ChainError
+	err := VerifyChain(device.ID, crypto.NewECDSAVerifier(keyPair.Public), links)
+	if !errors.As(err, &chainErr) || chainErr.Index != 0 {
+		t.Errorf("expected the new key to fail at index 0, got %v", err)
+	}
+	err = VerifyChain(device.ID, crypto.NewECDSAVerifier(device.RetiredKeys[0].PublicKey.(*ecdsa.PublicKey)), links)
+	if !errors.As(err, &chainErr) || chainErr.Index != 2 {
+		t.Errorf("expected the retired key to fail at index 2, got %v", err)
+	}
+
+	// Errors resolving a key are returned as they are
+	boom := errors.New("boom")
+	err = VerifyChainFunc(device.ID, func(int) (crypto.Verifier, error) { return nil, boom }, links)
+	if !errors.Is(err, boom) {
+		t.Errorf("expected resolver error, got %v", err)
+	}
+}
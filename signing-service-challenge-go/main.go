@@ -1,20 +1,121 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence/sqlite"
 )
 
 const (
 	ListenAddress = ":8080"
+	// DataDirEnv names the environment variable holding the directory of the
+	// file-backed device store. When unset, devices are kept in memory only.
+	DataDirEnv = "SIGNING_SERVICE_DATA_DIR"
+	// SQLitePathEnv names the environment variable holding the path of the
+	// SQLite database. It takes precedence over DataDirEnv.
+	SQLitePathEnv = "SIGNING_SERVICE_SQLITE_PATH"
+	// MasterKeyEnv and MasterKeyFileEnv provide the base64 encoded master key
+	// that encrypts private keys at rest, directly or via a file.
+	MasterKeyEnv     = "SIGNING_SERVICE_MASTER_KEY"
+	MasterKeyFileEnv = "SIGNING_SERVICE_MASTER_KEY_FILE"
+	// PreviousMasterKeyEnv and PreviousMasterKeyFileEnv provide the master key
+	// being rotated out. When set, all stored keys are re-wrapped under the
+	// current master key on startup.
+	PreviousMasterKeyEnv     = "SIGNING_SERVICE_PREVIOUS_MASTER_KEY"
+	PreviousMasterKeyFileEnv = "SIGNING_SERVICE_PREVIOUS_MASTER_KEY_FILE"
+	// AdminTokenEnv names the environment variable holding the bearer token
+	// for the admin endpoints. When unset, the admin endpoints are disabled.
+	AdminTokenEnv = "SIGNING_SERVICE_ADMIN_TOKEN"
 	// TODO: add further configuration parameters here ...
 )
 
 func main() {
-	server := api.NewServer(ListenAddress)
+	var opts []api.Option
+
+	masterKey := loadMasterKey(MasterKeyEnv, MasterKeyFileEnv)
+	previousMasterKey := loadMasterKey(PreviousMasterKeyEnv, PreviousMasterKeyFileEnv)
+	if previousMasterKey != nil && masterKey == nil {
+		log.Fatal("A previous master key is configured without a current master key")
+	}
+
+	if sqlitePath := os.Getenv(SQLitePathEnv); sqlitePath != "" {
+		repository, err := sqlite.Open(sqlitePath, sqlite.WithMasterKey(masterKey, previousMasterKey))
+		if err != nil {
+			log.Fatal("Could not open SQLite database ", sqlitePath, ": ", err)
+		}
+		defer repository.Close()
+
+		if previousMasterKey != nil {
+			if err := repository.RotateMasterKey(context.Background(), masterKey); err != nil {
+				log.Fatal("Could not rotate master key: ", err)
+			}
+		}
+
+		if err := persistence.ReconcileJournal(context.Background(), repository, repository.SignatureJournal()); err != nil {
+			log.Fatal("Could not reconcile devices with the signature journal: ", err)
+		}
+
+		opts = append(opts, api.WithRepository(repository), api.WithSignatureJournal(repository.SignatureJournal()))
+	} else if dataDir := os.Getenv(DataDirEnv); dataDir != "" {
+		repository, err := persistence.NewFileRepository(dataDir, persistence.WithMasterKey(masterKey, previousMasterKey))
+		if err != nil {
+			log.Fatal("Could not open device store in ", dataDir, ": ", err)
+		}
+		defer repository.Close()
+
+		if previousMasterKey != nil {
+			if err := repository.RotateMasterKey(masterKey); err != nil {
+				log.Fatal("Could not rotate master key: ", err)
+			}
+		}
+
+		journal, err := persistence.NewFileSignatureJournal(dataDir)
+		if err != nil {
+			log.Fatal("Could not open signature journal in ", dataDir, ": ", err)
+		}
+		defer journal.Close()
+
+		if err := persistence.ReconcileJournal(context.Background(), repository, journal); err != nil {
+			log.Fatal("Could not reconcile devices with the signature journal: ", err)
+		}
+
+		opts = append(opts, api.WithRepository(repository), api.WithSignatureJournal(journal))
+	}
+
+	if adminToken := os.Getenv(AdminTokenEnv); adminToken != "" {
+		opts = append(opts, api.WithAdminToken(adminToken))
+	}
+
+	server := api.NewServer(ListenAddress, opts...)
 
 	if err := server.Run(); err != nil {
 		log.Fatal("Could not start server on ", ListenAddress)
 	}
 }
+
+// loadMasterKey reads a master key from the environment variable keyEnv or
+// from the file named by fileEnv. It returns nil if neither is set.
+func loadMasterKey(keyEnv, fileEnv string) *crypto.MasterKey {
+	if path := os.Getenv(fileEnv); path != "" {
+		masterKey, err := crypto.LoadMasterKeyFile(path)
+		if err != nil {
+			log.Fatal("Could not load master key from ", fileEnv, ": ", err)
+		}
+		return masterKey
+	}
+
+	if _, ok := os.LookupEnv(keyEnv); ok {
+		masterKey, err := crypto.LoadMasterKeyEnv(keyEnv)
+		if err != nil {
+			log.Fatal("Could not load master key from ", keyEnv, ": ", err)
+		}
+		return masterKey
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package persistence_test
+
+import (
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence/persistencetest"
+)
+
+func TestInMemoryRepository_Conformance(t *testing.T) {
+	persistencetest.RunDeviceRepositorySuite(t, func(t *testing.T) persistence.DeviceRepository {
+		return persistence.NewInMemoryRepository()
+	})
+}
+
+func TestFileRepository_Conformance(t *testing.T) {
+	persistencetest.RunDeviceRepositorySuite(t, func(t *testing.T) persistence.DeviceRepository {
+		repo, err := persistence.NewFileRepository(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to open file repository: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestInMemorySignatureJournal_Conformance(t *testing.T) {
+	persistencetest.RunSignatureJournalSuite(t, func(t *testing.T, deviceIDs ...string) persistence.SignatureJournal {
+		return persistence.NewInMemorySignatureJournal()
+	})
+}
+
+func TestFileSignatureJournal_Conformance(t *testing.T) {
+	persistencetest.RunSignatureJournalSuite(t, func(t *testing.T, deviceIDs ...string) persistence.SignatureJournal {
+		journal, err := persistence.NewFileSignatureJournal(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to open file signature journal: %v", err)
+		}
+		t.Cleanup(func() { journal.Close() })
+		return journal
+	})
+}
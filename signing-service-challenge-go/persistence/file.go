@@ -0,0 +1,497 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+const (
+	walFileName      = "devices.wal"
+	snapshotFileName = "devices.snapshot"
+
+	// DefaultCompactionThreshold is the number of log entries after which the
+	// write-ahead log is folded into a new snapshot.
+	DefaultCompactionThreshold = 1000
+)
+
+const (
+	walOpCreate = "create"
+	walOpUpdate = "update"
+	walOpDelete = "delete"
+)
+
+// walEntry is a single write-ahead log record. Every entry carries the full
+// device state, so replaying an entry twice is harmless.
+type walEntry struct {
+	Op     string       `json:"op"`
+	Device DeviceRecord `json:"device"`
+}
+
+// snapshotFile is the on-disk layout of a compacted snapshot.
+type snapshotFile struct {
+	Devices []DeviceRecord `json:"devices"`
+}
+
+// FileOption configures a FileRepository.
+type FileOption func(*FileRepository)
+
+// WithCompactionThreshold sets how many log entries may accumulate before the
+// log is compacted into a snapshot. A threshold of zero disables automatic
+// compaction.
+func WithCompactionThreshold(entries int) FileOption {
+	return func(r *FileRepository) {
+		r.compactionThreshold = entries
+	}
+}
+
+// WithMasterKey enables envelope encryption of private keys at rest. Records
+// are sealed under current; previous master keys are only used to open
+// records written before a rotation.
+func WithMasterKey(current *crypto.MasterKey, previous ...*crypto.MasterKey) FileOption {
+	return func(r *FileRepository) {
+		r.masterKey = current
+		r.previousMasterKeys = previous
+	}
+}
+
+// FileRepository is a durable DeviceRepository. Every Create and Update is
+// appended to an fsync'd write-ahead log before it is acknowledged; the log is
+// periodically compacted into a snapshot. On startup the snapshot is loaded
+// and the log replayed, restoring exactly the last committed state of every
+// device. A record cut short at the end of the log (e.g. from a crash
+// mid-write) is discarded and truncated away; any other corruption fails
+// startup with ErrCorruptLog.
+type FileRepository struct {
+	dir                 string
+	devices             map[string]*domain.Device
+	counters            map[string]int
+	wal                 *os.File
+	walEntries          int
+	compactionThreshold int
+	masterKey           *crypto.MasterKey
+	previousMasterKeys  []*crypto.MasterKey
+	mu                  sync.Mutex
+}
+
+var _ DeviceRepository = (*FileRepository)(nil)
+
+// NewFileRepository opens (or initializes) the device store in dir.
+func NewFileRepository(dir string, opts ...FileOption) (*FileRepository, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	r := &FileRepository{
+		dir:                 dir,
+		devices:             make(map[string]*domain.Device),
+		counters:            make(map[string]int),
+		compactionThreshold: DefaultCompactionThreshold,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := r.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(r.walPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	r.wal = wal
+
+	return r, nil
+}
+
+// Close releases the write-ahead log file handle.
+func (r *FileRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.wal == nil {
+		return nil
+	}
+	err := r.wal.Close()
+	r.wal = nil
+	return err
+}
+
+// Create stores a new device
+func (r *FileRepository) Create(ctx context.Context, device *domain.Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.devices[device.ID]; exists {
+		return ErrDeviceAlreadyExists
+	}
+
+	record, err := r.newRecordLocked(device)
+	if err != nil {
+		return err
+	}
+	if err := r.appendLocked(walEntry{Op: walOpCreate, Device: record}); err != nil {
+		return err
+	}
+
+	r.devices[device.ID] = device
+	r.counters[device.ID] = record.SignatureCounter
+	r.maybeCompactLocked()
+	return nil
+}
+
+// Get retrieves a device by ID
+func (r *FileRepository) Get(ctx context.Context, id string) (*domain.Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	device, exists := r.devices[id]
+	if !exists {
+		return nil, ErrDeviceNotFound
+	}
+
+	return device, nil
+}
+
+// List returns a page of the devices matching opts
+func (r *FileRepository) List(ctx context.Context, opts ListOptions) (DevicePage, error) {
+	if err := ctx.Err(); err != nil {
+		return DevicePage{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return pageDevices(r.devices, opts)
+}
+
+// Update appends the current device state to the log. The state is captured
+// under the device lock, so concurrent updates can only ever persist the same
+// or a newer counter.
+func (r *FileRepository) Update(ctx context.Context, device *domain.Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.devices[device.ID]; !exists {
+		return ErrDeviceNotFound
+	}
+
+	record, err := r.newRecordLocked(device)
+	if err != nil {
+		return err
+	}
+	if record.SignatureCounter < r.counters[device.ID] {
+		return ErrCounterRegression
+	}
+	if err := r.appendLocked(walEntry{Op: walOpUpdate, Device: record}); err != nil {
+		return err
+	}
+
+	r.devices[device.ID] = device
+	r.counters[device.ID] = record.SignatureCounter
+	r.maybeCompactLocked()
+	return nil
+}
+
+// Delete appends the soft deleted device to the log and compacts it right
+// away, so that no earlier entry holding the private key remains on disk. If
+// the compaction fails the deletion itself is committed, but the error is
+// returned because the private key may still be on disk.
+func (r *FileRepository) Delete(ctx context.Context, device *domain.Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.devices[device.ID]; !exists {
+		return ErrDeviceNotFound
+	}
+
+	record, err := r.newRecordLocked(device)
+	if err != nil {
+		return err
+	}
+	if record.Status != domain.StatusDeleted {
+		return ErrDeviceNotDeleted
+	}
+	if record.SignatureCounter < r.counters[device.ID] {
+		return ErrCounterRegression
+	}
+	if err := r.appendLocked(walEntry{Op: walOpDelete, Device: record}); err != nil {
+		return err
+	}
+
+	r.devices[device.ID] = device
+	r.counters[device.ID] = record.SignatureCounter
+	if err := r.compactLocked(); err != nil {
+		return fmt.Errorf("erase private key of device %s: %w", device.ID, err)
+	}
+	return nil
+}
+
+// Compact writes all devices into a new snapshot and truncates the log.
+func (r *FileRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.compactLocked()
+}
+
+// RotateMasterKey makes next the master key for all private keys at rest.
+// Since every snapshot is rebuilt from the live devices, the compaction that
+// follows re-seals each private key under next and drops the log entries
+// sealed under the old key; the private keys themselves never change. The old
+// key is retained in memory only to open entries until then.
+func (r *FileRepository) RotateMasterKey(next *crypto.MasterKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.masterKey
+	r.masterKey = next
+	if previous != nil {
+		r.previousMasterKeys = append(r.previousMasterKeys, previous)
+	}
+
+	if err := r.compactLocked(); err != nil {
+		r.masterKey = previous
+		return fmt.Errorf("rotate master key: %w", err)
+	}
+	return nil
+}
+
+// newRecordLocked snapshots the device and seals its private key under the
+// current master key, if any. The caller must hold r.mu.
+func (r *FileRepository) newRecordLocked(device *domain.Device) (DeviceRecord, error) {
+	record, err := NewDeviceRecord(device)
+	if err != nil {
+		return DeviceRecord{}, err
+	}
+	if err := record.SealPrivateKey(r.masterKey); err != nil {
+		return DeviceRecord{}, err
+	}
+	return record, nil
+}
+
+// appendLocked writes one framed entry to the log and fsyncs it. The caller
+// must hold r.mu.
+func (r *FileRepository) appendLocked(entry walEntry) error {
+	if r.wal == nil {
+		return errors.New("file repository is closed")
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := appendFrame(r.wal, payload); err != nil {
+		return fmt.Errorf("append to write-ahead log: %w", err)
+	}
+
+	r.walEntries++
+	return nil
+}
+
+// maybeCompactLocked compacts the log once it reaches the configured
+// threshold. It is called after an entry has been committed, so a failed
+// compaction is not reported to the caller: the log still holds every entry
+// and compaction is retried on the next append. The caller must hold r.mu.
+func (r *FileRepository) maybeCompactLocked() {
+	if r.compactionThreshold > 0 && r.walEntries >= r.compactionThreshold {
+		_ = r.compactLocked()
+	}
+}
+
+// compactLocked atomically replaces the snapshot with the current state and
+// then empties the log. A crash in between is safe: replaying the old log on
+// top of the new snapshot yields the same state. The caller must hold r.mu.
+func (r *FileRepository) compactLocked() error {
+	snapshot := snapshotFile{Devices: make([]DeviceRecord, 0, len(r.devices))}
+	for _, device := range r.devices {
+		record, err := r.newRecordLocked(device)
+		if err != nil {
+			return err
+		}
+		// Never let the snapshot fall behind what the log has committed.
+		if record.SignatureCounter < r.counters[device.ID] {
+			return ErrCounterRegression
+		}
+		snapshot.Devices = append(snapshot.Devices, record)
+	}
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := r.snapshotPath() + ".tmp"
+	if err := writeFileSync(tmpPath, payload); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.snapshotPath()); err != nil {
+		return fmt.Errorf("install snapshot: %w", err)
+	}
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	if r.wal != nil {
+		if err := r.wal.Truncate(0); err != nil {
+			return fmt.Errorf("truncate write-ahead log: %w", err)
+		}
+		if err := r.wal.Sync(); err != nil {
+			return fmt.Errorf("sync write-ahead log: %w", err)
+		}
+	}
+
+	for _, record := range snapshot.Devices {
+		r.counters[record.ID] = record.SignatureCounter
+	}
+	r.walEntries = 0
+	return nil
+}
+
+func (r *FileRepository) loadSnapshot() error {
+	payload, err := os.ReadFile(r.snapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snapshot snapshotFile
+	if err := json.Unmarshal(payload, &snapshot); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	for _, record := range snapshot.Devices {
+		if err := r.apply(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayWAL applies every intact log entry on top of the snapshot. An entry
+// torn by a crash at the end of the log is truncated, so that new appends
+// start from a clean tail; any other corrupted entry fails with ErrCorruptLog.
+func (r *FileRepository) replayWAL() error {
+	file, err := os.OpenFile(r.walPath(), os.O_RDWR, 0o600)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	err = readFrames(file, func(payload []byte) error {
+		entry, err := decodeWALEntry(payload)
+		if err != nil {
+			return err
+		}
+		if err := r.apply(entry.Device); err != nil {
+			return err
+		}
+		r.walEntries++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("replay write-ahead log: %w", err)
+	}
+
+	return nil
+}
+
+// apply installs a persisted record, keeping the highest counter seen.
+func (r *FileRepository) apply(record DeviceRecord) error {
+	if counter, exists := r.counters[record.ID]; exists && record.SignatureCounter < counter {
+		return nil
+	}
+
+	masterKeys := append([]*crypto.MasterKey{r.masterKey}, r.previousMasterKeys...)
+	if err := record.OpenPrivateKey(masterKeys...); err != nil {
+		return err
+	}
+
+	device, err := record.Device()
+	if err != nil {
+		return err
+	}
+
+	r.devices[record.ID] = device
+	r.counters[record.ID] = record.SignatureCounter
+	return nil
+}
+
+func (r *FileRepository) walPath() string {
+	return filepath.Join(r.dir, walFileName)
+}
+
+func (r *FileRepository) snapshotPath() string {
+	return filepath.Join(r.dir, snapshotFileName)
+}
+
+// decodeWALEntry decodes the payload of a write-ahead log frame.
+func decodeWALEntry(payload []byte) (walEntry, error) {
+	var entry walEntry
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return walEntry{}, fmt.Errorf("decode write-ahead log entry: %w", err)
+	}
+	return entry, nil
+}
+
+func writeFileSync(path string, payload []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(payload); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	handle, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open data directory: %w", err)
+	}
+	defer handle.Close()
+
+	if err := handle.Sync(); err != nil {
+		return fmt.Errorf("sync data directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+const journalFileName = "signatures.log"
+
+// FileSignatureJournal is a durable SignatureJournal. Each record is appended
+// as a checksummed frame to an fsync'd log that is never rewritten; the log is
+// indexed in memory on startup. A record torn by a crash at the end of the log
+// is discarded and any other corrupted record fails startup, exactly like in
+// the FileRepository write-ahead log.
+type FileSignatureJournal struct {
+	file    *os.File
+	records map[string][]domain.SignatureRecord
+	mu      sync.RWMutex
+}
+
+var _ SignatureJournal = (*FileSignatureJournal)(nil)
+
+// NewFileSignatureJournal opens (or initializes) the signature journal in dir.
+func NewFileSignatureJournal(dir string) (*FileSignatureJournal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open signature journal: %w", err)
+	}
+
+	j := &FileSignatureJournal{
+		file:    file,
+		records: make(map[string][]domain.SignatureRecord),
+	}
+	if err := j.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// Close releases the journal file handle.
+func (j *FileSignatureJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
+
+// Append durably records a signing event
+func (j *FileSignatureJournal) Append(ctx context.Context, record domain.SignatureRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if hasRecord(j.records[record.DeviceID], record.Counter) {
+		return ErrSignatureAlreadyRecorded
+	}
+
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := appendFrame(j.file, payload); err != nil {
+		return fmt.Errorf("append to signature journal: %w", err)
+	}
+
+	return insertRecord(j.records, record)
+}
+
+// List returns a page of a device's signing events
+func (j *FileSignatureJournal) List(ctx context.Context, deviceID string, cursor string, limit int) (SignaturePage, error) {
+	if err := ctx.Err(); err != nil {
+		return SignaturePage{}, err
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return pageRecords(j.records[deviceID], cursor, limit)
+}
+
+// load indexes every intact record. A torn record at the end of the log is
+// truncated; any other corrupted record fails with ErrCorruptLog.
+func (j *FileSignatureJournal) load() error {
+	err := readFrames(j.file, func(payload []byte) error {
+		var record domain.SignatureRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return fmt.Errorf("decode signature record: %w", err)
+		}
+		if err := insertRecord(j.records, record); err != nil && !errors.Is(err, ErrSignatureAlreadyRecorded) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("load signature journal: %w", err)
+	}
+
+	if _, err := j.file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seek signature journal: %w", err)
+	}
+	return nil
+}
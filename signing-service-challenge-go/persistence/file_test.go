@@ -0,0 +1,603 @@
+package persistence
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// newTestDevice returns an ECDSA device with a real key pair.
+func newTestDevice(t *testing.T, id string) *domain.Device {
+	t.Helper()
+
+	generator := &crypto.ECCGenerator{}
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+
+	return domain.NewDevice(id, domain.AlgorithmECDSA, "Test "+id, keyPair.Public, keyPair.Private)
+}
+
+// signAndUpdate signs n transactions with the device and persists each step.
+func signAndUpdate(t *testing.T, repo DeviceRepository, device *domain.Device, n int) {
+	t.Helper()
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		t.Fatalf("failed to look up algorithm: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err := device.SignWithCurrentKey(algorithm.NewSigner, "data", nil); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		if err := repo.Update(context.Background(), device); err != nil {
+			t.Fatalf("failed to update device: %v", err)
+		}
+	}
+}
+
+func openFileRepository(t *testing.T, dir string, opts ...FileOption) *FileRepository {
+	t.Helper()
+
+	repo, err := NewFileRepository(dir, opts...)
+	if err != nil {
+		t.Fatalf("failed to open file repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestFileRepository_Restart(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  int
+		signatures int
+	}{
+		{name: "success - replay log only", threshold: 0, signatures: 5},
+		{name: "success - replay snapshot and log", threshold: 4, signatures: 10},
+		{name: "success - snapshot only", threshold: 3, signatures: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			repo := openFileRepository(t, dir, WithCompactionThreshold(tt.threshold))
+
+			device := newTestDevice(t, "device-1")
+			if err := repo.Create(context.Background(), device); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+			signAndUpdate(t, repo, device, tt.signatures)
+			repo.Close()
+
+			reopened := openFileRepository(t, dir, WithCompactionThreshold(tt.threshold))
+			got, err := reopened.Get(context.Background(), "device-1")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if got.SignatureCounter != tt.signatures {
+				t.Errorf("expected counter %d, got %d", tt.signatures, got.SignatureCounter)
+			}
+			if got.LastSignature != device.LastSignature {
+				t.Errorf("expected last signature %q, got %q", device.LastSignature, got.LastSignature)
+			}
+			if got.Label != device.Label {
+				t.Errorf("expected label %q, got %q", device.Label, got.Label)
+			}
+
+			// The restored key must keep extending the same chain.
+			signAndUpdate(t, reopened, got, 1)
+			if got.SignatureCounter != tt.signatures+1 {
+				t.Errorf("expected counter %d, got %d", tt.signatures+1, got.SignatureCounter)
+			}
+		})
+	}
+}
+
+func TestFileRepository_KeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	repo := openFileRepository(t, dir)
+
+	device := newTestDevice(t, "device-1")
+	if err := repo.Create(context.Background(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	signAndUpdate(t, repo, device, 2)
+	keyPair, err := (&crypto.ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	algorithm, _ := crypto.Lookup(crypto.AlgorithmNameECDSA)
+	if _, err := device.RotateKey(algorithm.NewSigner, keyPair.Public, keyPair.Private, nil, nil); err != nil {
+		t.Fatalf("failed to rotate key: %v", err)
+	}
+	if err := repo.Update(context.Background(), device); err != nil {
+		t.Fatalf("failed to update device: %v", err)
+	}
+	signAndUpdate(t, repo, device, 1)
+	repo.Close()
+
+	reopened := openFileRepository(t, dir)
+	got, err := reopened.Get(context.Background(), "device-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.SignatureCounter != 4 {
+		t.Errorf("expected counter 4, got %d", got.SignatureCounter)
+	}
+	if !got.PublicKey.(*ecdsa.PublicKey).Equal(keyPair.Public) {
+		t.Error("expected the rotated key to be restored")
+	}
+	if len(got.RetiredKeys) != 1 || got.RetiredKeys[0].LastCounter != 2 {
+		t.Fatalf("expected one retired key up to counter 2, got %+v", got.RetiredKeys)
+	}
+	if !got.PublicKeyFor(1).(*ecdsa.PublicKey).Equal(device.RetiredKeys[0].PublicKey) {
+		t.Error("expected counter 1 to resolve to the retired key")
+	}
+}
+
+func TestFileRepository_Delete(t *testing.T) {
+	dir := t.TempDir()
+	repo := openFileRepository(t, dir)
+
+	device := newTestDevice(t, "device-1")
+	publicKey := device.PublicKey.(*ecdsa.PublicKey)
+	_, privateKeyPEM, err := crypto.NewPKCS8Marshaler().Marshal(device.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %v", err)
+	}
+	// The first line of the base64 body; JSON leaves it as it is
+	secret := strings.Split(string(privateKeyPEM), "\n")[1]
+
+	if err := repo.Create(context.Background(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	signAndUpdate(t, repo, device, 2)
+	algorithm, _ := crypto.Lookup(crypto.AlgorithmNameECDSA)
+	if _, err := device.Delete(algorithm.NewSigner, nil); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+	if err := repo.Delete(context.Background(), device); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	repo.Close()
+
+	for _, name := range []string{walFileName, snapshotFileName} {
+		contents, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if strings.Contains(string(contents), secret) {
+			t.Errorf("expected the private key to be erased from %s", name)
+		}
+	}
+
+	reopened := openFileRepository(t, dir)
+	got, err := reopened.Get(context.Background(), "device-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Status != domain.StatusDeleted || got.PrivateKey != nil {
+		t.Errorf("expected deleted device without private key, got status %q", got.Status)
+	}
+	if !got.PublicKey.(*ecdsa.PublicKey).Equal(publicKey) {
+		t.Error("expected the public key to be kept")
+	}
+	if got.SignatureCounter != 3 {
+		t.Errorf("expected counter 3, got %d", got.SignatureCounter)
+	}
+}
+
+func TestFileRepository_CrashRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, path string, lastEntrySize int64)
+	}{
+		{
+			name: "success - log truncated inside the payload",
+			corrupt: func(t *testing.T, path string, lastEntrySize int64) {
+				truncateBy(t, path, lastEntrySize/2)
+			},
+		},
+		{
+			name: "success - log truncated inside the header",
+			corrupt: func(t *testing.T, path string, lastEntrySize int64) {
+				truncateBy(t, path, lastEntrySize-3)
+			},
+		},
+		{
+			name: "success - log truncated with zeroed space after the last entry",
+			corrupt: func(t *testing.T, path string, lastEntrySize int64) {
+				truncateBy(t, path, lastEntrySize-frameHeaderSize-1)
+				file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o600)
+				if err != nil {
+					t.Fatalf("failed to open log: %v", err)
+				}
+				defer file.Close()
+				file.Write(make([]byte, frameHeaderSize))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			repo := openFileRepository(t, dir, WithCompactionThreshold(0))
+			walPath := filepath.Join(dir, walFileName)
+
+			device := newTestDevice(t, "device-1")
+			if err := repo.Create(context.Background(), device); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+			signAndUpdate(t, repo, device, 3)
+			committedSignature := device.LastSignature
+
+			sizeBefore := fileSize(t, walPath)
+			signAndUpdate(t, repo, device, 1)
+			lastEntrySize := fileSize(t, walPath) - sizeBefore
+			repo.Close()
+
+			tt.corrupt(t, walPath, lastEntrySize)
+
+			reopened := openFileRepository(t, dir, WithCompactionThreshold(0))
+			got, err := reopened.Get(context.Background(), "device-1")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got.SignatureCounter != 3 {
+				t.Errorf("expected counter 3, got %d", got.SignatureCounter)
+			}
+			if got.LastSignature != committedSignature {
+				t.Errorf("expected last signature %q, got %q", committedSignature, got.LastSignature)
+			}
+
+			// The torn tail must be gone so that new entries are readable again.
+			if size := fileSize(t, walPath); size != sizeBefore {
+				t.Errorf("expected log to be truncated to %d bytes, got %d", sizeBefore, size)
+			}
+			signAndUpdate(t, reopened, got, 1)
+			reopened.Close()
+
+			again := openFileRepository(t, dir, WithCompactionThreshold(0))
+			got, _ = again.Get(context.Background(), "device-1")
+			if got.SignatureCounter != 4 {
+				t.Errorf("expected counter 4 after recovery, got %d", got.SignatureCounter)
+			}
+		})
+	}
+}
+
+func TestFileRepository_CorruptedEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, path string)
+	}{
+		{
+			name: "error - payload byte of an entry followed by others",
+			corrupt: func(t *testing.T, path string) {
+				corruptAt(t, path, frameHeaderSize+1)
+			},
+		},
+		{
+			name: "error - length prefix running past the end of the log",
+			corrupt: func(t *testing.T, path string) {
+				length := make([]byte, 4)
+				binary.BigEndian.PutUint32(length, uint32(fileSize(t, path)))
+				writeAt(t, path, length, 0)
+			},
+		},
+		{
+			name: "error - last entry has a bad checksum",
+			corrupt: func(t *testing.T, path string) {
+				corruptAt(t, path, fileSize(t, path)-2)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			repo := openFileRepository(t, dir, WithCompactionThreshold(0))
+			walPath := filepath.Join(dir, walFileName)
+
+			device := newTestDevice(t, "device-1")
+			if err := repo.Create(context.Background(), device); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+			signAndUpdate(t, repo, device, 3)
+			repo.Close()
+
+			tt.corrupt(t, walPath)
+			sizeBefore := fileSize(t, walPath)
+
+			_, err := NewFileRepository(dir, WithCompactionThreshold(0))
+			if !errors.Is(err, ErrCorruptLog) {
+				t.Fatalf("expected error %v, got %v", ErrCorruptLog, err)
+			}
+			if size := fileSize(t, walPath); size != sizeBefore {
+				t.Errorf("expected log to be left at %d bytes, got %d", sizeBefore, size)
+			}
+		})
+	}
+}
+
+func TestFileRepository_Compact(t *testing.T) {
+	dir := t.TempDir()
+	repo := openFileRepository(t, dir, WithCompactionThreshold(0))
+
+	device := newTestDevice(t, "device-1")
+	repo.Create(context.Background(), device)
+	signAndUpdate(t, repo, device, 3)
+
+	if err := repo.Compact(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if size := fileSize(t, filepath.Join(dir, walFileName)); size != 0 {
+		t.Errorf("expected empty log after compaction, got %d bytes", size)
+	}
+
+	repo.Close()
+	reopened := openFileRepository(t, dir)
+	got, _ := reopened.Get(context.Background(), "device-1")
+	if got.SignatureCounter != 3 {
+		t.Errorf("expected counter 3, got %d", got.SignatureCounter)
+	}
+}
+
+func truncateBy(t *testing.T, path string, n int64) {
+	t.Helper()
+
+	if err := os.Truncate(path, fileSize(t, path)-n); err != nil {
+		t.Fatalf("failed to truncate log: %v", err)
+	}
+}
+
+// corruptAt flips the bits of the byte at offset in the file at path.
+func corruptAt(t *testing.T, path string, offset int64) {
+	t.Helper()
+
+	b := make([]byte, 1)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	if _, err := file.ReadAt(b, offset); err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	writeAt(t, path, []byte{b[0] ^ 0xff}, offset)
+}
+
+// writeAt overwrites the file at path with b at offset.
+func writeAt(t *testing.T, path string, b []byte, offset int64) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(b, offset); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+	return info.Size()
+}
+
+func TestFileRepository_MasterKey(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := newTestMasterKey(t)
+	newKey := newTestMasterKey(t)
+
+	repo := openFileRepository(t, dir, WithMasterKey(oldKey), WithCompactionThreshold(0))
+	device := newTestDevice(t, "device-1")
+	if err := repo.Create(context.Background(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	signAndUpdate(t, repo, device, 2)
+
+	assertNoClearPrivateKey(t, dir)
+
+	// Without the master key the store cannot be opened.
+	repo.Close()
+	if _, err := NewFileRepository(dir); !errors.Is(err, ErrMasterKeyRequired) {
+		t.Fatalf("expected error %v, got %v", ErrMasterKeyRequired, err)
+	}
+
+	repo = openFileRepository(t, dir, WithMasterKey(oldKey), WithCompactionThreshold(0))
+	if err := repo.RotateMasterKey(newKey); err != nil {
+		t.Fatalf("failed to rotate master key: %v", err)
+	}
+	repo.Close()
+
+	assertNoClearPrivateKey(t, dir)
+
+	reopened := openFileRepository(t, dir, WithMasterKey(newKey))
+	got, err := reopened.Get(context.Background(), "device-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.SignatureCounter != 2 {
+		t.Errorf("expected counter 2, got %d", got.SignatureCounter)
+	}
+	privateKey, _ := got.PrivateKey.(*ecdsa.PrivateKey)
+	if privateKey == nil || !privateKey.Equal(device.PrivateKey) {
+		t.Error("expected rotation to keep the signing key unchanged")
+	}
+}
+
+func newTestMasterKey(t *testing.T) *crypto.MasterKey {
+	t.Helper()
+
+	raw := make([]byte, crypto.MasterKeySize)
+	rand.Read(raw)
+	key, err := crypto.NewMasterKey(raw)
+	if err != nil {
+		t.Fatalf("failed to create master key: %v", err)
+	}
+	return key
+}
+
+// assertNoClearPrivateKey fails if any file in dir contains a PEM private key.
+func assertNoClearPrivateKey(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		content, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if strings.Contains(string(content), "PRIVATE") {
+			t.Errorf("expected %s not to contain a clear text private key", entry.Name())
+		}
+	}
+}
+
+func TestFileSignatureJournal_Restart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	journal, err := NewFileSignatureJournal(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	for counter := 0; counter < 3; counter++ {
+		journal.Append(ctx, domain.SignatureRecord{DeviceID: "device-1", Counter: counter, Signature: "sig"})
+	}
+	journal.Close()
+
+	// Simulate a crash in the middle of the last append.
+	truncateBy(t, filepath.Join(dir, journalFileName), 5)
+
+	reopened, err := NewFileSignatureJournal(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen journal: %v", err)
+	}
+	defer reopened.Close()
+
+	page, _ := reopened.List(ctx, "device-1", "", 0)
+	if len(page.Records) != 2 {
+		t.Fatalf("expected 2 intact records, got %d", len(page.Records))
+	}
+
+	if err := reopened.Append(ctx, domain.SignatureRecord{DeviceID: "device-1", Counter: 2, Signature: "sig"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	page, _ = reopened.List(ctx, "device-1", "", 0)
+	if len(page.Records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(page.Records))
+	}
+}
+
+func TestFileSignatureJournal_CorruptedRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	journalPath := filepath.Join(dir, journalFileName)
+
+	journal, err := NewFileSignatureJournal(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	for counter := 0; counter < 3; counter++ {
+		journal.Append(ctx, domain.SignatureRecord{DeviceID: "device-1", Counter: counter, Signature: "sig"})
+	}
+	journal.Close()
+
+	// Flip a payload byte of the first record, which later records follow.
+	corruptAt(t, journalPath, frameHeaderSize+1)
+	sizeBefore := fileSize(t, journalPath)
+
+	if _, err := NewFileSignatureJournal(dir); !errors.Is(err, ErrCorruptLog) {
+		t.Fatalf("expected error %v, got %v", ErrCorruptLog, err)
+	}
+	if size := fileSize(t, journalPath); size != sizeBefore {
+		t.Errorf("expected journal to be left at %d bytes, got %d", sizeBefore, size)
+	}
+}
+
+func TestReconcileJournal_CrashBeforeUpdate(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openFileRepository(t, dir)
+	journal, err := NewFileSignatureJournal(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	device := newTestDevice(t, "device-1")
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	algorithm, _ := crypto.Lookup(string(device.Algorithm))
+	appendRecord := func(record domain.SignatureRecord) error { return journal.Append(ctx, record) }
+
+	for i := 0; i < 3; i++ {
+		if _, err := device.SignWithCurrentKey(algorithm.NewSigner, "data", appendRecord); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		// Crash after journaling the last signature, before the device is
+		// updated.
+		if i < 2 {
+			if err := repo.Update(ctx, device); err != nil {
+				t.Fatalf("failed to update device: %v", err)
+			}
+		}
+	}
+	lastSignature := device.LastSignature
+	repo.Close()
+	journal.Close()
+
+	repo = openFileRepository(t, dir)
+	journal, err = NewFileSignatureJournal(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen journal: %v", err)
+	}
+	defer journal.Close()
+	appendRecord = func(record domain.SignatureRecord) error { return journal.Append(ctx, record) }
+
+	stored, _ := repo.Get(ctx, "device-1")
+	if _, err := stored.SignWithCurrentKey(algorithm.NewSigner, "data", appendRecord); !errors.Is(err, ErrSignatureAlreadyRecorded) {
+		t.Fatalf("expected the unreconciled device to fail with %v, got %v", ErrSignatureAlreadyRecorded, err)
+	}
+
+	if err := ReconcileJournal(ctx, repo, journal); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stored.SignatureCounter != 3 || stored.LastSignature != lastSignature {
+		t.Fatalf("expected device at counter 3 after the journal, got %d", stored.SignatureCounter)
+	}
+	record, err := stored.SignWithCurrentKey(algorithm.NewSigner, "data", appendRecord)
+	if err != nil {
+		t.Fatalf("expected the reconciled device to sign, got %v", err)
+	}
+	if record.Counter != 3 {
+		t.Errorf("expected counter 3, got %d", record.Counter)
+	}
+
+	// The reconciled state was persisted.
+	repo.Close()
+	reopened := openFileRepository(t, dir)
+	got, _ := reopened.Get(ctx, "device-1")
+	if got.SignatureCounter != 3 {
+		t.Errorf("expected stored counter 3, got %d", got.SignatureCounter)
+	}
+}
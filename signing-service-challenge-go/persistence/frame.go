@@ -0,0 +1,141 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+const (
+	// frameHeaderSize is the size of the length and checksum prefix of a frame.
+	frameHeaderSize = 8
+	// maxFrameSize bounds the payload length read from disk, so that a
+	// corrupted length prefix cannot trigger a huge allocation.
+	maxFrameSize = 16 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeFrame prefixes payload with its length and CRC-32C checksum.
+func encodeFrame(payload []byte) []byte {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+	return frame
+}
+
+// ErrCorruptLog is returned on startup for a log with a corrupted frame. Only
+// the last append can be torn by a crash, so anything else is left for an
+// operator to inspect.
+var ErrCorruptLog = errors.New("corrupted log frame")
+
+// readFrames reads every frame of file from the start and passes its payload
+// to apply. A frame cut short by the end of the file is the torn tail of an
+// interrupted append, unless an intact frame follows it: the file is then
+// truncated to the last intact frame, so that new appends start from a clean
+// tail. Every other bad frame fails with ErrCorruptLog.
+func readFrames(file *os.File, apply func(payload []byte) error) error {
+	reader := bufio.NewReader(file)
+	var offset int64
+
+	for {
+		payload, size, err := readFrame(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("%w: offset %d: %v", ErrCorruptLog, offset, err)
+			}
+			torn, err := tornTail(file, offset)
+			if err != nil {
+				return err
+			}
+			if !torn {
+				return fmt.Errorf("%w: offset %d: frame length runs past intact frames", ErrCorruptLog, offset)
+			}
+			if err := file.Truncate(offset); err != nil {
+				return fmt.Errorf("truncate torn tail: %w", err)
+			}
+			return file.Sync()
+		}
+
+		if err := apply(payload); err != nil {
+			return err
+		}
+		offset += size
+	}
+}
+
+// tornTail reports whether the rest of file from offset, which ends in the
+// middle of a frame, can be a single torn append. A corrupted length prefix
+// can make a frame in the middle of the log look cut short, but then intact
+// frames start within the rest. The rest is shorter than one frame, so reading
+// it is bounded by maxFrameSize.
+func tornTail(file *os.File, offset int64) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil {
+		return false, fmt.Errorf("read torn tail: %w", err)
+	}
+
+	for i := 1; i+frameHeaderSize <= len(tail); i++ {
+		// Frames are never empty, and zeroed space would otherwise pass as
+		// intact empty frames.
+		length := int(binary.BigEndian.Uint32(tail[i : i+4]))
+		if length == 0 || length > len(tail)-i-frameHeaderSize {
+			continue
+		}
+		payload := tail[i+frameHeaderSize : i+frameHeaderSize+length]
+		if crc32.Checksum(payload, crcTable) == binary.BigEndian.Uint32(tail[i+4:i+8]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// readFrame reads one frame and returns its payload and on-disk size. It
+// returns io.EOF only at a clean frame boundary and io.ErrUnexpectedEOF for a
+// frame cut short by the end of the input; a corrupted frame yields a
+// different error.
+func readFrame(reader io.Reader) ([]byte, int64, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length > maxFrameSize {
+		return nil, 0, fmt.Errorf("frame too large: %d bytes", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return nil, 0, errors.New("frame checksum mismatch")
+	}
+
+	return payload, int64(frameHeaderSize) + int64(length), nil
+}
+
+// appendFrame writes one frame to file and fsyncs it.
+func appendFrame(file *os.File, payload []byte) error {
+	if _, err := file.Write(encodeFrame(payload)); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -1,23 +1,20 @@
 package persistence
 
 import (
-	"errors"
+	"context"
 	"sync"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 )
 
-var (
-	ErrDeviceNotFound      = errors.New("device not found")
-	ErrDeviceAlreadyExists = errors.New("device already exists")
-)
-
 // InMemoryRepository implements an in-memory storage for signature devices
 type InMemoryRepository struct {
 	devices map[string]*domain.Device
 	mu      sync.RWMutex
 }
 
+var _ DeviceRepository = (*InMemoryRepository)(nil)
+
 // NewInMemoryRepository creates a new in-memory repository
 func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
@@ -26,7 +23,11 @@ func NewInMemoryRepository() *InMemoryRepository {
 }
 
 // Create stores a new device
-func (r *InMemoryRepository) Create(device *domain.Device) error {
+func (r *InMemoryRepository) Create(ctx context.Context, device *domain.Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -39,7 +40,11 @@ func (r *InMemoryRepository) Create(device *domain.Device) error {
 }
 
 // Get retrieves a device by ID
-func (r *InMemoryRepository) Get(id string) (*domain.Device, error) {
+func (r *InMemoryRepository) Get(ctx context.Context, id string) (*domain.Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -51,28 +56,45 @@ func (r *InMemoryRepository) Get(id string) (*domain.Device, error) {
 	return device, nil
 }
 
-// List returns all devices
-func (r *InMemoryRepository) List() ([]*domain.Device, error) {
+// List returns a page of the devices matching opts
+func (r *InMemoryRepository) List(ctx context.Context, opts ListOptions) (DevicePage, error) {
+	if err := ctx.Err(); err != nil {
+		return DevicePage{}, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	devices := make([]*domain.Device, 0, len(r.devices))
-	for _, device := range r.devices {
-		devices = append(devices, device)
-	}
-
-	return devices, nil
+	return pageDevices(r.devices, opts)
 }
 
 // Update updates an existing device
-func (r *InMemoryRepository) Update(device *domain.Device) error {
+func (r *InMemoryRepository) Update(ctx context.Context, device *domain.Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.devices[device.ID]; !exists {
+	stored, exists := r.devices[device.ID]
+	if !exists {
 		return ErrDeviceNotFound
 	}
 
+	if stored != device && stored.Counter() > device.Counter() {
+		return ErrCounterRegression
+	}
+
 	r.devices[device.ID] = device
 	return nil
 }
+
+// Delete stores a soft deleted device. The private key only ever lived in
+// memory, so there is nothing else to erase.
+func (r *InMemoryRepository) Delete(ctx context.Context, device *domain.Device) error {
+	if device.Snapshot().Status != domain.StatusDeleted {
+		return ErrDeviceNotDeleted
+	}
+	return r.Update(ctx, device)
+}
@@ -1,6 +1,7 @@
 package persistence
 
 import (
+	"context"
 	"testing"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
@@ -37,10 +38,10 @@ func TestInMemoryRepository_Create(t *testing.T) {
 
 			// For duplicate test, create first device
 			if tt.wantError == ErrDeviceAlreadyExists {
-				repo.Create(&domain.Device{ID: "duplicate-id"})
+				repo.Create(context.Background(), &domain.Device{ID: "duplicate-id"})
 			}
 
-			err := repo.Create(tt.device)
+			err := repo.Create(context.Background(), tt.device)
 
 			if tt.wantError != nil {
 				if err != tt.wantError {
@@ -66,7 +67,7 @@ func TestInMemoryRepository_Get(t *testing.T) {
 			name:     "success - get existing device",
 			deviceID: "existing-device",
 			setup: func(repo *InMemoryRepository) {
-				repo.Create(&domain.Device{
+				repo.Create(context.Background(), &domain.Device{
 					ID:        "existing-device",
 					Algorithm: domain.AlgorithmRSA,
 				})
@@ -86,7 +87,7 @@ func TestInMemoryRepository_Get(t *testing.T) {
 			repo := NewInMemoryRepository()
 			tt.setup(repo)
 
-			device, err := repo.Get(tt.deviceID)
+			device, err := repo.Get(context.Background(), tt.deviceID)
 
 			if tt.wantError != nil {
 				if err != tt.wantError {
@@ -121,9 +122,9 @@ func TestInMemoryRepository_List(t *testing.T) {
 		{
 			name: "success - list multiple devices",
 			setup: func(repo *InMemoryRepository) {
-				repo.Create(&domain.Device{ID: "device-1"})
-				repo.Create(&domain.Device{ID: "device-2"})
-				repo.Create(&domain.Device{ID: "device-3"})
+				repo.Create(context.Background(), &domain.Device{ID: "device-1"})
+				repo.Create(context.Background(), &domain.Device{ID: "device-2"})
+				repo.Create(context.Background(), &domain.Device{ID: "device-3"})
 			},
 			expectedCount: 3,
 		},
@@ -134,14 +135,14 @@ func TestInMemoryRepository_List(t *testing.T) {
 			repo := NewInMemoryRepository()
 			tt.setup(repo)
 
-			devices, err := repo.List()
+			page, err := repo.List(context.Background(), ListOptions{})
 
 			if err != nil {
 				t.Errorf("expected no error, got %v", err)
 			}
 
-			if len(devices) != tt.expectedCount {
-				t.Errorf("expected %d devices, got %d", tt.expectedCount, len(devices))
+			if len(page.Devices) != tt.expectedCount {
+				t.Errorf("expected %d devices, got %d", tt.expectedCount, len(page.Devices))
 			}
 		})
 	}
@@ -161,7 +162,7 @@ func TestInMemoryRepository_Update(t *testing.T) {
 				SignatureCounter: 10,
 			},
 			setup: func(repo *InMemoryRepository) {
-				repo.Create(&domain.Device{
+				repo.Create(context.Background(), &domain.Device{
 					ID:               "device-1",
 					SignatureCounter: 5,
 				})
@@ -183,7 +184,7 @@ func TestInMemoryRepository_Update(t *testing.T) {
 			repo := NewInMemoryRepository()
 			tt.setup(repo)
 
-			err := repo.Update(tt.device)
+			err := repo.Update(context.Background(), tt.device)
 
 			if tt.wantError != nil {
 				if err != tt.wantError {
@@ -195,7 +196,7 @@ func TestInMemoryRepository_Update(t *testing.T) {
 				}
 
 				// Verify update worked
-				device, _ := repo.Get(tt.device.ID)
+				device, _ := repo.Get(context.Background(), tt.device.ID)
 				if device.SignatureCounter != tt.device.SignatureCounter {
 					t.Errorf("expected counter %d, got %d", tt.device.SignatureCounter, device.SignatureCounter)
 				}
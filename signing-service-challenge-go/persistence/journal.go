@@ -0,0 +1,230 @@
+package persistence
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+var (
+	ErrSignatureAlreadyRecorded = errors.New("signature counter already recorded")
+	ErrInvalidCursor            = errors.New("invalid cursor")
+)
+
+// SignaturePage is one page of a device's signature journal.
+type SignaturePage struct {
+	Records []domain.SignatureRecord
+	// NextCursor continues the listing after the last record of this page. It
+	// is empty when there are no further records.
+	NextCursor string
+}
+
+// SignatureJournal is the append-only store of signing events.
+type SignatureJournal interface {
+	// Append records a signing event. It returns ErrSignatureAlreadyRecorded if
+	// the device already has a record for the same counter.
+	Append(ctx context.Context, record domain.SignatureRecord) error
+	// List returns up to limit records of a device in counter order, starting
+	// after cursor (or from the beginning if cursor is empty). A limited page
+	// ends at the first gap in the counters and only continues with a cursor
+	// while the counters are contiguous, so a client paging through the
+	// journal never skips a record.
+	List(ctx context.Context, deviceID string, cursor string, limit int) (SignaturePage, error)
+}
+
+// EncodeSignatureCursor returns the opaque cursor that continues a listing
+// after the given counter.
+func EncodeSignatureCursor(counter int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(counter)))
+}
+
+// DecodeSignatureCursor returns the counter encoded in cursor, or -1 for an
+// empty cursor.
+func DecodeSignatureCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return -1, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	counter, err := strconv.Atoi(string(raw))
+	if err != nil || counter < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return counter, nil
+}
+
+// InMemorySignatureJournal implements an in-memory signature journal
+type InMemorySignatureJournal struct {
+	records map[string][]domain.SignatureRecord
+	mu      sync.RWMutex
+}
+
+var _ SignatureJournal = (*InMemorySignatureJournal)(nil)
+
+// NewInMemorySignatureJournal creates a new in-memory signature journal
+func NewInMemorySignatureJournal() *InMemorySignatureJournal {
+	return &InMemorySignatureJournal{
+		records: make(map[string][]domain.SignatureRecord),
+	}
+}
+
+// Append records a signing event, keeping each device's records sorted by
+// counter even if concurrent requests append out of order.
+func (j *InMemorySignatureJournal) Append(ctx context.Context, record domain.SignatureRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return insertRecord(j.records, record)
+}
+
+// List returns a page of a device's signing events
+func (j *InMemorySignatureJournal) List(ctx context.Context, deviceID string, cursor string, limit int) (SignaturePage, error) {
+	if err := ctx.Err(); err != nil {
+		return SignaturePage{}, err
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return pageRecords(j.records[deviceID], cursor, limit)
+}
+
+// hasRecord reports whether the counter-sorted records contain counter.
+func hasRecord(records []domain.SignatureRecord, counter int) bool {
+	i := sort.Search(len(records), func(i int) bool {
+		return records[i].Counter >= counter
+	})
+	return i < len(records) && records[i].Counter == counter
+}
+
+// insertRecord adds record to its device's slice in counter order.
+func insertRecord(records map[string][]domain.SignatureRecord, record domain.SignatureRecord) error {
+	deviceRecords := records[record.DeviceID]
+
+	if hasRecord(deviceRecords, record.Counter) {
+		return ErrSignatureAlreadyRecorded
+	}
+	i := sort.Search(len(deviceRecords), func(i int) bool {
+		return deviceRecords[i].Counter > record.Counter
+	})
+
+	deviceRecords = append(deviceRecords, domain.SignatureRecord{})
+	copy(deviceRecords[i+1:], deviceRecords[i:])
+	deviceRecords[i] = record
+	records[record.DeviceID] = deviceRecords
+
+	return nil
+}
+
+// pageRecords returns the page of counter-sorted records following cursor.
+func pageRecords(records []domain.SignatureRecord, cursor string, limit int) (SignaturePage, error) {
+	after, err := DecodeSignatureCursor(cursor)
+	if err != nil {
+		return SignaturePage{}, err
+	}
+
+	start := sort.Search(len(records), func(i int) bool {
+		return records[i].Counter > after
+	})
+	records = records[start:]
+
+	var page SignaturePage
+	if limit > 0 {
+		records, page.NextCursor = LimitSignaturePage(records, after, limit)
+	}
+	page.Records = append(make([]domain.SignatureRecord, 0, len(records)), records...)
+
+	return page, nil
+}
+
+// LimitSignaturePage cuts the counter-sorted records following the counter
+// after (-1 for the start of the journal) down to a page of at most limit
+// records. The page ends at the first gap in the counters, and the returned
+// cursor is only set if the record after the page follows it contiguously;
+// counters are never handed out past a record that is missing, for instance
+// because it is still being written.
+func LimitSignaturePage(records []domain.SignatureRecord, after int, limit int) ([]domain.SignatureRecord, string) {
+	contiguous := 0
+	for contiguous < len(records) && contiguous <= limit {
+		previous := after
+		if contiguous > 0 {
+			previous = records[contiguous-1].Counter
+		}
+		if previous >= 0 && records[contiguous].Counter != previous+1 {
+			break
+		}
+		contiguous++
+	}
+
+	if contiguous <= limit {
+		return records[:contiguous], ""
+	}
+	return records[:limit], EncodeSignatureCursor(records[limit-1].Counter)
+}
+
+// reconcilePageSize is the number of journal records ReconcileJournal reads
+// at a time.
+const reconcilePageSize = 100
+
+// ReconcileJournal moves every stored device past the signing events that
+// reached the journal but not the device, as after a crash or a failed Update
+// between the two writes, and persists the devices it changed. Without it such
+// a device would fail every further signature with
+// ErrSignatureAlreadyRecorded. It is meant to run on startup, before the
+// devices are used.
+func ReconcileJournal(ctx context.Context, repository DeviceRepository, journal SignatureJournal) error {
+	page, err := repository.List(ctx, ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, device := range page.Devices {
+		if err := reconcileDevice(ctx, repository, journal, device); err != nil {
+			return fmt.Errorf("reconcile device %s: %w", device.ID, err)
+		}
+	}
+	return nil
+}
+
+// reconcileDevice applies the journal records from the device's counter on.
+func reconcileDevice(ctx context.Context, repository DeviceRepository, journal SignatureJournal, device *domain.Device) error {
+	changed := false
+	for {
+		cursor := ""
+		if counter := device.Counter(); counter > 0 {
+			cursor = EncodeSignatureCursor(counter - 1)
+		}
+		page, err := journal.List(ctx, device.ID, cursor, reconcilePageSize)
+		if err != nil {
+			return err
+		}
+
+		applied, err := device.Reconcile(page.Records)
+		if err != nil {
+			return err
+		}
+		changed = changed || applied
+		if page.NextCursor == "" {
+			break
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return repository.Update(ctx, device)
+}
@@ -0,0 +1,228 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// ErrInvalidSort is returned for a device listing sorted by an unknown key.
+var ErrInvalidSort = errors.New("invalid sort order")
+
+// DeviceSort is the order of a device listing.
+type DeviceSort string
+
+const (
+	// SortByID lists devices by ascending ID. It is the default.
+	SortByID DeviceSort = "id"
+	// SortByIDDesc lists devices by descending ID.
+	SortByIDDesc DeviceSort = "-id"
+	// SortByCreatedAt lists devices from the oldest to the newest, by ID
+	// within the same creation time.
+	SortByCreatedAt DeviceSort = "created_at"
+	// SortByCreatedAtDesc lists devices from the newest to the oldest.
+	SortByCreatedAtDesc DeviceSort = "-created_at"
+)
+
+// ValidDeviceSort reports whether sort is a supported device order. The empty
+// order stands for SortByID.
+func ValidDeviceSort(sort DeviceSort) bool {
+	switch sort {
+	case "", SortByID, SortByIDDesc, SortByCreatedAt, SortByCreatedAtDesc:
+		return true
+	default:
+		return false
+	}
+}
+
+// ListOptions selects, orders and pages a device listing. The zero value lists
+// all devices by ID.
+type ListOptions struct {
+	// Algorithm only lists devices of this algorithm.
+	Algorithm domain.SignatureAlgorithm
+	// Label only lists devices whose label contains this substring. The match
+	// is case sensitive.
+	Label string
+	// Status only lists devices in this lifecycle state.
+	Status domain.DeviceStatus
+	// Metadata only lists devices that carry every one of these entries.
+	Metadata map[string]string
+	// CreatedFrom only lists devices created at or after this time, if set.
+	CreatedFrom time.Time
+	// CreatedBefore only lists devices created before this time, if set.
+	CreatedBefore time.Time
+	// Sort orders the listing; empty means SortByID.
+	Sort DeviceSort
+	// Cursor continues a previous listing with the same sort order after its
+	// last device, or starts from the beginning if empty.
+	Cursor string
+	// Limit is the maximum number of devices returned; zero or less means no
+	// limit.
+	Limit int
+}
+
+// DevicePage is one page of a device listing.
+type DevicePage struct {
+	Devices []*domain.Device
+	// NextCursor continues the listing after the last device of this page. It
+	// is empty when there are no further devices.
+	NextCursor string
+}
+
+// DevicePosition is the place of a device in a listing: the sort keys of the
+// last device of a page.
+type DevicePosition struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PositionOf returns the position of device in a listing.
+func PositionOf(device *domain.Device) DevicePosition {
+	snapshot := device.Snapshot()
+	return DevicePosition{ID: snapshot.ID, CreatedAt: snapshot.CreatedAt}
+}
+
+// deviceCursor is the position of a listing encoded in its cursor.
+type deviceCursor struct {
+	Sort DeviceSort `json:"s"`
+	DevicePosition
+}
+
+// EncodeDeviceCursor returns the opaque cursor that continues a listing in the
+// given order after position.
+func EncodeDeviceCursor(sort DeviceSort, position DevicePosition) string {
+	raw, _ := json.Marshal(deviceCursor{Sort: normalizeSort(sort), DevicePosition: position})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeDeviceCursor returns the position of the last device before cursor,
+// or nil for an empty cursor. Cursors from a listing in another order fail
+// with ErrInvalidCursor.
+func DecodeDeviceCursor(sort DeviceSort, cursor string) (*DevicePosition, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var position deviceCursor
+	if err := json.Unmarshal(raw, &position); err != nil || position.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if position.Sort != normalizeSort(sort) {
+		return nil, ErrInvalidCursor
+	}
+
+	return &position.DevicePosition, nil
+}
+
+// before reports whether p comes before other in a listing in the given
+// order.
+func (p DevicePosition) before(sort DeviceSort, other DevicePosition) bool {
+	switch normalizeSort(sort) {
+	case SortByIDDesc:
+		return p.ID > other.ID
+	case SortByCreatedAt:
+		if !p.CreatedAt.Equal(other.CreatedAt) {
+			return p.CreatedAt.Before(other.CreatedAt)
+		}
+		return p.ID < other.ID
+	case SortByCreatedAtDesc:
+		if !p.CreatedAt.Equal(other.CreatedAt) {
+			return p.CreatedAt.After(other.CreatedAt)
+		}
+		return p.ID > other.ID
+	default:
+		return p.ID < other.ID
+	}
+}
+
+// normalizeSort maps the empty order to SortByID.
+func normalizeSort(sort DeviceSort) DeviceSort {
+	if sort == "" {
+		return SortByID
+	}
+	return sort
+}
+
+// Matches reports whether device passes the filters of the options. Devices
+// without a status count as active.
+func (o ListOptions) Matches(device *domain.Device) bool {
+	snapshot := device.Snapshot()
+
+	if o.Algorithm != "" && snapshot.Algorithm != o.Algorithm {
+		return false
+	}
+	if o.Label != "" && !strings.Contains(snapshot.Label, o.Label) {
+		return false
+	}
+	if o.Status != "" {
+		status := snapshot.Status
+		if status == "" {
+			status = domain.StatusActive
+		}
+		if status != o.Status {
+			return false
+		}
+	}
+	for key, value := range o.Metadata {
+		if actual, ok := snapshot.Metadata[key]; !ok || actual != value {
+			return false
+		}
+	}
+	if !o.CreatedFrom.IsZero() && snapshot.CreatedAt.Before(o.CreatedFrom) {
+		return false
+	}
+	if !o.CreatedBefore.IsZero() && !snapshot.CreatedAt.Before(o.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// pageDevices filters, sorts and pages devices held in memory.
+func pageDevices(devices map[string]*domain.Device, opts ListOptions) (DevicePage, error) {
+	if !ValidDeviceSort(opts.Sort) {
+		return DevicePage{}, ErrInvalidSort
+	}
+	after, err := DecodeDeviceCursor(opts.Sort, opts.Cursor)
+	if err != nil {
+		return DevicePage{}, err
+	}
+
+	type entry struct {
+		device   *domain.Device
+		position DevicePosition
+	}
+	matches := make([]entry, 0, len(devices))
+	for _, device := range devices {
+		position := PositionOf(device)
+		if after != nil && !after.before(opts.Sort, position) {
+			continue
+		}
+		if opts.Matches(device) {
+			matches = append(matches, entry{device: device, position: position})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].position.before(opts.Sort, matches[j].position)
+	})
+
+	page := DevicePage{Devices: make([]*domain.Device, 0, len(matches))}
+	for _, match := range matches {
+		if opts.Limit > 0 && len(page.Devices) == opts.Limit {
+			page.NextCursor = EncodeDeviceCursor(opts.Sort, matches[opts.Limit-1].position)
+			break
+		}
+		page.Devices = append(page.Devices, match.device)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,221 @@
+package persistencetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// JournalFactory returns a new, empty signature journal for a single test.
+// deviceIDs lists the devices the test will record signatures for, so that
+// backends enforcing referential integrity can create them first.
+type JournalFactory func(t *testing.T, deviceIDs ...string) persistence.SignatureJournal
+
+// RunSignatureJournalSuite runs the shared conformance tests against the
+// journals returned by newJournal.
+func RunSignatureJournalSuite(t *testing.T, newJournal JournalFactory) {
+	t.Run("AppendAndList", func(t *testing.T) { testJournalAppendAndList(t, newJournal) })
+	t.Run("Pagination", func(t *testing.T) { testJournalPagination(t, newJournal) })
+	t.Run("PaginationGap", func(t *testing.T) { testJournalPaginationGap(t, newJournal) })
+	t.Run("CanceledContext", func(t *testing.T) { testJournalCanceledContext(t, newJournal) })
+}
+
+// NewSignatureRecord returns a signing event for the given device and counter.
+func NewSignatureRecord(deviceID string, counter int) domain.SignatureRecord {
+	return domain.SignatureRecord{
+		DeviceID:   deviceID,
+		Counter:    counter,
+		Data:       "data",
+		SignedData: "signed data",
+		Signature:  "c2lnbmF0dXJl",
+		Algorithm:  domain.AlgorithmECDSA,
+		CreatedAt:  time.Date(2024, 1, 1, 12, 0, counter, 0, time.UTC),
+	}
+}
+
+func testJournalAppendAndList(t *testing.T, newJournal JournalFactory) {
+	journal := newJournal(t, "device-1", "device-2")
+	ctx := context.Background()
+
+	// Concurrent signers may append out of counter order.
+	for _, counter := range []int{1, 0, 2} {
+		if err := journal.Append(ctx, NewSignatureRecord("device-1", counter)); err != nil {
+			t.Fatalf("failed to append counter %d: %v", counter, err)
+		}
+	}
+	journal.Append(ctx, NewSignatureRecord("device-2", 0))
+
+	t.Run("error - duplicate counter", func(t *testing.T) {
+		err := journal.Append(ctx, NewSignatureRecord("device-1", 1))
+		if !errors.Is(err, persistence.ErrSignatureAlreadyRecorded) {
+			t.Errorf("expected error %v, got %v", persistence.ErrSignatureAlreadyRecorded, err)
+		}
+	})
+
+	t.Run("success - list in counter order", func(t *testing.T) {
+		page, err := journal.List(ctx, "device-1", "", 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(page.Records) != 3 {
+			t.Fatalf("expected 3 records, got %d", len(page.Records))
+		}
+		for i, record := range page.Records {
+			if record.Counter != i {
+				t.Errorf("expected counter %d at position %d, got %d", i, i, record.Counter)
+			}
+		}
+
+		want := NewSignatureRecord("device-1", 0)
+		got := page.Records[0]
+		if got.Data != want.Data || got.SignedData != want.SignedData || got.Signature != want.Signature ||
+			got.Algorithm != want.Algorithm || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("expected record %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("success - unknown device has no records", func(t *testing.T) {
+		page, err := journal.List(ctx, "unknown", "", 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(page.Records) != 0 || page.NextCursor != "" {
+			t.Errorf("expected empty page, got %+v", page)
+		}
+	})
+}
+
+func testJournalPagination(t *testing.T, newJournal JournalFactory) {
+	journal := newJournal(t, "device-1")
+	ctx := context.Background()
+
+	for counter := 0; counter < 7; counter++ {
+		journal.Append(ctx, NewSignatureRecord("device-1", counter))
+	}
+
+	var counters []int
+	cursor := ""
+	pages := 0
+	for {
+		page, err := journal.List(ctx, "device-1", cursor, 3)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		pages++
+		for _, record := range page.Records {
+			counters = append(counters, record.Counter)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+	if len(counters) != 7 {
+		t.Fatalf("expected 7 records, got %d", len(counters))
+	}
+	for i, counter := range counters {
+		if counter != i {
+			t.Errorf("expected counter %d at position %d, got %d", i, i, counter)
+		}
+	}
+
+	t.Run("error - invalid cursor", func(t *testing.T) {
+		_, err := journal.List(ctx, "device-1", "not a cursor!", 3)
+		if !errors.Is(err, persistence.ErrInvalidCursor) {
+			t.Errorf("expected error %v, got %v", persistence.ErrInvalidCursor, err)
+		}
+	})
+}
+
+func testJournalPaginationGap(t *testing.T, newJournal JournalFactory) {
+	journal := newJournal(t, "device-1", "device-2")
+	ctx := context.Background()
+
+	// Counter 3 is missing, as if it were still being written.
+	for _, counter := range []int{0, 1, 2, 4} {
+		journal.Append(ctx, NewSignatureRecord("device-1", counter))
+	}
+	// Imported devices start their journal at a later counter.
+	for _, counter := range []int{5, 6, 7} {
+		journal.Append(ctx, NewSignatureRecord("device-2", counter))
+	}
+
+	counters := func(page persistence.SignaturePage) []int {
+		result := make([]int, 0, len(page.Records))
+		for _, record := range page.Records {
+			result = append(result, record.Counter)
+		}
+		return result
+	}
+	list := func(deviceID, cursor string, limit int) persistence.SignaturePage {
+		t.Helper()
+		page, err := journal.List(ctx, deviceID, cursor, limit)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return page
+	}
+
+	first := list("device-1", "", 2)
+	if got := counters(first); len(got) != 2 || got[0] != 0 || got[1] != 1 || first.NextCursor == "" {
+		t.Fatalf("expected counters [0 1] and a cursor, got %v %q", got, first.NextCursor)
+	}
+
+	t.Run("success - page ends at the gap", func(t *testing.T) {
+		page := list("device-1", first.NextCursor, 2)
+		if got := counters(page); len(got) != 1 || got[0] != 2 || page.NextCursor != "" {
+			t.Errorf("expected counters [2] and no cursor, got %v %q", got, page.NextCursor)
+		}
+	})
+
+	t.Run("success - cursor before the gap stays empty", func(t *testing.T) {
+		page := list("device-1", persistence.EncodeSignatureCursor(2), 2)
+		if got := counters(page); len(got) != 0 || page.NextCursor != "" {
+			t.Errorf("expected empty page, got %v %q", got, page.NextCursor)
+		}
+	})
+
+	t.Run("success - unlimited listing returns every record", func(t *testing.T) {
+		if got := counters(list("device-1", "", 0)); len(got) != 4 {
+			t.Errorf("expected 4 records, got %v", got)
+		}
+	})
+
+	t.Run("success - first page starts at the first counter", func(t *testing.T) {
+		page := list("device-2", "", 2)
+		if got := counters(page); len(got) != 2 || got[0] != 5 || got[1] != 6 || page.NextCursor == "" {
+			t.Errorf("expected counters [5 6] and a cursor, got %v %q", got, page.NextCursor)
+		}
+	})
+
+	t.Run("success - page continues once the gap is filled", func(t *testing.T) {
+		journal.Append(ctx, NewSignatureRecord("device-1", 3))
+
+		page := list("device-1", first.NextCursor, 2)
+		if got := counters(page); len(got) != 2 || got[0] != 2 || got[1] != 3 || page.NextCursor == "" {
+			t.Errorf("expected counters [2 3] and a cursor, got %v %q", got, page.NextCursor)
+		}
+	})
+}
+
+func testJournalCanceledContext(t *testing.T, newJournal JournalFactory) {
+	journal := newJournal(t, "device-1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := journal.Append(ctx, NewSignatureRecord("device-1", 0)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Append: expected error %v, got %v", context.Canceled, err)
+	}
+	if _, err := journal.List(ctx, "device-1", "", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("List: expected error %v, got %v", context.Canceled, err)
+	}
+}
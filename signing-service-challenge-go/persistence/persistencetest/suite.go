@@ -0,0 +1,719 @@
+// Package persistencetest provides a conformance suite that every
+// persistence.DeviceRepository implementation must pass.
+package persistencetest
+
+import (
+	"context"
+	gocrypto "crypto"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// Factory returns a new, empty repository for a single test.
+type Factory func(t *testing.T) persistence.DeviceRepository
+
+// RunDeviceRepositorySuite runs the shared conformance tests against the
+// repositories returned by newRepository.
+func RunDeviceRepositorySuite(t *testing.T, newRepository Factory) {
+	t.Run("Create", func(t *testing.T) { testCreate(t, newRepository) })
+	t.Run("Get", func(t *testing.T) { testGet(t, newRepository) })
+	t.Run("List", func(t *testing.T) { testList(t, newRepository) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepository) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newRepository) })
+	t.Run("CanceledContext", func(t *testing.T) { testCanceledContext(t, newRepository) })
+	t.Run("ConcurrentCreate", func(t *testing.T) { testConcurrentCreate(t, newRepository) })
+}
+
+// NewRSADevice returns a device with a freshly generated RSA key pair.
+func NewRSADevice(t *testing.T, id string) *domain.Device {
+	t.Helper()
+
+	generator := &crypto.RSAGenerator{}
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+
+	return domain.NewDevice(id, domain.AlgorithmRSA, "RSA "+id, keyPair.Public, keyPair.Private)
+}
+
+// NewECDSADevice returns a device with a freshly generated ECDSA key pair on
+// the default curve.
+func NewECDSADevice(t *testing.T, id string) *domain.Device {
+	t.Helper()
+
+	return NewECDSACurveDevice(t, id, crypto.DefaultCurve)
+}
+
+// NewECDSACurveDevice returns a device with a freshly generated ECDSA key pair
+// on the named curve.
+func NewECDSACurveDevice(t *testing.T, id string, curve string) *domain.Device {
+	t.Helper()
+
+	generator := &crypto.ECCGenerator{Curve: curve}
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key pair: %v", err)
+	}
+
+	return domain.NewDevice(id, domain.AlgorithmECDSA, "ECDSA "+id, keyPair.Public, keyPair.Private)
+}
+
+// NewEd25519Device returns a device with a freshly generated Ed25519 key pair.
+func NewEd25519Device(t *testing.T, id string) *domain.Device {
+	t.Helper()
+
+	generator := &crypto.Ed25519Generator{}
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key pair: %v", err)
+	}
+
+	return domain.NewDevice(id, domain.AlgorithmEd25519, "Ed25519 "+id, keyPair.Public, keyPair.Private)
+}
+
+// DeleteDevice soft deletes the device, sealing its chain first unless it is
+// retired.
+func DeleteDevice(t *testing.T, device *domain.Device) {
+	t.Helper()
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		t.Fatalf("failed to look up algorithm: %v", err)
+	}
+	if _, err := device.Delete(algorithm.NewSigner, nil); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+}
+
+// RotateKey rotates the device to a freshly generated key pair of the same
+// algorithm and key options.
+func RotateKey(t *testing.T, device *domain.Device) {
+	t.Helper()
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		t.Fatalf("failed to look up algorithm: %v", err)
+	}
+	publicKey, privateKey, err := algorithm.GenerateKey(device.KeyOptions())
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	if _, err := device.RotateKey(algorithm.NewSigner, publicKey, privateKey, nil, nil); err != nil {
+		t.Fatalf("failed to rotate key: %v", err)
+	}
+}
+
+func testCreate(t *testing.T, newRepository Factory) {
+	tests := []struct {
+		name      string
+		setup     func(persistence.DeviceRepository)
+		device    *domain.Device
+		wantError error
+	}{
+		{
+			name:      "success - create RSA device",
+			setup:     func(persistence.DeviceRepository) {},
+			device:    NewRSADevice(t, "rsa-device"),
+			wantError: nil,
+		},
+		{
+			name:      "success - create ECDSA device",
+			setup:     func(persistence.DeviceRepository) {},
+			device:    NewECDSADevice(t, "ecdsa-device"),
+			wantError: nil,
+		},
+		{
+			name:      "success - create Ed25519 device",
+			setup:     func(persistence.DeviceRepository) {},
+			device:    NewEd25519Device(t, "ed25519-device"),
+			wantError: nil,
+		},
+		{
+			name: "error - duplicate device ID",
+			setup: func(repo persistence.DeviceRepository) {
+				repo.Create(context.Background(), NewECDSADevice(t, "duplicate-id"))
+			},
+			device:    NewECDSADevice(t, "duplicate-id"),
+			wantError: persistence.ErrDeviceAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t)
+			tt.setup(repo)
+
+			err := repo.Create(context.Background(), tt.device)
+
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func testGet(t *testing.T, newRepository Factory) {
+	repo := newRepository(t)
+	ctx := context.Background()
+
+	device := NewRSADevice(t, "existing-device")
+	device.SignatureCounter = 7
+	device.LastSignature = "bGFzdA=="
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	t.Run("success - get existing device", func(t *testing.T) {
+		got, err := repo.Get(ctx, "existing-device")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		AssertDeviceEqual(t, device, got)
+	})
+
+	t.Run("error - device not found", func(t *testing.T) {
+		_, err := repo.Get(ctx, "non-existent")
+		if !errors.Is(err, persistence.ErrDeviceNotFound) {
+			t.Errorf("expected error %v, got %v", persistence.ErrDeviceNotFound, err)
+		}
+	})
+}
+
+func testList(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		deviceCount   int
+		expectedCount int
+	}{
+		{name: "success - empty repository", deviceCount: 0, expectedCount: 0},
+		{name: "success - multiple devices", deviceCount: 3, expectedCount: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t)
+			for i := 0; i < tt.deviceCount; i++ {
+				repo.Create(ctx, NewECDSADevice(t, fmt.Sprintf("device-%d", i)))
+			}
+
+			page, err := repo.List(ctx, persistence.ListOptions{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(page.Devices) != tt.expectedCount {
+				t.Errorf("expected %d devices, got %d", tt.expectedCount, len(page.Devices))
+			}
+			if page.NextCursor != "" {
+				t.Errorf("expected no next cursor, got %q", page.NextCursor)
+			}
+		})
+	}
+
+	t.Run("orders and pages by ID", func(t *testing.T) {
+		repo := newRepository(t)
+		for _, id := range []string{"device-c", "device-a", "device-e", "device-b", "device-d"} {
+			if err := repo.Create(ctx, NewEd25519Device(t, id)); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+		}
+
+		sorts := []struct {
+			sort     persistence.DeviceSort
+			expected []string
+		}{
+			{sort: "", expected: []string{"device-a", "device-b", "device-c", "device-d", "device-e"}},
+			{sort: persistence.SortByID, expected: []string{"device-a", "device-b", "device-c", "device-d", "device-e"}},
+			{sort: persistence.SortByIDDesc, expected: []string{"device-e", "device-d", "device-c", "device-b", "device-a"}},
+		}
+		for _, tt := range sorts {
+			if got := listAllIDs(t, repo, persistence.ListOptions{Sort: tt.sort, Limit: 2}); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("sort %q: expected %v, got %v", tt.sort, tt.expected, got)
+			}
+		}
+	})
+
+	t.Run("orders, pages and filters by creation time", func(t *testing.T) {
+		repo := newRepository(t)
+		day := func(d int) time.Time { return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC) }
+		created := map[string]time.Time{"device-b": day(1), "device-a": day(2), "device-c": day(2), "device-d": day(3)}
+		for id, at := range created {
+			device := NewEd25519Device(t, id)
+			device.CreatedAt = at
+			if err := repo.Create(ctx, device); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+		}
+
+		listings := []struct {
+			name     string
+			opts     persistence.ListOptions
+			expected []string
+		}{
+			{name: "oldest first", opts: persistence.ListOptions{Sort: persistence.SortByCreatedAt, Limit: 1}, expected: []string{"device-b", "device-a", "device-c", "device-d"}},
+			{name: "newest first", opts: persistence.ListOptions{Sort: persistence.SortByCreatedAtDesc, Limit: 1}, expected: []string{"device-d", "device-c", "device-a", "device-b"}},
+			{name: "created range", opts: persistence.ListOptions{CreatedFrom: day(2), CreatedBefore: day(3)}, expected: []string{"device-a", "device-c"}},
+			{name: "created from", opts: persistence.ListOptions{Sort: persistence.SortByCreatedAtDesc, CreatedFrom: day(2), Limit: 2}, expected: []string{"device-d", "device-c", "device-a"}},
+		}
+		for _, tt := range listings {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := listAllIDs(t, repo, tt.opts); !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("expected %v, got %v", tt.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("filters devices", func(t *testing.T) {
+		repo := newRepository(t)
+
+		store := func(device *domain.Device, store string) *domain.Device {
+			if err := device.UpdateDetails(nil, map[string]*string{"store": &store}); err != nil {
+				t.Fatalf("failed to set metadata: %v", err)
+			}
+			return device
+		}
+		devices := []*domain.Device{
+			store(NewECDSADevice(t, "device-1"), "berlin"),
+			store(NewEd25519Device(t, "device-2"), "berlin"),
+			store(NewEd25519Device(t, "device-3"), "hamburg"),
+			NewEd25519Device(t, "device-4"),
+		}
+		for _, device := range devices {
+			if err := repo.Create(ctx, device); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+		}
+		if err := devices[2].Suspend(); err != nil {
+			t.Fatalf("failed to suspend device: %v", err)
+		}
+		if err := repo.Update(ctx, devices[2]); err != nil {
+			t.Fatalf("failed to update device: %v", err)
+		}
+
+		filters := []struct {
+			name     string
+			opts     persistence.ListOptions
+			expected []string
+		}{
+			{name: "algorithm", opts: persistence.ListOptions{Algorithm: domain.AlgorithmEd25519}, expected: []string{"device-2", "device-3", "device-4"}},
+			{name: "label substring", opts: persistence.ListOptions{Label: "ECDSA"}, expected: []string{"device-1"}},
+			{name: "label is case sensitive", opts: persistence.ListOptions{Label: "ecdsa"}, expected: []string{}},
+			{name: "status", opts: persistence.ListOptions{Status: domain.StatusSuspended}, expected: []string{"device-3"}},
+			{name: "active status", opts: persistence.ListOptions{Status: domain.StatusActive}, expected: []string{"device-1", "device-2", "device-4"}},
+			{name: "metadata", opts: persistence.ListOptions{Metadata: map[string]string{"store": "berlin"}}, expected: []string{"device-1", "device-2"}},
+			{name: "unknown metadata key", opts: persistence.ListOptions{Metadata: map[string]string{"tenant": "berlin"}}, expected: []string{}},
+			{
+				name:     "combined",
+				opts:     persistence.ListOptions{Algorithm: domain.AlgorithmEd25519, Metadata: map[string]string{"store": "berlin"}},
+				expected: []string{"device-2"},
+			},
+			{
+				name:     "paged",
+				opts:     persistence.ListOptions{Algorithm: domain.AlgorithmEd25519, Sort: persistence.SortByIDDesc, Limit: 1},
+				expected: []string{"device-4", "device-3", "device-2"},
+			},
+		}
+		for _, tt := range filters {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := listAllIDs(t, repo, tt.opts); !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("expected %v, got %v", tt.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("error - invalid options", func(t *testing.T) {
+		repo := newRepository(t)
+		if err := repo.Create(ctx, NewEd25519Device(t, "device-1")); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+		ascending := persistence.EncodeDeviceCursor(persistence.SortByID, persistence.DevicePosition{ID: "device-1"})
+
+		invalid := []struct {
+			name     string
+			opts     persistence.ListOptions
+			expected error
+		}{
+			{name: "malformed cursor", opts: persistence.ListOptions{Cursor: "not a cursor!"}, expected: persistence.ErrInvalidCursor},
+			{name: "cursor of another order", opts: persistence.ListOptions{Cursor: ascending, Sort: persistence.SortByIDDesc}, expected: persistence.ErrInvalidCursor},
+			{name: "unknown sort", opts: persistence.ListOptions{Sort: "label"}, expected: persistence.ErrInvalidSort},
+		}
+		for _, tt := range invalid {
+			if _, err := repo.List(ctx, tt.opts); !errors.Is(err, tt.expected) {
+				t.Errorf("%s: expected error %v, got %v", tt.name, tt.expected, err)
+			}
+		}
+	})
+}
+
+// listAllIDs follows the cursors of a listing to its end and returns the IDs
+// of all listed devices.
+func listAllIDs(t *testing.T, repo persistence.DeviceRepository, opts persistence.ListOptions) []string {
+	t.Helper()
+
+	ids := []string{}
+	for {
+		page, err := repo.List(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("failed to list devices: %v", err)
+		}
+		if opts.Limit > 0 && len(page.Devices) > opts.Limit {
+			t.Fatalf("expected at most %d devices, got %d", opts.Limit, len(page.Devices))
+		}
+		for _, device := range page.Devices {
+			ids = append(ids, device.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func testUpdate(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("success - update persists counter and last signature", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		stored, _ := repo.Get(ctx, "device-1")
+		algorithm, err := crypto.Lookup(string(stored.Algorithm))
+		if err != nil {
+			t.Fatalf("failed to look up algorithm: %v", err)
+		}
+		if _, err := stored.SignWithCurrentKey(algorithm.NewSigner, "data", nil); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		if err := repo.Update(ctx, stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got, err := repo.Get(ctx, "device-1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.SignatureCounter != 1 {
+			t.Errorf("expected counter 1, got %d", got.SignatureCounter)
+		}
+		if got.LastSignature != stored.LastSignature {
+			t.Errorf("expected last signature %q, got %q", stored.LastSignature, got.LastSignature)
+		}
+	})
+
+	t.Run("success - update persists key rotation", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		stored, _ := repo.Get(ctx, "device-1")
+		want := stored.Clone()
+		RotateKey(t, want)
+		// Apply the rotation to the stored device, as a handler would.
+		stored.PublicKey, stored.PrivateKey = want.PublicKey, want.PrivateKey
+		stored.RetiredKeys = want.RetiredKeys
+		stored.SignatureCounter, stored.LastSignature = want.SignatureCounter, want.LastSignature
+		stored.UpdatedAt, stored.LastSignedAt = want.UpdatedAt, want.LastSignedAt
+		if err := repo.Update(ctx, stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got, err := repo.Get(ctx, "device-1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		AssertDeviceEqual(t, want, got)
+	})
+
+	t.Run("success - update persists status", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		stored, _ := repo.Get(ctx, "device-1")
+		if err := stored.Suspend(); err != nil {
+			t.Fatalf("failed to suspend: %v", err)
+		}
+		if err := repo.Update(ctx, stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got, err := repo.Get(ctx, "device-1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Status != domain.StatusSuspended {
+			t.Errorf("expected status %q, got %q", domain.StatusSuspended, got.Status)
+		}
+	})
+
+	t.Run("success - update persists label and metadata", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		stored, _ := repo.Get(ctx, "device-1")
+		label, store := "register 7", "42"
+		if err := stored.UpdateDetails(&label, map[string]*string{"store_id": &store}); err != nil {
+			t.Fatalf("failed to update details: %v", err)
+		}
+		if err := repo.Update(ctx, stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got, err := repo.Get(ctx, "device-1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Label != label || got.Metadata["store_id"] != store {
+			t.Errorf("expected label %q and store_id %q, got %q and %v", label, store, got.Label, got.Metadata)
+		}
+	})
+
+	t.Run("error - counter regression", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		device.SignatureCounter = 5
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		stale := device.Clone()
+		stale.SignatureCounter = 4
+
+		err := repo.Update(ctx, stale)
+		if !errors.Is(err, persistence.ErrCounterRegression) {
+			t.Errorf("expected error %v, got %v", persistence.ErrCounterRegression, err)
+		}
+
+		got, _ := repo.Get(ctx, "device-1")
+		if got.SignatureCounter != 5 {
+			t.Errorf("expected counter 5, got %d", got.SignatureCounter)
+		}
+	})
+
+	t.Run("error - update non-existent device", func(t *testing.T) {
+		repo := newRepository(t)
+
+		err := repo.Update(ctx, NewECDSADevice(t, "non-existent"))
+		if !errors.Is(err, persistence.ErrDeviceNotFound) {
+			t.Errorf("expected error %v, got %v", persistence.ErrDeviceNotFound, err)
+		}
+	})
+}
+
+func testDelete(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("success - delete keeps public key and chain state", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		stored, _ := repo.Get(ctx, "device-1")
+		publicKey := stored.PublicKey
+		DeleteDevice(t, stored)
+		if err := repo.Delete(ctx, stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got, err := repo.Get(ctx, "device-1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Status != domain.StatusDeleted || got.PrivateKey != nil {
+			t.Errorf("expected deleted device without private key, got status %q", got.Status)
+		}
+		if key, ok := got.PublicKey.(interface{ Equal(gocrypto.PublicKey) bool }); !ok || !key.Equal(publicKey) {
+			t.Error("expected public key to be kept")
+		}
+		if got.SignatureCounter != 1 {
+			t.Errorf("expected counter 1 after the decommission record, got %d", got.SignatureCounter)
+		}
+	})
+
+	t.Run("error - device not deleted", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "device-1")
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+
+		err := repo.Delete(ctx, device)
+		if !errors.Is(err, persistence.ErrDeviceNotDeleted) {
+			t.Errorf("expected error %v, got %v", persistence.ErrDeviceNotDeleted, err)
+		}
+	})
+
+	t.Run("error - delete non-existent device", func(t *testing.T) {
+		repo := newRepository(t)
+		device := NewECDSADevice(t, "non-existent")
+		DeleteDevice(t, device)
+
+		err := repo.Delete(ctx, device)
+		if !errors.Is(err, persistence.ErrDeviceNotFound) {
+			t.Errorf("expected error %v, got %v", persistence.ErrDeviceNotFound, err)
+		}
+	})
+}
+
+func testCanceledContext(t *testing.T, newRepository Factory) {
+	repo := newRepository(t)
+	device := NewECDSADevice(t, "device-1")
+	if err := repo.Create(context.Background(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := repo.Create(ctx, NewECDSADevice(t, "device-2")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Create: expected error %v, got %v", context.Canceled, err)
+	}
+	if _, err := repo.Get(ctx, "device-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get: expected error %v, got %v", context.Canceled, err)
+	}
+	if _, err := repo.List(ctx, persistence.ListOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("List: expected error %v, got %v", context.Canceled, err)
+	}
+	if err := repo.Update(ctx, device); !errors.Is(err, context.Canceled) {
+		t.Errorf("Update: expected error %v, got %v", context.Canceled, err)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, newRepository Factory) {
+	repo := newRepository(t)
+	device := NewECDSADevice(t, "contended-id")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Create(context.Background(), device); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("expected exactly one successful create, got %d", created)
+	}
+}
+
+// AssertDeviceEqual fails the test if got does not carry the same persisted
+// state as want.
+func AssertDeviceEqual(t *testing.T, want, got *domain.Device) {
+	t.Helper()
+
+	if got.ID != want.ID {
+		t.Errorf("expected ID %q, got %q", want.ID, got.ID)
+	}
+	if got.Algorithm != want.Algorithm {
+		t.Errorf("expected algorithm %q, got %q", want.Algorithm, got.Algorithm)
+	}
+	if got.Label != want.Label {
+		t.Errorf("expected label %q, got %q", want.Label, got.Label)
+	}
+	if (len(got.Metadata) != 0 || len(want.Metadata) != 0) && !reflect.DeepEqual(got.Metadata, want.Metadata) {
+		t.Errorf("expected metadata %v, got %v", want.Metadata, got.Metadata)
+	}
+	if got.Curve != want.Curve {
+		t.Errorf("expected curve %q, got %q", want.Curve, got.Curve)
+	}
+	if got.RSAScheme != want.RSAScheme {
+		t.Errorf("expected RSA scheme %q, got %q", want.RSAScheme, got.RSAScheme)
+	}
+	if got.Deterministic != want.Deterministic {
+		t.Errorf("expected deterministic %t, got %t", want.Deterministic, got.Deterministic)
+	}
+	if got.SignatureEncoding != want.SignatureEncoding {
+		t.Errorf("expected signature encoding %q, got %q", want.SignatureEncoding, got.SignatureEncoding)
+	}
+	if got.SignatureCounter != want.SignatureCounter {
+		t.Errorf("expected counter %d, got %d", want.SignatureCounter, got.SignatureCounter)
+	}
+	if got.LastSignature != want.LastSignature {
+		t.Errorf("expected last signature %q, got %q", want.LastSignature, got.LastSignature)
+	}
+	if status(got) != status(want) {
+		t.Errorf("expected status %q, got %q", status(want), status(got))
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) || !got.LastSignedAt.Equal(want.LastSignedAt) {
+		t.Errorf("expected created, updated and last signed at %v, %v, %v, got %v, %v, %v",
+			want.CreatedAt, want.UpdatedAt, want.LastSignedAt, got.CreatedAt, got.UpdatedAt, got.LastSignedAt)
+	}
+
+	if want.PrivateKey == nil {
+		if got.PrivateKey != nil {
+			t.Error("expected no private key")
+		}
+	} else {
+		privateKey, ok := got.PrivateKey.(interface {
+			Equal(gocrypto.PrivateKey) bool
+		})
+		if !ok || !privateKey.Equal(want.PrivateKey) {
+			t.Error("expected private key to be preserved")
+		}
+	}
+	publicKey, ok := got.PublicKey.(interface{ Equal(gocrypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(want.PublicKey) {
+		t.Error("expected public key to be preserved")
+	}
+
+	if len(got.RetiredKeys) != len(want.RetiredKeys) {
+		t.Fatalf("expected %d retired keys, got %d", len(want.RetiredKeys), len(got.RetiredKeys))
+	}
+	for i, retired := range got.RetiredKeys {
+		wantRetired := want.RetiredKeys[i]
+		if retired.FirstCounter != wantRetired.FirstCounter || retired.LastCounter != wantRetired.LastCounter {
+			t.Errorf("expected retired key %d to cover counters %d-%d, got %d-%d",
+				i, wantRetired.FirstCounter, wantRetired.LastCounter, retired.FirstCounter, retired.LastCounter)
+		}
+		if !retired.RetiredAt.Equal(wantRetired.RetiredAt) {
+			t.Errorf("expected retired key %d retired at %v, got %v", i, wantRetired.RetiredAt, retired.RetiredAt)
+		}
+		publicKey, ok := retired.PublicKey.(interface{ Equal(gocrypto.PublicKey) bool })
+		if !ok || !publicKey.Equal(wantRetired.PublicKey) {
+			t.Errorf("expected retired key %d to be preserved", i)
+		}
+	}
+}
+
+// status returns the lifecycle state of a device, treating an empty status as
+// active like the repositories do.
+func status(device *domain.Device) domain.DeviceStatus {
+	if device.Status == "" {
+		return domain.StatusActive
+	}
+	return device.Status
+}
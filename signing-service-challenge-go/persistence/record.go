@@ -0,0 +1,280 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// ErrMasterKeyRequired is returned when a record holds an encrypted private key
+// but none of the configured master keys can unwrap it.
+var ErrMasterKeyRequired = errors.New("private key is encrypted and no matching master key is configured")
+
+// DeviceRecord is the serializable form of a signature device used by the
+// durable storage backends. Keys are stored PEM encoded using the crypto
+// marshalers; on decode the public key is derived from the private key.
+// Deleted devices have no private key; they are restored from the public key.
+// When a master key is configured, the private key PEM is replaced by an
+// envelope in EncryptedPrivateKey (see SealPrivateKey).
+type DeviceRecord struct {
+	ID                string                    `json:"id"`
+	Algorithm         domain.SignatureAlgorithm `json:"algorithm"`
+	Label             string                    `json:"label,omitempty"`
+	Metadata          map[string]string         `json:"metadata,omitempty"`
+	Curve             string                    `json:"curve,omitempty"`
+	RSAScheme         string                    `json:"rsa_scheme,omitempty"`
+	Deterministic     bool                      `json:"deterministic,omitempty"`
+	SignatureEncoding string                    `json:"signature_encoding,omitempty"`
+	SignatureCounter  int                       `json:"signature_counter"`
+	LastSignature     string                    `json:"last_signature,omitempty"`
+	PublicKey         string                    `json:"public_key"`
+	PrivateKey        string                    `json:"private_key,omitempty"`
+	RetiredKeys       []RetiredKeyRecord        `json:"retired_keys,omitempty"`
+	Status            domain.DeviceStatus       `json:"status,omitempty"` // empty in records written before lifecycle states
+	CreatedAt         time.Time                 `json:"created_at"`       // zero in records written before timestamps
+	UpdatedAt         time.Time                 `json:"updated_at"`
+	LastSignedAt      time.Time                 `json:"last_signed_at"`
+
+	EncryptedPrivateKey *crypto.WrappedKey `json:"encrypted_private_key,omitempty"`
+}
+
+// RetiredKeyRecord is the serializable form of a domain.RetiredKey, with the
+// public key PEM encoded.
+type RetiredKeyRecord struct {
+	PublicKey    string    `json:"public_key"`
+	FirstCounter int       `json:"first_counter"`
+	LastCounter  int       `json:"last_counter"`
+	RetiredAt    time.Time `json:"retired_at"`
+}
+
+// NewDeviceRecord captures a consistent snapshot of the device as a record.
+func NewDeviceRecord(device *domain.Device) (DeviceRecord, error) {
+	snapshot := device.Clone()
+	defer crypto.ZeroizePrivateKey(snapshot.PrivateKey)
+
+	publicKey, privateKey, err := encodeKeyPair(snapshot)
+	if err != nil {
+		return DeviceRecord{}, err
+	}
+	retiredKeys, err := EncodeRetiredKeys(snapshot.RetiredKeys)
+	if err != nil {
+		return DeviceRecord{}, fmt.Errorf("device %s: %w", snapshot.ID, err)
+	}
+	status := snapshot.Status
+	if status == "" {
+		status = domain.StatusActive
+	}
+
+	return DeviceRecord{
+		ID:                snapshot.ID,
+		Algorithm:         snapshot.Algorithm,
+		Label:             snapshot.Label,
+		Metadata:          snapshot.Metadata,
+		Curve:             snapshot.Curve,
+		RSAScheme:         snapshot.RSAScheme,
+		Deterministic:     snapshot.Deterministic,
+		SignatureEncoding: snapshot.SignatureEncoding,
+		SignatureCounter:  snapshot.SignatureCounter,
+		LastSignature:     snapshot.LastSignature,
+		PublicKey:         string(publicKey),
+		PrivateKey:        string(privateKey),
+		RetiredKeys:       retiredKeys,
+		Status:            status,
+		CreatedAt:         snapshot.CreatedAt,
+		UpdatedAt:         snapshot.UpdatedAt,
+		LastSignedAt:      snapshot.LastSignedAt,
+	}, nil
+}
+
+// SealPrivateKey replaces the clear PEM private key with an envelope sealed
+// under masterKey. The device ID is bound to the envelope as associated data,
+// so an encrypted key cannot be moved to another device. A nil masterKey
+// leaves the record unchanged, as do records without a private key.
+func (r *DeviceRecord) SealPrivateKey(masterKey *crypto.MasterKey) error {
+	if masterKey == nil || r.EncryptedPrivateKey != nil || r.PrivateKey == "" {
+		return nil
+	}
+
+	wrapped, err := masterKey.Seal([]byte(r.PrivateKey), []byte(r.ID))
+	if err != nil {
+		return fmt.Errorf("device %s: seal private key: %w", r.ID, err)
+	}
+
+	r.EncryptedPrivateKey = wrapped
+	r.PrivateKey = ""
+	return nil
+}
+
+// OpenPrivateKey restores the clear PEM private key from its envelope using
+// whichever of masterKeys wrapped it. Records without an envelope are left
+// unchanged.
+func (r *DeviceRecord) OpenPrivateKey(masterKeys ...*crypto.MasterKey) error {
+	if r.EncryptedPrivateKey == nil {
+		return nil
+	}
+
+	masterKey := findMasterKey(r.EncryptedPrivateKey, masterKeys)
+	if masterKey == nil {
+		return fmt.Errorf("device %s: %w", r.ID, ErrMasterKeyRequired)
+	}
+
+	privateKey, err := masterKey.Open(r.EncryptedPrivateKey, []byte(r.ID))
+	if err != nil {
+		return fmt.Errorf("device %s: open private key: %w", r.ID, err)
+	}
+
+	r.PrivateKey = string(privateKey)
+	r.EncryptedPrivateKey = nil
+	return nil
+}
+
+// RewrapPrivateKey re-wraps the data key of the envelope under next, using
+// whichever of masterKeys currently wraps it. The encrypted private key itself
+// is not touched. A clear private key is sealed under next instead.
+func (r *DeviceRecord) RewrapPrivateKey(next *crypto.MasterKey, masterKeys ...*crypto.MasterKey) error {
+	if r.EncryptedPrivateKey == nil {
+		return r.SealPrivateKey(next)
+	}
+
+	masterKey := findMasterKey(r.EncryptedPrivateKey, masterKeys)
+	if masterKey == nil {
+		return fmt.Errorf("device %s: %w", r.ID, ErrMasterKeyRequired)
+	}
+
+	wrapped, err := masterKey.Rewrap(r.EncryptedPrivateKey, next)
+	if err != nil {
+		return fmt.Errorf("device %s: rewrap private key: %w", r.ID, err)
+	}
+
+	r.EncryptedPrivateKey = wrapped
+	return nil
+}
+
+// Device restores the signature device described by the record. Encrypted
+// records must be opened with OpenPrivateKey first.
+func (r DeviceRecord) Device() (*domain.Device, error) {
+	if r.EncryptedPrivateKey != nil {
+		return nil, fmt.Errorf("device %s: %w", r.ID, ErrMasterKeyRequired)
+	}
+
+	var publicKey, privateKey interface{}
+	var err error
+	if r.Status == domain.StatusDeleted {
+		publicKey, err = crypto.ParsePublicKeyPEM([]byte(r.PublicKey))
+	} else {
+		publicKey, privateKey, err = decodePrivateKey(r.Algorithm, []byte(r.PrivateKey))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("device %s: %w", r.ID, err)
+	}
+
+	device := domain.NewDevice(r.ID, r.Algorithm, r.Label, publicKey, privateKey)
+	device.Metadata = r.Metadata
+	// Records written before curves were stored have no curve; the key
+	// always tells.
+	if r.Curve != "" && r.Curve != device.Curve {
+		return nil, fmt.Errorf("device %s: stored curve %s does not match key curve %s", r.ID, r.Curve, device.Curve)
+	}
+	device.RSAScheme = r.RSAScheme
+	device.Deterministic = r.Deterministic
+	device.SignatureEncoding = r.SignatureEncoding
+	device.SignatureCounter = r.SignatureCounter
+	device.LastSignature = r.LastSignature
+	if device.RetiredKeys, err = DecodeRetiredKeys(r.RetiredKeys); err != nil {
+		return nil, fmt.Errorf("device %s: %w", r.ID, err)
+	}
+	// Records written before lifecycle states have no status and are active.
+	if r.Status != "" {
+		if !domain.ValidDeviceStatus(r.Status) {
+			return nil, fmt.Errorf("device %s: unknown status %q", r.ID, r.Status)
+		}
+		device.Status = r.Status
+	}
+	// Records written before timestamps keep zero ones rather than the time
+	// of loading.
+	device.CreatedAt = r.CreatedAt
+	device.UpdatedAt = r.UpdatedAt
+	device.LastSignedAt = r.LastSignedAt
+
+	return device, nil
+}
+
+// encodeKeyPair returns the PEM encoded public and private key of the device
+// using the marshaler registered for its algorithm. Deleted devices have no
+// private key; only their public key is encoded.
+func encodeKeyPair(device *domain.Device) ([]byte, []byte, error) {
+	if device.PrivateKey == nil && device.Status == domain.StatusDeleted {
+		publicKey, err := crypto.MarshalPublicKeyPEM(device.PublicKey)
+		return publicKey, nil, err
+	}
+
+	algorithm, err := crypto.Lookup(string(device.Algorithm))
+	if err != nil {
+		return nil, nil, err
+	}
+	return algorithm.MarshalPrivateKey(device.PrivateKey)
+}
+
+// EncodeRetiredKeys PEM encodes the public keys of retired keys.
+func EncodeRetiredKeys(retiredKeys []domain.RetiredKey) ([]RetiredKeyRecord, error) {
+	if len(retiredKeys) == 0 {
+		return nil, nil
+	}
+
+	records := make([]RetiredKeyRecord, len(retiredKeys))
+	for i, retired := range retiredKeys {
+		publicKey, err := crypto.MarshalPublicKeyPEM(retired.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("retired key %d: %w", i+1, err)
+		}
+		records[i] = RetiredKeyRecord{
+			PublicKey:    string(publicKey),
+			FirstCounter: retired.FirstCounter,
+			LastCounter:  retired.LastCounter,
+			RetiredAt:    retired.RetiredAt,
+		}
+	}
+	return records, nil
+}
+
+// DecodeRetiredKeys restores retired keys from their records.
+func DecodeRetiredKeys(records []RetiredKeyRecord) ([]domain.RetiredKey, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	retiredKeys := make([]domain.RetiredKey, len(records))
+	for i, record := range records {
+		publicKey, err := crypto.ParsePublicKeyPEM([]byte(record.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("retired key %d: %w", i+1, err)
+		}
+		retiredKeys[i] = domain.RetiredKey{
+			PublicKey:    publicKey,
+			FirstCounter: record.FirstCounter,
+			LastCounter:  record.LastCounter,
+			RetiredAt:    record.RetiredAt,
+		}
+	}
+	return retiredKeys, nil
+}
+
+func decodePrivateKey(name domain.SignatureAlgorithm, encoded []byte) (interface{}, interface{}, error) {
+	algorithm, err := crypto.Lookup(string(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return algorithm.UnmarshalPrivateKey(encoded)
+}
+
+func findMasterKey(wrapped *crypto.WrappedKey, masterKeys []*crypto.MasterKey) *crypto.MasterKey {
+	for _, masterKey := range masterKeys {
+		if masterKey != nil && masterKey.ID() == wrapped.MasterKeyID {
+			return masterKey
+		}
+	}
+	return nil
+}
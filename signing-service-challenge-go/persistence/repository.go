@@ -0,0 +1,42 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+var (
+	ErrDeviceNotFound      = errors.New("device not found")
+	ErrDeviceAlreadyExists = errors.New("device already exists")
+	ErrCounterRegression   = errors.New("signature counter must not decrease")
+	ErrDeviceNotDeleted    = errors.New("device has not been deleted")
+)
+
+// DeviceRepository is the storage contract for signature devices.
+// Every backend must satisfy the conformance suite in persistencetest.
+type DeviceRepository interface {
+	// Create stores a new device. It returns ErrDeviceAlreadyExists if a device
+	// with the same ID is already stored.
+	Create(ctx context.Context, device *domain.Device) error
+	// Get retrieves a device by ID. It returns ErrDeviceNotFound if no such
+	// device exists.
+	Get(ctx context.Context, id string) (*domain.Device, error)
+	// List returns a page of the stored devices that match the filters of
+	// opts, in the order of opts.Sort. It returns ErrInvalidCursor for a
+	// cursor that does not belong to a listing in the same order and
+	// ErrInvalidSort for an unknown order.
+	List(ctx context.Context, opts ListOptions) (DevicePage, error)
+	// Update persists the current state of an existing device. It returns
+	// ErrDeviceNotFound if the device has not been created before and
+	// ErrCounterRegression if the stored signature counter is ahead of the
+	// device's counter.
+	Update(ctx context.Context, device *domain.Device) error
+	// Delete persists the soft deletion of a device deleted with
+	// domain.Device.Delete. The device state is stored as by Update and its
+	// private key is erased from storage, while its public keys remain. It
+	// returns ErrDeviceNotDeleted if the device has not been deleted, and
+	// otherwise fails like Update.
+	Delete(ctx context.Context, device *domain.Device) error
+}
@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// SignatureJournal is a persistence.SignatureJournal stored in the same
+// SQLite database as the devices.
+type SignatureJournal struct {
+	db *sql.DB
+}
+
+var _ persistence.SignatureJournal = (*SignatureJournal)(nil)
+
+// SignatureJournal returns the signature journal sharing this repository's
+// database.
+func (r *Repository) SignatureJournal() *SignatureJournal {
+	return &SignatureJournal{db: r.db}
+}
+
+// Append records a signing event
+func (j *SignatureJournal) Append(ctx context.Context, record domain.SignatureRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := j.db.ExecContext(ctx, `
+		INSERT INTO signatures (device_id, counter, data, signed_data, signature, algorithm, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		record.DeviceID, record.Counter, record.Data, record.SignedData, record.Signature,
+		string(record.Algorithm), record.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return persistence.ErrSignatureAlreadyRecorded
+		}
+		return fmt.Errorf("insert signature: %w", err)
+	}
+
+	return nil
+}
+
+// List returns a page of a device's signing events
+func (j *SignatureJournal) List(ctx context.Context, deviceID string, cursor string, limit int) (persistence.SignaturePage, error) {
+	if err := ctx.Err(); err != nil {
+		return persistence.SignaturePage{}, err
+	}
+
+	after, err := persistence.DecodeSignatureCursor(cursor)
+	if err != nil {
+		return persistence.SignaturePage{}, err
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	queryLimit := -1
+	if limit > 0 {
+		queryLimit = limit + 1
+	}
+
+	rows, err := j.db.QueryContext(ctx, `
+		SELECT device_id, counter, data, signed_data, signature, algorithm, created_at
+		FROM signatures
+		WHERE device_id = ? AND counter > ?
+		ORDER BY counter
+		LIMIT ?`, deviceID, after, queryLimit)
+	if err != nil {
+		return persistence.SignaturePage{}, fmt.Errorf("query signatures: %w", err)
+	}
+	defer rows.Close()
+
+	page := persistence.SignaturePage{Records: make([]domain.SignatureRecord, 0)}
+	for rows.Next() {
+		var record domain.SignatureRecord
+		var algorithm, createdAt string
+
+		err := rows.Scan(&record.DeviceID, &record.Counter, &record.Data, &record.SignedData,
+			&record.Signature, &algorithm, &createdAt)
+		if err != nil {
+			return persistence.SignaturePage{}, fmt.Errorf("scan signature: %w", err)
+		}
+
+		record.Algorithm = domain.SignatureAlgorithm(algorithm)
+		record.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return persistence.SignaturePage{}, fmt.Errorf("parse signature timestamp: %w", err)
+		}
+
+		page.Records = append(page.Records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return persistence.SignaturePage{}, fmt.Errorf("query signatures: %w", err)
+	}
+
+	if limit > 0 {
+		page.Records, page.NextCursor = persistence.LimitSignaturePage(page.Records, after, limit)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations holds the schema changes in order. Entries are applied once and
+// recorded in schema_migrations; never edit an applied entry, append a new one.
+var migrations = []string{
+	// 1: devices with PEM encoded key material
+	`CREATE TABLE devices (
+		id                TEXT PRIMARY KEY,
+		algorithm         TEXT NOT NULL,
+		label             TEXT NOT NULL DEFAULT '',
+		signature_counter INTEGER NOT NULL DEFAULT 0 CHECK (signature_counter >= 0),
+		last_signature    TEXT NOT NULL DEFAULT '',
+		public_key        TEXT NOT NULL,
+		private_key       TEXT NOT NULL
+	)`,
+	// 2: envelope encrypted private keys; private_key is empty when sealed
+	`ALTER TABLE devices ADD COLUMN master_key_id TEXT;
+	ALTER TABLE devices ADD COLUMN wrapped_data_key BLOB;
+	ALTER TABLE devices ADD COLUMN encrypted_private_key BLOB`,
+	// 3: append-only signature journal
+	`CREATE TABLE signatures (
+		device_id   TEXT NOT NULL REFERENCES devices (id),
+		counter     INTEGER NOT NULL CHECK (counter >= 0),
+		data        TEXT NOT NULL,
+		signed_data TEXT NOT NULL,
+		signature   TEXT NOT NULL,
+		algorithm   TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		PRIMARY KEY (device_id, counter)
+	)`,
+	// 4: named curve of ECDSA devices
+	`ALTER TABLE devices ADD COLUMN curve TEXT NOT NULL DEFAULT ''`,
+	// 5: signature scheme of RSA devices
+	`ALTER TABLE devices ADD COLUMN rsa_scheme TEXT NOT NULL DEFAULT ''`,
+	// 6: RFC 6979 nonces for ECDSA devices
+	`ALTER TABLE devices ADD COLUMN deterministic INTEGER NOT NULL DEFAULT 0`,
+	// 7: signature encoding of ECDSA devices
+	`ALTER TABLE devices ADD COLUMN signature_encoding TEXT NOT NULL DEFAULT ''`,
+	// 8: public keys replaced by key rotations, as a JSON array of
+	// persistence.RetiredKeyRecord; empty for devices that never rotated
+	`ALTER TABLE devices ADD COLUMN retired_keys TEXT NOT NULL DEFAULT ''`,
+	// 9: lifecycle state; devices stored before it are active
+	`ALTER TABLE devices ADD COLUMN status TEXT NOT NULL DEFAULT 'ACTIVE'`,
+	// 10: client defined metadata as a JSON object; empty without metadata
+	`ALTER TABLE devices ADD COLUMN metadata TEXT NOT NULL DEFAULT ''`,
+	// 11: creation, update and last signing time as fixed width UTC
+	// timestamps (see timestampLayout); empty when unknown or never signed
+	`ALTER TABLE devices ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+	ALTER TABLE devices ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+	ALTER TABLE devices ADD COLUMN last_signed_at TEXT NOT NULL DEFAULT '';
+	CREATE INDEX devices_created_at ON devices (created_at, id)`,
+}
+
+// migrate applies every pending migration, each in its own transaction.
+func migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		if err := applyMigration(ctx, db, version, migrations[i]); err != nil {
+			return fmt.Errorf("apply migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version int, statement string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statement); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,621 @@
+// Package sqlite implements persistence.DeviceRepository on top of an
+// embedded SQLite database.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Repository stores signature devices in SQLite. Keys are stored PEM encoded
+// through persistence.DeviceRecord. Loaded devices are kept in an identity
+// map so that every caller in this process shares one *domain.Device per ID
+// and therefore one signing lock; the counter column is additionally guarded
+// by a compare-and-swap so it can never regress, even across processes.
+type Repository struct {
+	db                 *sql.DB
+	devices            map[string]*domain.Device
+	masterKey          *crypto.MasterKey
+	previousMasterKeys []*crypto.MasterKey
+	mu                 sync.Mutex
+}
+
+var _ persistence.DeviceRepository = (*Repository)(nil)
+
+// deviceColumns lists the columns read by scanRecord, in order.
+const deviceColumns = `id, algorithm, label, metadata, curve, rsa_scheme, deterministic, signature_encoding, signature_counter, last_signature, public_key,
+	private_key, retired_keys, status, master_key_id, wrapped_data_key, encrypted_private_key, created_at, updated_at, last_signed_at`
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithMasterKey enables envelope encryption of private keys at rest. New
+// devices are sealed under current; previous master keys are only used to
+// open devices that have not been re-wrapped yet.
+func WithMasterKey(current *crypto.MasterKey, previous ...*crypto.MasterKey) Option {
+	return func(r *Repository) {
+		r.masterKey = current
+		r.previousMasterKeys = previous
+	}
+}
+
+// Open opens the database at path, creating it if needed, and applies all
+// pending schema migrations.
+func Open(path string, opts ...Option) (*Repository, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL&_synchronous=FULL&_foreign_keys=on&_secure_delete=on")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; one connection keeps transactions simple.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	r := &Repository{
+		db:      db,
+		devices: make(map[string]*domain.Device),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Close closes the underlying database.
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+// Create stores a new device
+func (r *Repository) Create(ctx context.Context, device *domain.Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Snapshot under r.mu so that writes reach the database in counter order.
+	record, err := persistence.NewDeviceRecord(device)
+	if err != nil {
+		return err
+	}
+	if err := record.SealPrivateKey(r.masterKey); err != nil {
+		return err
+	}
+	masterKeyID, wrappedDataKey, encryptedPrivateKey := envelopeColumns(record)
+	retiredKeys, err := retiredKeysColumn(record)
+	if err != nil {
+		return err
+	}
+	metadata, err := metadataColumn(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO devices (`+deviceColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ID, string(record.Algorithm), record.Label, metadata,
+		record.Curve, record.RSAScheme, record.Deterministic, record.SignatureEncoding,
+		record.SignatureCounter, record.LastSignature, record.PublicKey, record.PrivateKey,
+		retiredKeys, string(record.Status), masterKeyID, wrappedDataKey, encryptedPrivateKey,
+		timestampColumn(record.CreatedAt), timestampColumn(record.UpdatedAt), timestampColumn(record.LastSignedAt),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+			return persistence.ErrDeviceAlreadyExists
+		}
+		return fmt.Errorf("insert device: %w", err)
+	}
+
+	r.devices[device.ID] = device
+	return nil
+}
+
+// Get retrieves a device by ID
+func (r *Repository) Get(ctx context.Context, id string) (*domain.Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if device, ok := r.devices[id]; ok {
+		return device, nil
+	}
+
+	row := r.db.QueryRowContext(ctx, `SELECT `+deviceColumns+` FROM devices WHERE id = ?`, id)
+
+	record, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, persistence.ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.loadLocked(record)
+}
+
+// List returns a page of the devices matching opts. The filters run in SQL;
+// metadata entries are matched with json_each.
+func (r *Repository) List(ctx context.Context, opts persistence.ListOptions) (persistence.DevicePage, error) {
+	if err := ctx.Err(); err != nil {
+		return persistence.DevicePage{}, err
+	}
+	if !persistence.ValidDeviceSort(opts.Sort) {
+		return persistence.DevicePage{}, persistence.ErrInvalidSort
+	}
+	after, err := persistence.DecodeDeviceCursor(opts.Sort, opts.Cursor)
+	if err != nil {
+		return persistence.DevicePage{}, err
+	}
+
+	query, args := listQuery(opts, after)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return persistence.DevicePage{}, fmt.Errorf("query devices: %w", err)
+	}
+	defer rows.Close()
+
+	page := persistence.DevicePage{Devices: make([]*domain.Device, 0)}
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return persistence.DevicePage{}, err
+		}
+		device, err := r.loadLocked(record)
+		if err != nil {
+			return persistence.DevicePage{}, err
+		}
+		page.Devices = append(page.Devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return persistence.DevicePage{}, fmt.Errorf("query devices: %w", err)
+	}
+
+	if opts.Limit > 0 && len(page.Devices) > opts.Limit {
+		page.Devices = page.Devices[:opts.Limit]
+		page.NextCursor = persistence.EncodeDeviceCursor(opts.Sort, persistence.PositionOf(page.Devices[opts.Limit-1]))
+	}
+
+	return page, nil
+}
+
+// listQuery builds the query and arguments of a device listing that starts
+// after the position after, if not nil.
+func listQuery(opts persistence.ListOptions, after *persistence.DevicePosition) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if opts.Algorithm != "" {
+		conditions = append(conditions, `algorithm = ?`)
+		args = append(args, string(opts.Algorithm))
+	}
+	if opts.Label != "" {
+		conditions = append(conditions, `instr(label, ?) > 0`)
+		args = append(args, opts.Label)
+	}
+	if opts.Status != "" {
+		conditions = append(conditions, `status = ?`)
+		args = append(args, string(opts.Status))
+	}
+	for key, value := range opts.Metadata {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM json_each(NULLIF(devices.metadata, '')) AS entry
+			WHERE entry.key = ? AND entry.type = 'text' AND entry.value = ?)`)
+		args = append(args, key, value)
+	}
+
+	if !opts.CreatedFrom.IsZero() {
+		conditions = append(conditions, `created_at >= ?`)
+		args = append(args, timestampColumn(opts.CreatedFrom))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		conditions = append(conditions, `created_at < ?`)
+		args = append(args, timestampColumn(opts.CreatedBefore))
+	}
+
+	var order string
+	switch opts.Sort {
+	case persistence.SortByIDDesc:
+		order = `id DESC`
+		if after != nil {
+			conditions = append(conditions, `id < ?`)
+			args = append(args, after.ID)
+		}
+	case persistence.SortByCreatedAt:
+		order = `created_at, id`
+		if after != nil {
+			conditions = append(conditions, `(created_at, id) > (?, ?)`)
+			args = append(args, timestampColumn(after.CreatedAt), after.ID)
+		}
+	case persistence.SortByCreatedAtDesc:
+		order = `created_at DESC, id DESC`
+		if after != nil {
+			conditions = append(conditions, `(created_at, id) < (?, ?)`)
+			args = append(args, timestampColumn(after.CreatedAt), after.ID)
+		}
+	default:
+		order = `id`
+		if after != nil {
+			conditions = append(conditions, `id > ?`)
+			args = append(args, after.ID)
+		}
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	limit := -1
+	if opts.Limit > 0 {
+		limit = opts.Limit + 1
+	}
+	args = append(args, limit)
+
+	query := `SELECT ` + deviceColumns + ` FROM devices`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	return query + ` ORDER BY ` + order + ` LIMIT ?`, args
+}
+
+// Update persists the device state in a transaction. The counter is written
+// with a compare-and-swap against the value read in the same transaction, and
+// a counter lower than the stored one is rejected with
+// persistence.ErrCounterRegression. The key columns are only rewritten when
+// the device's key has been rotated.
+func (r *Repository) Update(ctx context.Context, device *domain.Device) error {
+	return r.update(ctx, device, false)
+}
+
+// Delete persists a soft deleted device like Update and clears its private
+// key columns in the same transaction. Secure delete is enabled on the
+// database and the write-ahead log is checkpointed afterwards, so that the
+// erased key does not linger in free pages or the log.
+func (r *Repository) Delete(ctx context.Context, device *domain.Device) error {
+	if device.Snapshot().Status != domain.StatusDeleted {
+		return persistence.ErrDeviceNotDeleted
+	}
+	if err := r.update(ctx, device, true); err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("erase private key of device %s: %w", device.ID, err)
+	}
+	return nil
+}
+
+// update writes the device state, and with eraseKey clears the private key
+// columns, in one transaction.
+func (r *Repository) update(ctx context.Context, device *domain.Device, eraseKey bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Snapshot under r.mu so that writes reach the database in counter order.
+	record, err := persistence.NewDeviceRecord(device)
+	if err != nil {
+		return err
+	}
+	metadata, err := metadataColumn(record)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var storedCounter int
+	var storedPublicKey string
+	err = tx.QueryRowContext(ctx, `SELECT signature_counter, public_key FROM devices WHERE id = ?`, record.ID).
+		Scan(&storedCounter, &storedPublicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return persistence.ErrDeviceNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("read signature counter: %w", err)
+	}
+	if record.SignatureCounter < storedCounter {
+		return persistence.ErrCounterRegression
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE devices
+		SET label = ?, metadata = ?, signature_counter = ?, last_signature = ?, status = ?,
+			updated_at = ?, last_signed_at = ?
+		WHERE id = ? AND signature_counter = ?`,
+		record.Label, metadata, record.SignatureCounter, record.LastSignature, string(record.Status),
+		timestampColumn(record.UpdatedAt), timestampColumn(record.LastSignedAt),
+		record.ID, storedCounter,
+	)
+	if err != nil {
+		return fmt.Errorf("update device: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update device: %w", err)
+	}
+	if affected != 1 {
+		// Another writer moved the counter between our read and write.
+		return persistence.ErrCounterRegression
+	}
+
+	if record.PublicKey != storedPublicKey {
+		if err := r.updateKeysLocked(ctx, tx, record); err != nil {
+			return err
+		}
+	}
+	if eraseKey {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE devices
+			SET private_key = '', master_key_id = NULL, wrapped_data_key = NULL, encrypted_private_key = NULL
+			WHERE id = ?`,
+			record.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("erase private key: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit device update: %w", err)
+	}
+
+	r.devices[device.ID] = device
+	return nil
+}
+
+// RotateMasterKey re-wraps the data key of every stored private key under
+// next in a single transaction and makes next the current master key. The
+// encrypted private keys are left untouched; devices stored in clear text are
+// sealed under next.
+func (r *Repository) RotateMasterKey(ctx context.Context, next *crypto.MasterKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+deviceColumns+` FROM devices`)
+	if err != nil {
+		return fmt.Errorf("query devices: %w", err)
+	}
+
+	var records []persistence.DeviceRecord
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		records = append(records, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query devices: %w", err)
+	}
+
+	masterKeys := r.masterKeysLocked()
+	for _, record := range records {
+		if err := record.RewrapPrivateKey(next, masterKeys...); err != nil {
+			return err
+		}
+
+		masterKeyID, wrappedDataKey, encryptedPrivateKey := envelopeColumns(record)
+		_, err := tx.ExecContext(ctx, `
+			UPDATE devices
+			SET private_key = '', master_key_id = ?, wrapped_data_key = ?, encrypted_private_key = ?
+			WHERE id = ?`,
+			masterKeyID, wrappedDataKey, encryptedPrivateKey, record.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("rewrap device %s: %w", record.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit master key rotation: %w", err)
+	}
+
+	if r.masterKey != nil {
+		r.previousMasterKeys = append(r.previousMasterKeys, r.masterKey)
+	}
+	r.masterKey = next
+	return nil
+}
+
+// updateKeysLocked writes the key pair and retired keys of a device whose key
+// has been rotated. The caller must hold r.mu.
+func (r *Repository) updateKeysLocked(ctx context.Context, tx *sql.Tx, record persistence.DeviceRecord) error {
+	if err := record.SealPrivateKey(r.masterKey); err != nil {
+		return err
+	}
+	masterKeyID, wrappedDataKey, encryptedPrivateKey := envelopeColumns(record)
+	retiredKeys, err := retiredKeysColumn(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE devices
+		SET public_key = ?, private_key = ?, retired_keys = ?,
+			master_key_id = ?, wrapped_data_key = ?, encrypted_private_key = ?
+		WHERE id = ?`,
+		record.PublicKey, record.PrivateKey, retiredKeys,
+		masterKeyID, wrappedDataKey, encryptedPrivateKey, record.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update device keys: %w", err)
+	}
+	return nil
+}
+
+// masterKeysLocked returns every master key that may open a stored private
+// key. The caller must hold r.mu.
+func (r *Repository) masterKeysLocked() []*crypto.MasterKey {
+	return append([]*crypto.MasterKey{r.masterKey}, r.previousMasterKeys...)
+}
+
+// loadLocked returns the cached device for the record, decoding and caching
+// it on first use. The caller must hold r.mu.
+func (r *Repository) loadLocked(record persistence.DeviceRecord) (*domain.Device, error) {
+	if device, ok := r.devices[record.ID]; ok {
+		return device, nil
+	}
+
+	if err := record.OpenPrivateKey(r.masterKeysLocked()...); err != nil {
+		return nil, err
+	}
+
+	device, err := record.Device()
+	if err != nil {
+		return nil, err
+	}
+
+	r.devices[record.ID] = device
+	return device, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row scanner) (persistence.DeviceRecord, error) {
+	var record persistence.DeviceRecord
+	var algorithm string
+	var masterKeyID sql.NullString
+	var metadata, retiredKeys, status string
+	var wrappedDataKey, encryptedPrivateKey []byte
+	var createdAt, updatedAt, lastSignedAt string
+
+	err := row.Scan(
+		&record.ID, &algorithm, &record.Label, &metadata,
+		&record.Curve, &record.RSAScheme, &record.Deterministic, &record.SignatureEncoding,
+		&record.SignatureCounter, &record.LastSignature, &record.PublicKey, &record.PrivateKey,
+		&retiredKeys, &status, &masterKeyID, &wrappedDataKey, &encryptedPrivateKey,
+		&createdAt, &updatedAt, &lastSignedAt,
+	)
+	if err != nil {
+		return persistence.DeviceRecord{}, err
+	}
+	for _, column := range []struct {
+		value string
+		to    *time.Time
+	}{
+		{createdAt, &record.CreatedAt},
+		{updatedAt, &record.UpdatedAt},
+		{lastSignedAt, &record.LastSignedAt},
+	} {
+		if *column.to, err = parseTimestamp(column.value); err != nil {
+			return persistence.DeviceRecord{}, fmt.Errorf("device %s: decode timestamp: %w", record.ID, err)
+		}
+	}
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &record.Metadata); err != nil {
+			return persistence.DeviceRecord{}, fmt.Errorf("device %s: decode metadata: %w", record.ID, err)
+		}
+	}
+	if retiredKeys != "" {
+		if err := json.Unmarshal([]byte(retiredKeys), &record.RetiredKeys); err != nil {
+			return persistence.DeviceRecord{}, fmt.Errorf("device %s: decode retired keys: %w", record.ID, err)
+		}
+	}
+
+	record.Algorithm = domain.SignatureAlgorithm(algorithm)
+	record.Status = domain.DeviceStatus(status)
+	if masterKeyID.Valid {
+		record.EncryptedPrivateKey = &crypto.WrappedKey{
+			MasterKeyID: masterKeyID.String,
+			DataKey:     wrappedDataKey,
+			Ciphertext:  encryptedPrivateKey,
+		}
+	}
+	return record, nil
+}
+
+// timestampLayout is the fixed width UTC format of the timestamp columns, so
+// that they sort as text in time order.
+const timestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// timestampColumn formats t for a timestamp column; the zero time is stored
+// as the empty string.
+func timestampColumn(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timestampLayout)
+}
+
+// parseTimestamp reads a timestamp column written by timestampColumn.
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(timestampLayout, value)
+}
+
+// metadataColumn encodes the metadata of a record as a JSON object, or as the
+// empty string if the device has none.
+func metadataColumn(record persistence.DeviceRecord) (string, error) {
+	if len(record.Metadata) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(record.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("device %s: encode metadata: %w", record.ID, err)
+	}
+	return string(encoded), nil
+}
+
+// retiredKeysColumn encodes the retired keys of a record as a JSON array, or
+// as the empty string if the device never rotated its key.
+func retiredKeysColumn(record persistence.DeviceRecord) (string, error) {
+	if len(record.RetiredKeys) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(record.RetiredKeys)
+	if err != nil {
+		return "", fmt.Errorf("device %s: encode retired keys: %w", record.ID, err)
+	}
+	return string(encoded), nil
+}
+
+// envelopeColumns splits the envelope of a record into its column values,
+// which are all NULL for a clear text private key.
+func envelopeColumns(record persistence.DeviceRecord) (interface{}, interface{}, interface{}) {
+	if record.EncryptedPrivateKey == nil {
+		return nil, nil, nil
+	}
+	wrapped := record.EncryptedPrivateKey
+	return wrapped.MasterKeyID, wrapped.DataKey, wrapped.Ciphertext
+}
@@ -0,0 +1,309 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence/persistencetest"
+)
+
+func openTestRepository(t *testing.T, path string, opts ...Option) *Repository {
+	t.Helper()
+
+	repo, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("failed to open sqlite repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestRepository_Conformance(t *testing.T) {
+	persistencetest.RunDeviceRepositorySuite(t, func(t *testing.T) persistence.DeviceRepository {
+		return openTestRepository(t, filepath.Join(t.TempDir(), "devices.db"))
+	})
+}
+
+func TestRepository_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	repo := openTestRepository(t, path)
+	ctx := context.Background()
+
+	device := persistencetest.NewRSADevice(t, "device-1")
+	device.SignatureCounter = 3
+	device.LastSignature = "bGFzdA=="
+	pkcs1v15Device := persistencetest.NewRSADevice(t, "device-5")
+	pkcs1v15Device.RSAScheme = crypto.RSASchemePKCS1v15
+	deterministicDevice := persistencetest.NewECDSACurveDevice(t, "device-6", crypto.CurveP256)
+	deterministicDevice.Deterministic = true
+	deterministicDevice.SignatureEncoding = crypto.SignatureEncodingRaw
+	rotatedDevice := persistencetest.NewEd25519Device(t, "device-7")
+	persistencetest.RotateKey(t, rotatedDevice)
+	retiredDevice := persistencetest.NewECDSADevice(t, "device-8")
+	retiredDevice.Status = domain.StatusRetired
+	retiredDevice.Metadata = map[string]string{"store_id": "42", "tenant": "acme"}
+	devices := []*domain.Device{
+		device,
+		pkcs1v15Device,
+		rotatedDevice,
+		deterministicDevice,
+		persistencetest.NewECDSACurveDevice(t, "device-2", crypto.CurveP256),
+		persistencetest.NewECDSACurveDevice(t, "device-3", crypto.CurveP521),
+		persistencetest.NewEd25519Device(t, "device-4"),
+		retiredDevice,
+	}
+	for _, device := range devices {
+		if err := repo.Create(ctx, device); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+	// Status changes reach the database through Update.
+	if err := pkcs1v15Device.Suspend(); err != nil {
+		t.Fatalf("failed to suspend device: %v", err)
+	}
+	if err := repo.Update(ctx, pkcs1v15Device); err != nil {
+		t.Fatalf("failed to update device: %v", err)
+	}
+	repo.Close()
+
+	reopened := openTestRepository(t, path)
+	for _, device := range devices {
+		got, err := reopened.Get(ctx, device.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		persistencetest.AssertDeviceEqual(t, device, got)
+	}
+
+	var version int
+	reopened.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if version != len(migrations) {
+		t.Errorf("expected schema version %d, got %d", len(migrations), version)
+	}
+}
+
+func TestRepository_KeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	ctx := context.Background()
+	masterKey := newTestMasterKey(t)
+
+	repo := openTestRepository(t, path, WithMasterKey(masterKey))
+	device := persistencetest.NewRSADevice(t, "device-1")
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	persistencetest.RotateKey(t, device)
+	persistencetest.RotateKey(t, device)
+	if err := repo.Update(ctx, device); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	repo.Close()
+
+	reopened := openTestRepository(t, path, WithMasterKey(masterKey))
+	got, err := reopened.Get(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	persistencetest.AssertDeviceEqual(t, device, got)
+
+	var privateKey string
+	reopened.db.QueryRow(`SELECT private_key FROM devices WHERE id = ?`, "device-1").Scan(&privateKey)
+	if privateKey != "" {
+		t.Error("expected the rotated private key to be sealed under the master key")
+	}
+}
+
+func TestRepository_Delete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	ctx := context.Background()
+
+	repo := openTestRepository(t, path)
+	device := persistencetest.NewECDSADevice(t, "device-1")
+	device.Metadata = map[string]string{"store_id": "42"}
+	_, privateKeyPEM, err := crypto.NewPKCS8Marshaler().Marshal(device.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %v", err)
+	}
+	// The base64 body of the PEM block, as stored in the private_key column
+	secret := bytes.Split(privateKeyPEM, []byte("\n"))[1]
+
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	persistencetest.DeleteDevice(t, device)
+	if err := repo.Delete(ctx, device); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	repo.Close()
+
+	for _, file := range []string{path, path + "-wal"} {
+		contents, err := os.ReadFile(file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		if bytes.Contains(contents, secret) {
+			t.Errorf("expected the private key to be erased from %s", filepath.Base(file))
+		}
+	}
+
+	reopened := openTestRepository(t, path)
+	got, err := reopened.Get(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	persistencetest.AssertDeviceEqual(t, device, got)
+}
+
+func TestRepository_CounterCompareAndSwap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	ctx := context.Background()
+
+	// Two repositories on the same file behave like two service instances.
+	first := openTestRepository(t, path)
+	second := openTestRepository(t, path)
+
+	device := persistencetest.NewECDSADevice(t, "device-1")
+	if err := first.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	stale, err := second.Get(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("failed to load device: %v", err)
+	}
+
+	device.SignatureCounter = 2
+	if err := first.Update(ctx, device); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stale.SignatureCounter = 1
+	if err := second.Update(ctx, stale); !errors.Is(err, persistence.ErrCounterRegression) {
+		t.Errorf("expected error %v, got %v", persistence.ErrCounterRegression, err)
+	}
+
+	var counter int
+	first.db.QueryRow(`SELECT signature_counter FROM devices WHERE id = ?`, "device-1").Scan(&counter)
+	if counter != 2 {
+		t.Errorf("expected stored counter 2, got %d", counter)
+	}
+}
+
+func TestRepository_ReconcileJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	ctx := context.Background()
+
+	repo := openTestRepository(t, path)
+	device := persistencetest.NewECDSADevice(t, "device-1")
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	algorithm, _ := crypto.Lookup(string(device.Algorithm))
+	journal := repo.SignatureJournal()
+	appendRecord := func(record domain.SignatureRecord) error { return journal.Append(ctx, record) }
+
+	// Crash after journaling a signature, before the device is updated.
+	if _, err := device.SignWithCurrentKey(algorithm.NewSigner, "data", appendRecord); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	repo.Close()
+
+	reopened := openTestRepository(t, path)
+	if err := persistence.ReconcileJournal(ctx, reopened, reopened.SignatureJournal()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var counter int
+	reopened.db.QueryRow(`SELECT signature_counter FROM devices WHERE id = ?`, "device-1").Scan(&counter)
+	if counter != 1 {
+		t.Errorf("expected stored counter 1, got %d", counter)
+	}
+	stored, _ := reopened.Get(ctx, "device-1")
+	journal = reopened.SignatureJournal()
+	if _, err := stored.SignWithCurrentKey(algorithm.NewSigner, "data", appendRecord); err != nil {
+		t.Errorf("expected the reconciled device to sign, got %v", err)
+	}
+}
+
+func TestRepository_MasterKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	ctx := context.Background()
+	oldKey := newTestMasterKey(t)
+	newKey := newTestMasterKey(t)
+
+	repo := openTestRepository(t, path, WithMasterKey(oldKey))
+	device := persistencetest.NewECDSADevice(t, "device-1")
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	var privateKey string
+	var ciphertextBefore []byte
+	repo.db.QueryRow(`SELECT private_key, encrypted_private_key FROM devices WHERE id = ?`, "device-1").
+		Scan(&privateKey, &ciphertextBefore)
+	if privateKey != "" || len(ciphertextBefore) == 0 {
+		t.Fatal("expected private key to be stored encrypted")
+	}
+
+	if err := repo.RotateMasterKey(ctx, newKey); err != nil {
+		t.Fatalf("failed to rotate master key: %v", err)
+	}
+
+	var masterKeyID string
+	var ciphertextAfter []byte
+	repo.db.QueryRow(`SELECT master_key_id, encrypted_private_key FROM devices WHERE id = ?`, "device-1").
+		Scan(&masterKeyID, &ciphertextAfter)
+	if masterKeyID != newKey.ID() {
+		t.Errorf("expected master key ID %q, got %q", newKey.ID(), masterKeyID)
+	}
+	if !bytes.Equal(ciphertextBefore, ciphertextAfter) {
+		t.Error("expected rotation to re-wrap the data key only")
+	}
+	repo.Close()
+
+	// A fresh process with only the old key can no longer read the device.
+	stale := openTestRepository(t, path, WithMasterKey(oldKey))
+	if _, err := stale.Get(ctx, "device-1"); !errors.Is(err, persistence.ErrMasterKeyRequired) {
+		t.Errorf("expected error %v, got %v", persistence.ErrMasterKeyRequired, err)
+	}
+
+	reopened := openTestRepository(t, path, WithMasterKey(newKey))
+	got, err := reopened.Get(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	persistencetest.AssertDeviceEqual(t, device, got)
+}
+
+func newTestMasterKey(t *testing.T) *crypto.MasterKey {
+	t.Helper()
+
+	raw := make([]byte, crypto.MasterKeySize)
+	rand.Read(raw)
+	key, err := crypto.NewMasterKey(raw)
+	if err != nil {
+		t.Fatalf("failed to create master key: %v", err)
+	}
+	return key
+}
+
+func TestSignatureJournal_Conformance(t *testing.T) {
+	persistencetest.RunSignatureJournalSuite(t, func(t *testing.T, deviceIDs ...string) persistence.SignatureJournal {
+		repo := openTestRepository(t, filepath.Join(t.TempDir(), "devices.db"))
+		for _, id := range deviceIDs {
+			if err := repo.Create(context.Background(), persistencetest.NewECDSADevice(t, id)); err != nil {
+				t.Fatalf("failed to create device: %v", err)
+			}
+		}
+		return repo.SignatureJournal()
+	})
+}